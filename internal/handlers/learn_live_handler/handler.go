@@ -25,6 +25,35 @@ func SetupRoutes(r chi.Router) {
 		r.Put("/update-subject", routes.UpdateSubject)
 		r.Delete("/delete-subject", routes.DeleteSubject)
 
+		r.Get("/list-grading-scales", routes.ListGradingScales) // scales with their values
+		r.Post("/add-grading-scale", routes.AddGradingScale)
+		r.Put("/update-grading-scale", routes.UpdateGradingScale) // replaces all values of the scale
+		r.Delete("/delete-grading-scale", routes.DeleteGradingScale)
+
+		r.Get("/list-terms", routes.ListTerms)
+		r.Post("/add-term", routes.AddTerm)
+		r.Put("/update-term", routes.UpdateTerm)
+		r.Delete("/delete-term", routes.DeleteTerm)
+
+		r.Get("/transcript", routes.GetTranscript) // format=json|pdf, students get their own transcript
+
+		r.Get("/list-subject-prerequisites", routes.ListSubjectPrerequisites)
+		r.Post("/add-subject-prerequisite", routes.AddSubjectPrerequisite)
+		r.Put("/update-subject-prerequisite", routes.UpdateSubjectPrerequisite) // just update min_grade
+		r.Delete("/delete-subject-prerequisite", routes.DeleteSubjectPrerequisite)
+		r.Get("/check-student-eligibility", routes.CheckStudentEligibility) // students check themselves
+		r.Get("/check-group-eligibility", routes.CheckGroupEligibility)     // lists only students with unmet prerequisites
+
+		r.Get("/list-electives", routes.ListElectives) // capacity with enrolled and waitlisted counts
+		r.Post("/add-elective", routes.AddElective)
+		r.Put("/update-elective", routes.UpdateElective)
+		r.Delete("/delete-elective", routes.DeleteElective)
+
+		r.Get("/list-current-user-enrollments", routes.ListCurrentUserEnrollments)
+		r.Get("/list-enrollments-of-a-subject", routes.ListEnrollmentsOfASubject)
+		r.Post("/enroll", routes.EnrollStudent)             // students enroll themselves, admins pass student_id
+		r.Delete("/drop-enrollment", routes.DropEnrollment) // promotes the next waitlisted student
+
 		r.Get("/list-rooms", routes.ListRooms)
 		r.Get("/list-rooms-of-a-subject", routes.ListRoomsOfASubject)
 		r.Post("/add-room", routes.AddRoom)
@@ -35,13 +64,20 @@ func SetupRoutes(r chi.Router) {
 		r.Get("/list-students-of-a-group", routes.ListStudentsOfAGroup)
 		r.Post("/add-group", routes.AddGroup)
 		r.Put("/update-group", routes.UpdateGroup)
+		r.Put("/set-group-curator", routes.SetGroupCurator) // curator_id = 0 removes the curator
 		r.Delete("/delete-group", routes.DeleteGroup)
 
+		r.Get("/list-subgroups-of-a-group", routes.ListSubgroupsOfAGroup)
+		r.Post("/add-subgroup", routes.AddSubgroup)
+		r.Put("/update-subgroup", routes.UpdateSubgroup)
+		r.Delete("/delete-subgroup", routes.DeleteSubgroup)
+		r.Put("/set-student-subgroup", routes.SetStudentSubgroup) // subgroup_id = 0 removes student from subgroup
+
 		r.Get("/list-groups-and-subjects-relations", routes.ListGroupsSubjects)      // list both ids and names
 		r.Get("/list-subjects-of-a-group", routes.ListSubjectsOfAGroup)              // list both ids and names
 		r.Get("/list-groups-that-have-a-subject", routes.ListGroupsThatHaveASubject) // list both ids and names
-		r.Post("/add-groups-and-subjects-relation", routes.AddGroupSubject)          // just insert
-		r.Put("/update-groups-and-subjects-relation", routes.UpdateGroupSubject)     // just update set names by id
+		r.Post("/add-groups-and-subjects-relation", routes.AddGroupSubject)          // checks prerequisites unless force=true
+		r.Put("/update-groups-and-subjects-relation", routes.UpdateGroupSubject)     // checks prerequisites unless force=true
 		r.Delete("/delete-groups-and-subjects-relation", routes.DeleteGroupSubject)  // just delete by ids (remember, no body)
 
 		r.Get("/list-professors-and-subjects-relations", routes.ListProfessorsSubjects)      // list both ids and names
@@ -58,19 +94,96 @@ func SetupRoutes(r chi.Router) {
 		r.Put("/update-professors-and-groups-relation", routes.UpdateProfessorGroup)     // just update set names by id
 		r.Delete("/delete-professors-and-groups-relation", routes.DeleteProfessorGroup)  // just delete by ids (remember, no body)
 
-		r.Get("/list-current-user-grades-and-attendance-by-subject", routes.ListCurrentUserGradesAndAttendanceBySubject)
-		r.Get("/list-grades-and-attendance-of-a-student-by-subject", routes.ListGradesAndAttendanceOfAStudentBySubject)
-		r.Get("/list-grades-and-attendance-of-a-group", routes.ListGradesAndAttendanceOfAGroup)
+		r.Get("/list-current-user-grades-and-attendance-by-subject", routes.ListCurrentUserGradesAndAttendanceBySubject) // optional from, to (YYYY-MM-DD) and sort (asc or desc)
+		r.Get("/list-grades-and-attendance-of-a-student-by-subject", routes.ListGradesAndAttendanceOfAStudentBySubject)  // optional from, to and sort
+		r.Get("/list-grades-and-attendance-of-a-group", routes.ListGradesAndAttendanceOfAGroup)                          // optional subgroup_id, from, to and sort
+		r.Get("/list-grades-and-attendance-of-a-group-by-subgroup", routes.ListGradesAndAttendanceOfAGroupBySubgroup)
+		r.Get("/gradebook", routes.GetGradebook)            // students x lessons matrix for group_id and subject_id, optional subgroup_id
+		r.Get("/export-gradebook", routes.ExportGradebook)  // group_id, subject_id, format=csv|xlsx
+		r.Post("/import-gradebook", routes.ImportGradebook) // multipart file, group_id, subject_id, optional format, dry_run and reason
 		r.Post("/insert-grade-and-attendance-of-a-student", routes.InsertGradeAndAttendanceOfAStudent)
+		r.Post("/insert-grades-of-students", routes.InsertGradesOfStudents) // subject_id and grades, all or nothing
 		r.Put("/update-grade-and-attendance-of-a-student", routes.UpdateGradeAndAttendanceOfAStudent)
 		r.Delete("/delete-grade-and-attendance-of-a-student", routes.DeleteGradeAndAttendanceOfAStudent)
 
+		r.Get("/list-grade-categories-of-a-subject", routes.ListGradeCategoriesOfASubject)
+		r.Post("/add-grade-category", routes.AddGradeCategory)
+		r.Put("/update-grade-category", routes.UpdateGradeCategory)
+		r.Delete("/delete-grade-category", routes.DeleteGradeCategory)               // id and subject_id
+		r.Get("/list-weighted-scores-of-a-group", routes.ListWeightedScoresOfAGroup) // group_id and subject_id
+		r.Get("/get-weighted-score-of-a-student", routes.GetWeightedScoreOfAStudent) // students get their own score
+		r.Get("/group-ranking", routes.GetGroupRanking)                              // group_id, subject_id, rank_by=weighted|total|attendance
+		r.Get("/current-user-standing", routes.GetCurrentUserStanding)               // subject_id, rank_by; only if ranking is visible
+		r.Put("/set-ranking-visibility", routes.SetRankingVisibility)                // subject_id and ranking_visible
+
 		r.Get("/list-current-user-total-grades-by-subject", routes.ListCurrentUserTotalGradesBySubject)
 		r.Get("/list-total-grades-of-a-student-by-subject", routes.ListTotalGradesOfAStudentBySubject)
-		r.Get("/list-total-grades-of-a-group", routes.ListTotalGradesOfAGroup)
+		r.Get("/list-total-grades-of-a-group", routes.ListTotalGradesOfAGroup) // optional subgroup_id
 		r.Post("/insert-total-grade-of-a-student", routes.InsertTotalGradeOfAStudent)
 		r.Put("/update-total-grade-of-a-student", routes.UpdateTotalGradeOfAStudent)
 		r.Delete("/delete-total-grade-of-a-student", routes.DeleteTotalGradeOfAStudent)
+		r.Get("/list-total-grade-proposals", routes.ListTotalGradeProposals)                             // group_id and subject_id, uses subject grading_method
+		r.Post("/accept-total-grade-proposals", routes.AcceptTotalGradeProposals)                        // optional student_ids, skips overridden grades
+		r.Put("/override-total-grade-of-a-student", routes.OverrideTotalGradeOfAStudent)                 // requires override_reason
+		r.Get("/list-grade-history-of-a-grade", routes.ListGradeHistoryOfAGrade)                         // grade_kind (lesson, total or attendance) and grade_id, attendance grade_id is the lesson id
+		r.Get("/list-grade-history-of-a-student-by-subject", routes.ListGradeHistoryOfAStudentBySubject) // student_id for professors and admins
+		r.Post("/finalize-grades", routes.FinalizeGrades)                                                // group_id and subject_id, locks grades of the group
+		r.Post("/unlock-grades", routes.UnlockGrades)                                                    // admin only, duration_minutes and reason are required
+		r.Get("/list-grade-locks", routes.ListGradeLocks)                                                // optional group_id and subject_id
+		r.Get("/list-grade-unlocks", routes.ListGradeUnlocks)                                            // admin only, group_id and subject_id
+		r.Get("/grade-statistics", routes.GetGradeStatistics)                                            // optional group_id, subject_id, professor_id, term_id and group_by
+		r.Get("/compare-groups-by-subject", routes.CompareGroupsBySubject)                               // subject_id, optional term_id
+		r.Post("/open-grade-appeal", routes.OpenGradeAppeal)                                             // student only, grade_kind, grade_id and reason
+		r.Get("/list-grade-appeals", routes.ListGradeAppeals)                                            // optional status
+		r.Get("/grade-appeal", routes.GetGradeAppeal)                                                    // appeal_id, with comments and attachments
+		r.Post("/assign-grade-appeal", routes.AssignGradeAppeal)                                         // admin only
+		r.Post("/escalate-grade-appeal", routes.EscalateGradeAppeal)
+		r.Post("/resolve-grade-appeal", routes.ResolveGradeAppeal) // optional new_grade is written into the grade
+		r.Post("/comment-grade-appeal", routes.CommentGradeAppeal)
+		r.Post("/upload-grade-appeal-attachment", routes.UploadGradeAppealAttachment) // multipart appeal_id and file
+		r.Get("/grade-appeal-attachment", routes.GetGradeAppealAttachment)            // attachment_id
+
+		r.Get("/list-lessons", routes.ListLessons) // optional group_id, subject_id, professor_id, from, to and sort
+		r.Get("/lesson", routes.GetLesson)         // id, with attendance and grades of the lesson
+		r.Post("/add-lesson", routes.AddLesson)
+		r.Put("/update-lesson", routes.UpdateLesson)
+		r.Delete("/delete-lesson", routes.DeleteLesson)                                       // id
+		r.Put("/cancel-lesson", routes.CancelLesson)                                          // id, the lesson stays in timetables and calendars as cancelled
+		r.Get("/timetable", routes.GetTimetable)                                              // view=day|week, optional date, timezone, group_id or professor_id
+		r.Get("/list-timetable-rules", routes.ListTimetableRules)                             // optional group_id and professor_id
+		r.Post("/add-timetable-rule", routes.AddTimetableRule)                                // creates the lessons of the rule, 409 with conflicts
+		r.Delete("/delete-timetable-rule", routes.DeleteTimetableRule)                        // id, future lessons of the rule are deleted
+		r.Post("/record-lesson-attendance", routes.RecordLessonAttendance)                    // lesson_id and attendance
+		r.Get("/list-current-user-lesson-attendance", routes.ListCurrentUserLessonAttendance) // optional subject_id, from, to and sort
+		r.Get("/attendance-rule", routes.GetAttendanceRule)                                   // subject_id
+		r.Put("/set-attendance-rule", routes.SetAttendanceRule)
+		r.Get("/list-attendance-summaries", routes.ListAttendanceSummaries)                         // subject_id, optional group_id, student_id, from and to
+		r.Get("/list-current-user-attendance-summaries", routes.ListCurrentUserAttendanceSummaries) // optional subject_id, from and to
+		r.Get("/attendance-report", routes.GetAttendanceReport)                                     // by=student|group|subject, optional subject_id, group_id, student_id, from and to
+		r.Post("/livekit-webhook", routes.LiveKitWebhook)                                           // called by LiveKit, signed with the LiveKit API key
+		r.Get("/lesson-auto-attendance", routes.GetLessonAutoAttendance)                            // lesson_id, attendance proposed from LiveKit participant events
+		r.Post("/confirm-lesson-auto-attendance", routes.ConfirmLessonAutoAttendance)               // lesson_id and optional attendance overrides
+		r.Post("/open-check-in-window", routes.OpenCheckInWindow)                                   // lesson_id, optional rotation_seconds and duration_minutes
+		r.Put("/close-check-in-window", routes.CloseCheckInWindow)
+		r.Get("/check-in-code", routes.GetCheckInCode) // window_id, code to show as digits or QR code
+		r.Post("/check-in", routes.CheckInToLesson)    // window_id and code, students check themselves in
+
+		r.Get("/list-assignments", routes.ListAssignments) // optional group_id, subject_id, from, to and sort by due_at
+		r.Post("/add-assignment", routes.AddAssignment)    // subject_id, group_id, optional subgroup_id, title, description and due_at
+		r.Put("/update-assignment", routes.UpdateAssignment)
+		r.Put("/cancel-assignment", routes.CancelAssignment) // id, the assignment stays in calendars as cancelled
+
+		r.Post("/submit-absence-excuse", routes.SubmitAbsenceExcuse)       // multipart: date_from, date_to, reason and file
+		r.Get("/list-absence-excuses", routes.ListAbsenceExcuses)          // optional status and student_id
+		r.Get("/absence-excuse-document", routes.GetAbsenceExcuseDocument) // id
+		r.Put("/review-absence-excuse", routes.ReviewAbsenceExcuse)        // id, status approved or rejected, optional comment
+
+		r.Get("/list-current-user-notifications", routes.ListCurrentUserNotifications) // optional unread=true
+		r.Put("/mark-notifications-read", routes.MarkNotificationsRead)                // ids, empty marks all
+
+		r.Get("/current-user-calendar-feed", routes.GetCurrentUserCalendarFeed)         // secret iCalendar feed URL, created on first request
+		r.Put("/reset-current-user-calendar-feed", routes.ResetCurrentUserCalendarFeed) // revokes the old feed URL
+		r.Get("/calendar-feed", routes.GetCalendarFeed)                                 // token, no login needed, text/calendar
 
 		r.Get("/get-token", routes.GetToken)
 
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// AbsenceExcuse - заявление студента об уважительной причине пропусков с DateFrom по DateTo включительно.
+// Статусы: pending - на рассмотрении, approved - одобрено, rejected - отклонено.
+// Подтверждающий документ отдаётся отдельным запросом.
+type AbsenceExcuse struct {
+	ID               int        `json:"id"`
+	StudentID        int        `json:"student_id"`
+	StudentFirstname string     `json:"student_firstname,omitempty"`
+	StudentLastname  string     `json:"student_lastname,omitempty"`
+	GroupID          int        `json:"group_id,omitempty"`
+	DateFrom         time.Time  `json:"date_from"`
+	DateTo           time.Time  `json:"date_to"`
+	Reason           string     `json:"reason"`
+	Status           string     `json:"status"`
+	FileName         string     `json:"file_name"`
+	ContentType      string     `json:"content_type"`
+	Size             int        `json:"size"`
+	ReviewedBy       int        `json:"reviewed_by,omitempty"`
+	ReviewComment    string     `json:"review_comment,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	ExcusedLessons   int        `json:"excused_lessons,omitempty"`
+}
+
+// AbsenceExcuseReview - решение по заявлению: status approved или rejected и необязательный комментарий
+type AbsenceExcuseReview struct {
+	ID      int    `json:"id"`
+	Status  string `json:"status"`
+	Comment string `json:"comment,omitempty"`
+}
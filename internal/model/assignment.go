@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Assignment - задание по предмету для группы (или подгруппы, если SubgroupID указан) со сроком сдачи DueAt.
+// Sequence увеличивается при каждом изменении и отмене задания.
+type Assignment struct {
+	ID          int       `json:"id"`
+	SubjectID   int       `json:"subject_id"`
+	SubjectName string    `json:"subject_name,omitempty"`
+	GroupID     int       `json:"group_id"`
+	GroupName   string    `json:"group_name,omitempty"`
+	SubgroupID  int       `json:"subgroup_id,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	DueAt       time.Time `json:"due_at"`
+	CreatedBy   int       `json:"created_by,omitempty"`
+	Cancelled   bool      `json:"cancelled,omitempty"`
+	Sequence    int       `json:"sequence"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,54 @@
+package model
+
+// AttendanceRule - правила подсчёта посещаемости предмета. Нулевые LatesPerAbsence и LeftEarlyPerAbsence
+// означают, что опоздания и ранние уходы не превращаются в пропуски.
+// LateAfterMinutes и MinPresencePercent - пороги автоматической отметки по подключениям к LiveKit.
+// AlertBelowPercent - порог посещаемости для уведомлений, 0 - без уведомлений.
+type AttendanceRule struct {
+	SubjectID              int  `json:"subject_id"`
+	LatesPerAbsence        int  `json:"lates_per_absence"`
+	LeftEarlyPerAbsence    int  `json:"left_early_per_absence"`
+	RemoteCountsAsPresent  bool `json:"remote_counts_as_present"`
+	ExcusedCountsAsPresent bool `json:"excused_counts_as_present"`
+	LateAfterMinutes       int  `json:"late_after_minutes"`
+	MinPresencePercent     int  `json:"min_presence_percent"`
+	AlertBelowPercent      int  `json:"alert_below_percent"`
+}
+
+// AttendanceSummary - посещаемость студента по предмету с учётом правил предмета.
+// CountedLessons - занятия, учитываемые в AttendanceRate, AttendedLessons - посещённые из них за вычетом
+// пропусков, набранных опозданиями и ранними уходами, EffectiveAbsences - пропуски вместе с набранными.
+type AttendanceSummary struct {
+	StudentID         int     `json:"student_id"`
+	StudentFirstname  string  `json:"student_firstname"`
+	StudentLastname   string  `json:"student_lastname"`
+	GroupID           int     `json:"group_id"`
+	GroupName         string  `json:"group_name"`
+	SubgroupID        int     `json:"subgroup_id,omitempty"`
+	SubjectID         int     `json:"subject_id"`
+	SubjectName       string  `json:"subject_name"`
+	RecordedLessons   int     `json:"recorded_lessons"`
+	Present           int     `json:"present"`
+	Late              int     `json:"late"`
+	Excused           int     `json:"excused"`
+	LeftEarly         int     `json:"left_early"`
+	Remote            int     `json:"remote"`
+	Absent            int     `json:"absent"`
+	CountedLessons    int     `json:"counted_lessons"`
+	AttendedLessons   int     `json:"attended_lessons"`
+	EffectiveAbsences int     `json:"effective_absences"`
+	AttendanceRate    float64 `json:"attendance_rate"`
+}
+
+// AttendanceReportRow - посещаемость студента, группы или предмета за период.
+// Students - число студентов с отметками, AttendancePercent - доля посещённых учитываемых занятий в процентах.
+type AttendanceReportRow struct {
+	ID                int     `json:"id"`
+	Name              string  `json:"name"`
+	Students          int     `json:"students"`
+	RecordedLessons   int     `json:"recorded_lessons"`
+	CountedLessons    int     `json:"counted_lessons"`
+	AttendedLessons   int     `json:"attended_lessons"`
+	EffectiveAbsences int     `json:"effective_absences"`
+	AttendancePercent float64 `json:"attendance_percent"`
+}
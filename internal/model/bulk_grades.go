@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// BulkGradeEntry - оценки за занятие для многих студентов одного предмета.
+// CategoryID, LessonID, LessonDate, Description и Reason применяются к оценкам, в которых они не указаны.
+type BulkGradeEntry struct {
+	SubjectID   int            `json:"subject_id"`
+	CategoryID  int            `json:"category_id,omitempty"`
+	LessonID    int            `json:"lesson_id,omitempty"`
+	LessonDate  *time.Time     `json:"lesson_date,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Reason      string         `json:"reason,omitempty"`
+	Grades      []StudentGrade `json:"grades"`
+}
+
+// BulkGradeResult - результат по одной оценке пакета. Index - позиция оценки в запросе.
+type BulkGradeResult struct {
+	Index     int    `json:"index"`
+	StudentID int    `json:"student_id"`
+	GradeID   int    `json:"grade_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
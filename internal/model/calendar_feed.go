@@ -0,0 +1,8 @@
+package model
+
+// CalendarFeed - секретная ссылка на календарь занятий пользователя в формате iCalendar.
+// Ссылка работает без входа в систему, поэтому её нужно сбрасывать, если она стала известна другим.
+type CalendarFeed struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
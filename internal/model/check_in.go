@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// CheckInWindow - окно самостоятельной отметки студентов на очном занятии.
+// Код окна меняется каждые RotationSeconds секунд, DurationMinutes задаётся только при открытии окна.
+type CheckInWindow struct {
+	ID              int        `json:"id"`
+	LessonID        int        `json:"lesson_id"`
+	RotationSeconds int        `json:"rotation_seconds"`
+	DurationMinutes int        `json:"duration_minutes,omitempty"`
+	OpenedAt        time.Time  `json:"opened_at"`
+	ClosesAt        time.Time  `json:"closes_at"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+}
+
+// CheckInCode - текущий код окна отметки. QRPayload - текст для QR-кода с тем же кодом,
+// изображение QR-кода строит клиент.
+type CheckInCode struct {
+	WindowID  int       `json:"window_id"`
+	Code      string    `json:"code"`
+	QRPayload string    `json:"qr_payload"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CheckIn - код окна отметки, введённый студентом
+type CheckIn struct {
+	WindowID int    `json:"window_id"`
+	Code     string `json:"code"`
+}
@@ -0,0 +1,9 @@
+package model
+
+type Elective struct {
+	SubjectID     int    `json:"subject_id"`
+	SubjectName   string `json:"subject_name,omitempty"`
+	Capacity      int    `json:"capacity"`
+	EnrolledCount int    `json:"enrolled_count"`
+	WaitlistCount int    `json:"waitlist_count"`
+}
@@ -0,0 +1,19 @@
+package model
+
+// EligibilityReport описывает, может ли студент изучать предмет, и какие пререквизиты не выполнены
+type EligibilityReport struct {
+	StudentID          int                 `json:"student_id"`
+	StudentFirstname   string              `json:"student_firstname,omitempty"`
+	StudentLastname    string              `json:"student_lastname,omitempty"`
+	SubjectID          int                 `json:"subject_id"`
+	Eligible           bool                `json:"eligible"`
+	UnmetPrerequisites []UnmetPrerequisite `json:"unmet_prerequisites"`
+}
+
+type UnmetPrerequisite struct {
+	PrerequisiteID   int     `json:"prerequisite_id"`
+	PrerequisiteName string  `json:"prerequisite_name"`
+	MinGrade         float64 `json:"min_grade"`
+	// Итоговая оценка студента по пререквизиту, пустая если оценки нет
+	Grade string `json:"grade"`
+}
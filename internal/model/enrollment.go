@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type Enrollment struct {
+	ID               int       `json:"id,omitempty"`
+	StudentID        int       `json:"student_id,omitempty"`
+	StudentFirstname string    `json:"student_firstname,omitempty"`
+	StudentLastname  string    `json:"student_lastname,omitempty"`
+	StudentGroupID   int       `json:"student_group_id,omitempty"`
+	SubjectID        int       `json:"subject_id"`
+	SubjectName      string    `json:"subject_name,omitempty"`
+	Status           string    `json:"status,omitempty"`
+	EnrolledAt       time.Time `json:"enrolled_at"`
+}
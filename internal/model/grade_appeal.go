@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// GradeAppeal - апелляция студента на оценку за занятие (grade_kind = lesson) или итоговую (total).
+// Статусы: open - открыта, assigned - назначена преподавателю, escalated - передана администратору,
+// resolved - решена. ResolvedGrade пуст, если оценка оставлена без изменений.
+type GradeAppeal struct {
+	ID                 int                     `json:"id"`
+	StudentID          int                     `json:"student_id"`
+	StudentFirstname   string                  `json:"student_firstname,omitempty"`
+	StudentLastname    string                  `json:"student_lastname,omitempty"`
+	GradeKind          string                  `json:"grade_kind"`
+	GradeID            int                     `json:"grade_id"`
+	SubjectID          int                     `json:"subject_id"`
+	SubjectName        string                  `json:"subject_name,omitempty"`
+	Status             string                  `json:"status"`
+	ProfessorID        int                     `json:"professor_id,omitempty"`
+	ProfessorFirstname string                  `json:"professor_firstname,omitempty"`
+	ProfessorLastname  string                  `json:"professor_lastname,omitempty"`
+	Reason             string                  `json:"reason"`
+	Resolution         string                  `json:"resolution,omitempty"`
+	ResolvedGrade      string                  `json:"resolved_grade,omitempty"`
+	ResolvedBy         int                     `json:"resolved_by,omitempty"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
+	ResolvedAt         *time.Time              `json:"resolved_at,omitempty"`
+	Comments           []GradeAppealComment    `json:"comments,omitempty"`
+	Attachments        []GradeAppealAttachment `json:"attachments,omitempty"`
+}
+
+type GradeAppealComment struct {
+	ID              int       `json:"id"`
+	AppealID        int       `json:"appeal_id"`
+	AuthorID        int       `json:"author_id,omitempty"`
+	AuthorFirstname string    `json:"author_firstname,omitempty"`
+	AuthorLastname  string    `json:"author_lastname,omitempty"`
+	Body            string    `json:"body"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GradeAppealAttachment - описание вложения, содержимое файла отдаётся отдельным запросом
+type GradeAppealAttachment struct {
+	ID          int       `json:"id"`
+	AppealID    int       `json:"appeal_id"`
+	UploadedBy  int       `json:"uploaded_by,omitempty"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GradeAppealAction - тело запросов к апелляции: назначение (professor_id), комментарий и передача
+// администратору (comment), решение (resolution и необязательная новая оценка new_grade).
+type GradeAppealAction struct {
+	AppealID    int    `json:"appeal_id"`
+	ProfessorID int    `json:"professor_id,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+	Resolution  string `json:"resolution,omitempty"`
+	NewGrade    string `json:"new_grade,omitempty"`
+}
@@ -0,0 +1,9 @@
+package model
+
+type GradeCategory struct {
+	ID           int     `json:"id"`
+	SubjectID    int     `json:"subject_id"`
+	CategoryName string  `json:"category_name"`
+	Weight       float64 `json:"weight"`
+	DropLowest   int     `json:"drop_lowest"`
+}
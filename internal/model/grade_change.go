@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// GradeChange - запись истории изменения оценки или отметки посещаемости. Пустое значение означает,
+// что оценки (отметки) не было (при добавлении) или она удалена.
+type GradeChange struct {
+	ID             int       `json:"id"`
+	GradeKind      string    `json:"grade_kind"`
+	GradeID        int       `json:"grade_id"`
+	StudentID      int       `json:"student_id"`
+	SubjectID      int       `json:"subject_id"`
+	OldValue       string    `json:"old_value"`
+	NewValue       string    `json:"new_value"`
+	ActorID        int       `json:"actor_id"`
+	ActorFirstname string    `json:"actor_firstname,omitempty"`
+	ActorLastname  string    `json:"actor_lastname,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+	Reason         string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// GradeLock - итоговая ведомость группы по предмету. IsLocked = false, пока действует разблокировка.
+type GradeLock struct {
+	ID            int        `json:"id,omitempty"`
+	GroupID       int        `json:"group_id"`
+	GroupName     string     `json:"group_name,omitempty"`
+	SubjectID     int        `json:"subject_id"`
+	SubjectName   string     `json:"subject_name,omitempty"`
+	LockedBy      int        `json:"locked_by,omitempty"`
+	LockedAt      time.Time  `json:"locked_at"`
+	UnlockedUntil *time.Time `json:"unlocked_until,omitempty"`
+	IsLocked      bool       `json:"is_locked"`
+}
+
+// GradeUnlock - запись журнала разблокировок. При запросе разблокировки передаются
+// group_id, subject_id, duration_minutes и reason.
+type GradeUnlock struct {
+	ID              int       `json:"id,omitempty"`
+	GroupID         int       `json:"group_id"`
+	SubjectID       int       `json:"subject_id"`
+	AdminID         int       `json:"admin_id,omitempty"`
+	AdminFirstname  string    `json:"admin_firstname,omitempty"`
+	AdminLastname   string    `json:"admin_lastname,omitempty"`
+	Reason          string    `json:"reason"`
+	DurationMinutes int       `json:"duration_minutes,omitempty"`
+	UnlockedAt      time.Time `json:"unlocked_at"`
+	UnlockedUntil   time.Time `json:"unlocked_until"`
+}
@@ -0,0 +1,47 @@
+package model
+
+// GradeStatistics - сводка оценок по одной группировке (группа, предмет, преподаватель или семестр).
+// Из полей группировки заполнено только то, по которому сгруппирована выборка.
+// Среднее, медиана и отклонение считаются по оценкам за занятия, процент сдачи - по итоговым оценкам.
+type GradeStatistics struct {
+	GroupID       int    `json:"group_id,omitempty"`
+	GroupName     string `json:"group_name,omitempty"`
+	SubjectID     int    `json:"subject_id,omitempty"`
+	SubjectName   string `json:"subject_name,omitempty"`
+	ProfessorID   int    `json:"professor_id,omitempty"`
+	ProfessorName string `json:"professor_name,omitempty"`
+	TermID        int    `json:"term_id,omitempty"`
+	TermName      string `json:"term_name,omitempty"`
+
+	GradeCount int        `json:"grade_count"`
+	Mean       float64    `json:"mean"`
+	Median     float64    `json:"median"`
+	StdDev     float64    `json:"std_dev"`
+	Histogram  []GradeBin `json:"histogram"`
+
+	LessonCount    int     `json:"lesson_count"`
+	AttendedCount  int     `json:"attended_count"`
+	AttendanceRate float64 `json:"attendance_rate"`
+
+	TotalGradeCount     int        `json:"total_grade_count"`
+	PassedCount         int        `json:"passed_count"`
+	PassRate            float64    `json:"pass_rate"`
+	TotalGradeHistogram []GradeBin `json:"total_grade_histogram"`
+
+	// Заполняются при сравнении групп: отклонение от показателей всех групп по предмету
+	MeanDelta     *float64 `json:"mean_delta,omitempty"`
+	PassRateDelta *float64 `json:"pass_rate_delta,omitempty"`
+}
+
+type GradeBin struct {
+	Grade string `json:"grade"`
+	Count int    `json:"count"`
+}
+
+// GroupComparison - сравнение групп, изучающих один предмет
+type GroupComparison struct {
+	SubjectID   int               `json:"subject_id"`
+	SubjectName string            `json:"subject_name"`
+	Overall     GradeStatistics   `json:"overall"`
+	Groups      []GradeStatistics `json:"groups"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Gradebook - матрица студенты × занятия по одному предмету группы.
+// Lessons описывает столбцы, Cells каждой строки идут в том же порядке.
+type Gradebook struct {
+	GroupID     int               `json:"group_id"`
+	GroupName   string            `json:"group_name"`
+	SubjectID   int               `json:"subject_id"`
+	SubjectName string            `json:"subject_name"`
+	LessonCount int               `json:"lesson_count"`
+	Lessons     []GradebookLesson `json:"lessons"`
+	Rows        []GradebookRow    `json:"rows"`
+}
+
+// GradebookLesson - столбец журнала. Столбец занятия расписания задаётся LessonID,
+// столбец старых оценок без занятия - датой Date (LessonID = 0).
+// Если у студента несколько оценок за занятие, занятие занимает несколько столбцов с Ordinal 1, 2, ...
+type GradebookLesson struct {
+	LessonID int        `json:"lesson_id,omitempty"`
+	Date     time.Time  `json:"date"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	Ordinal  int        `json:"ordinal"`
+}
+
+type GradebookRow struct {
+	StudentID           int              `json:"student_id"`
+	StudentFirstname    string           `json:"student_firstname"`
+	StudentLastname     string           `json:"student_lastname"`
+	StudentSubgroupID   int              `json:"student_subgroup_id,omitempty"`
+	StudentSubgroupName string           `json:"student_subgroup_name,omitempty"`
+	Cells               []*GradebookCell `json:"cells"` // nil, если у студента нет записи за занятие
+	AverageGrade        float64          `json:"average_grade"`
+	AttendanceRate      float64          `json:"attendance_rate"`
+	TotalGrade          string           `json:"total_grade"`
+}
+
+// GradebookCell - оценка и посещаемость студента за занятие. ID = 0, если записи об оценке нет,
+// Grade = nil, если в записи нет оценки.
+// AttendanceStatus - отметка посещаемости занятия, пустая у столбцов без занятия и у занятий без отметки.
+type GradebookCell struct {
+	ID               int        `json:"id"`
+	Grade            *int       `json:"grade"`
+	AttendanceStatus string     `json:"attendance_status,omitempty"`
+	LessonDate       *time.Time `json:"lesson_date,omitempty"`
+	Description      string     `json:"description,omitempty"`
+	RunningAverage   float64    `json:"running_average"`
+}
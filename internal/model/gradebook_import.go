@@ -0,0 +1,30 @@
+package model
+
+// GradebookImportResult - результат импорта журнала. При dry_run изменения только перечисляются.
+// Изменения применяются, только если нет ни конфликтов, ни ошибок.
+type GradebookImportResult struct {
+	DryRun    bool                     `json:"dry_run"`
+	Applied   bool                     `json:"applied"`
+	Changes   []GradebookChange        `json:"changes"`
+	Conflicts []GradebookImportProblem `json:"conflicts"`
+	Errors    []GradebookImportProblem `json:"errors"`
+}
+
+// GradebookChange - изменение одной ячейки журнала. Action: insert, update или delete для оценки,
+// attendance для отметки посещаемости. Column - lesson_<id> или date_<YYYY-MM-DD> для занятия, total_grade для итоговой.
+type GradebookChange struct {
+	Row       int    `json:"row"`
+	StudentID int    `json:"student_id"`
+	Column    string `json:"column"`
+	Action    string `json:"action"`
+	OldValue  string `json:"old_value,omitempty"`
+	NewValue  string `json:"new_value,omitempty"`
+}
+
+// GradebookImportProblem - конфликт или ошибка в строке файла. Row - номер строки файла, начиная с 1.
+type GradebookImportProblem struct {
+	Row       int    `json:"row"`
+	StudentID int    `json:"student_id,omitempty"`
+	Column    string `json:"column,omitempty"`
+	Error     string `json:"error"`
+}
@@ -0,0 +1,14 @@
+package model
+
+type GradingScale struct {
+	ID               int                 `json:"id"`
+	ScaleName        string              `json:"scale_name"`
+	PassingThreshold float64             `json:"passing_threshold"`
+	IsPassFail       bool                `json:"is_pass_fail"` // зачёт/незачёт, такие оценки не входят в GPA
+	Values           []GradingScaleValue `json:"values"`
+}
+
+type GradingScaleValue struct {
+	Value        string  `json:"value"`
+	NumericValue float64 `json:"numeric_value"`
+}
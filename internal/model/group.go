@@ -3,4 +3,15 @@ package model
 type Group struct {
 	ID        int    `json:"id"`
 	GroupName string `json:"group_name"`
+	GroupKind string `json:"group_kind,omitempty"`
+	CuratorID int    `json:"curator_id,omitempty"`
+	// Заполняются для связей professor_group, ограниченных подгруппой
+	SubgroupID   int    `json:"subgroup_id,omitempty"`
+	SubgroupName string `json:"subgroup_name,omitempty"`
+}
+
+// GroupCurator - назначение куратора группы. CuratorID = 0 снимает куратора.
+type GroupCurator struct {
+	GroupID   int `json:"group_id"`
+	CuratorID int `json:"curator_id"`
 }
@@ -5,4 +5,6 @@ type IUDProfessorGroup struct {
 	GroupID        int `json:"group_id"`
 	OldProfessorID int `json:"old_professor_id,omitempty"`
 	OldGroupID     int `json:"old_group_id,omitempty"`
+	SubgroupID     int `json:"subgroup_id,omitempty"`
+	OldSubgroupID  int `json:"old_subgroup_id,omitempty"`
 }
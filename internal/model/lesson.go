@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// Lesson - занятие группы (или подгруппы, если SubgroupID указан) по предмету.
+// Kind - lesson (занятие) или exam (экзамен), по умолчанию lesson.
+// TimetableRuleID указан у занятий, созданных правилом расписания.
+// Sequence увеличивается при каждом изменении и отмене занятия.
+// Attendance и Grades заполняются только при запросе одного занятия.
+type Lesson struct {
+	ID                 int                `json:"id"`
+	SubjectID          int                `json:"subject_id"`
+	SubjectName        string             `json:"subject_name,omitempty"`
+	GroupID            int                `json:"group_id"`
+	GroupName          string             `json:"group_name,omitempty"`
+	SubgroupID         int                `json:"subgroup_id,omitempty"`
+	ProfessorID        int                `json:"professor_id,omitempty"`
+	ProfessorFirstname string             `json:"professor_firstname,omitempty"`
+	ProfessorLastname  string             `json:"professor_lastname,omitempty"`
+	RoomID             int                `json:"room_id,omitempty"`
+	RoomName           string             `json:"room_name,omitempty"`
+	StartsAt           time.Time          `json:"starts_at"`
+	EndsAt             time.Time          `json:"ends_at"`
+	Topic              string             `json:"topic,omitempty"`
+	Kind               string             `json:"kind"`
+	TimetableRuleID    int                `json:"timetable_rule_id,omitempty"`
+	Cancelled          bool               `json:"cancelled,omitempty"`
+	Sequence           int                `json:"sequence"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	Attendance         []LessonAttendance `json:"attendance,omitempty"`
+	Grades             []StudentGrade     `json:"grades,omitempty"`
+}
+
+// LessonAttendance - отметка студента на занятии. Status: present, late, excused, left_early, remote или absent.
+// Status пуст, если отметки ещё нет.
+type LessonAttendance struct {
+	LessonID         int        `json:"lesson_id,omitempty"`
+	StudentID        int        `json:"student_id"`
+	StudentFirstname string     `json:"student_firstname,omitempty"`
+	StudentLastname  string     `json:"student_lastname,omitempty"`
+	Status           string     `json:"status"`
+	MinutesLate      int        `json:"minutes_late,omitempty"`
+	Note             string     `json:"note,omitempty"`
+	RecordedBy       int        `json:"recorded_by,omitempty"`
+	RecordedAt       *time.Time `json:"recorded_at,omitempty"`
+}
+
+// LessonAttendanceEntry - посещаемость занятия для многих студентов сразу
+type LessonAttendanceEntry struct {
+	LessonID   int                `json:"lesson_id"`
+	Attendance []LessonAttendance `json:"attendance"`
+}
+
+// StudentLessonAttendance - занятие и посещаемость его студентом. Status пуст, если отметки ещё нет.
+type StudentLessonAttendance struct {
+	LessonID    int       `json:"lesson_id"`
+	SubjectID   int       `json:"subject_id"`
+	SubjectName string    `json:"subject_name"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Topic       string    `json:"topic,omitempty"`
+	Status      string    `json:"status"`
+	MinutesLate int       `json:"minutes_late,omitempty"`
+	Note        string    `json:"note,omitempty"`
+}
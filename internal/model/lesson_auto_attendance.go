@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// LessonAutoAttendance - отметка студента, предложенная по его подключениям к комнате LiveKit занятия.
+// Status и MinutesLate - предложение, RecordedStatus - уже записанная отметка (пуст, если её нет).
+type LessonAutoAttendance struct {
+	StudentID        int        `json:"student_id"`
+	StudentFirstname string     `json:"student_firstname"`
+	StudentLastname  string     `json:"student_lastname"`
+	FirstJoinedAt    *time.Time `json:"first_joined_at,omitempty"`
+	LastLeftAt       *time.Time `json:"last_left_at,omitempty"`
+	InRoom           bool       `json:"in_room"`
+	MinutesInRoom    int        `json:"minutes_in_room"`
+	PresencePercent  float64    `json:"presence_percent"`
+	Status           string     `json:"status"`
+	MinutesLate      int        `json:"minutes_late,omitempty"`
+	RecordedStatus   string     `json:"recorded_status,omitempty"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Notification - уведомление пользователя. ReadAt = nil, пока уведомление не прочитано.
+type Notification struct {
+	ID        int        `json:"id"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// NotificationsRead - уведомления, отмечаемые прочитанными. Пустой IDs отмечает все уведомления.
+type NotificationsRead struct {
+	IDs []int `json:"ids"`
+}
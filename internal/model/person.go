@@ -11,6 +11,7 @@ type Person struct {
 	Email       string    `json:"email"`
 	PhoneNumber string    `json:"phone_number"`
 	GroupID     int       `json:"group_id"`
+	SubgroupID  int       `json:"subgroup_id,omitempty"`
 	IsProfessor bool      `json:"is_professor"`
 	IsAdmin     bool      `json:"is_admin"`
 	Sex         string    `json:"sex"`
@@ -0,0 +1,42 @@
+package model
+
+// GroupRanking - рейтинг студентов группы по предмету. RankBy: weighted, total или attendance.
+// Студенты без оценок (или без посещений) в рейтинг не входят и учтены в UnrankedCount.
+type GroupRanking struct {
+	GroupID       int            `json:"group_id"`
+	SubjectID     int            `json:"subject_id"`
+	RankBy        string         `json:"rank_by"`
+	RankedCount   int            `json:"ranked_count"`
+	UnrankedCount int            `json:"unranked_count"`
+	Entries       []RankingEntry `json:"entries"`
+}
+
+// RankingEntry - место студента. Равные значения делят место, следующее место пропускается (1, 2, 2, 4).
+// Percentile - доля студентов с меньшим значением плюс половина равных, в процентах.
+type RankingEntry struct {
+	Rank             int     `json:"rank"`
+	StudentID        int     `json:"student_id"`
+	StudentFirstname string  `json:"student_firstname"`
+	StudentLastname  string  `json:"student_lastname"`
+	Value            float64 `json:"value"`
+	Percentile       float64 `json:"percentile"`
+	IsTied           bool    `json:"is_tied,omitempty"`
+}
+
+// StudentStanding - место студента в рейтинге группы без данных других студентов
+type StudentStanding struct {
+	SubjectID   int     `json:"subject_id"`
+	RankBy      string  `json:"rank_by"`
+	Ranked      bool    `json:"ranked"`
+	Rank        int     `json:"rank,omitempty"`
+	RankedCount int     `json:"ranked_count"`
+	Value       float64 `json:"value,omitempty"`
+	Percentile  float64 `json:"percentile,omitempty"`
+	IsTied      bool    `json:"is_tied,omitempty"`
+}
+
+// RankingVisibility - тело запроса администратора, открывающего или закрывающего рейтинг предмета студентам
+type RankingVisibility struct {
+	SubjectID      int  `json:"subject_id"`
+	RankingVisible bool `json:"ranking_visible"`
+}
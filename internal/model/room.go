@@ -4,5 +4,6 @@ type Room struct {
 	ID          int    `json:"id,omitempty"`
 	SubjectID   int    `json:"subject_id"`
 	SubjectName string `json:"subject_name,omitempty"`
+	SubgroupID  int    `json:"subgroup_id,omitempty"`
 	RoomName    string `json:"room_name"`
 }
@@ -1,14 +1,26 @@
 package model
 
+import "time"
+
 type StudentGrade struct {
-	ID               int    `json:"id,omitempty"`
-	StudentID        int    `json:"student_id,omitempty"`
-	StudentFirstname string `json:"student_firstname,omitempty"`
-	StudentLastname  string `json:"student_lastname,omitempty"`
-	StudentGroupID   int    `json:"student_group_id,omitempty"`
-	StudentGroupName string `json:"student_group_name,omitempty"`
-	SubjectID        int    `json:"subject_id"`
-	SubjectName      string `json:"subject_name,omitempty"`
-	Grade            int    `json:"grade"`
-	HasAttended      bool   `json:"has_attended"`
+	ID                  int        `json:"id,omitempty"`
+	StudentID           int        `json:"student_id,omitempty"`
+	StudentFirstname    string     `json:"student_firstname,omitempty"`
+	StudentLastname     string     `json:"student_lastname,omitempty"`
+	StudentGroupID      int        `json:"student_group_id,omitempty"`
+	StudentGroupName    string     `json:"student_group_name,omitempty"`
+	StudentSubgroupID   int        `json:"student_subgroup_id,omitempty"`
+	StudentSubgroupName string     `json:"student_subgroup_name,omitempty"`
+	SubjectID           int        `json:"subject_id"`
+	SubjectName         string     `json:"subject_name,omitempty"`
+	CategoryID          int        `json:"category_id,omitempty"`
+	CategoryName        string     `json:"category_name,omitempty"`
+	LessonID            int        `json:"lesson_id,omitempty"`         // занятие, за которое поставлена оценка
+	Grade               *int       `json:"grade"`                       // nil - оценки нет
+	AttendanceStatus    string     `json:"attendance_status,omitempty"` // отметка посещаемости занятия lesson_id из lesson_attendance
+	GradedAt            *time.Time `json:"graded_at,omitempty"`         // по умолчанию время добавления оценки
+	LessonDate          *time.Time `json:"lesson_date,omitempty"`       // дата занятия, за которое поставлена оценка
+	Description         string     `json:"description,omitempty"`
+	ProfessorComment    string     `json:"professor_comment,omitempty"`
+	Reason              string     `json:"reason,omitempty"` // причина изменения, обязательна после окончания семестра
 }
@@ -0,0 +1,6 @@
+package model
+
+type StudentSubgroup struct {
+	StudentID  int `json:"student_id"`
+	SubgroupID int `json:"subgroup_id"`
+}
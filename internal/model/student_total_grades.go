@@ -1,13 +1,19 @@
 package model
 
 type StudentTotalGrade struct {
-	ID               int    `json:"id,omitempty"`
-	StudentID        int    `json:"student_id,omitempty"`
-	StudentFirstname string `json:"student_firstname,omitempty"`
-	StudentLastname  string `json:"student_lastname,omitempty"`
-	StudentGroupID   int    `json:"student_group_id,omitempty"`
-	StudentGroupName string `json:"student_group_name,omitempty"`
-	SubjectID        int    `json:"subject_id"`
-	SubjectName      string `json:"subject_name,omitempty"`
-	Grade            string `json:"grade"`
+	ID                  int    `json:"id,omitempty"`
+	StudentID           int    `json:"student_id,omitempty"`
+	StudentFirstname    string `json:"student_firstname,omitempty"`
+	StudentLastname     string `json:"student_lastname,omitempty"`
+	StudentGroupID      int    `json:"student_group_id,omitempty"`
+	StudentGroupName    string `json:"student_group_name,omitempty"`
+	StudentSubgroupID   int    `json:"student_subgroup_id,omitempty"`
+	StudentSubgroupName string `json:"student_subgroup_name,omitempty"`
+	SubjectID           int    `json:"subject_id"`
+	SubjectName         string `json:"subject_name,omitempty"`
+	TermID              int    `json:"term_id,omitempty"`
+	Grade               string `json:"grade"`
+	IsOverride          bool   `json:"is_override,omitempty"`
+	OverrideReason      string `json:"override_reason,omitempty"`
+	Reason              string `json:"reason,omitempty"` // причина изменения, обязательна после окончания семестра
 }
@@ -0,0 +1,8 @@
+package model
+
+type Subgroup struct {
+	ID           int    `json:"id"`
+	GroupID      int    `json:"group_id"`
+	GroupName    string `json:"group_name,omitempty"`
+	SubgroupName string `json:"subgroup_name"`
+}
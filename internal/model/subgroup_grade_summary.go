@@ -0,0 +1,12 @@
+package model
+
+type SubgroupGradeSummary struct {
+	SubgroupID     int     `json:"subgroup_id"`
+	SubgroupName   string  `json:"subgroup_name"`
+	SubjectID      int     `json:"subject_id"`
+	SubjectName    string  `json:"subject_name"`
+	StudentCount   int     `json:"student_count"`
+	GradeCount     int     `json:"grade_count"`
+	AverageGrade   float64 `json:"average_grade"`
+	AttendanceRate float64 `json:"attendance_rate"`
+}
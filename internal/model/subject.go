@@ -1,6 +1,13 @@
 package model
 
 type Subject struct {
-	ID          int    `json:"id"`
-	SubjectName string `json:"subject_name"`
+	ID                int     `json:"id"`
+	SubjectName       string  `json:"subject_name"`
+	SubjectCode       string  `json:"subject_code,omitempty"`
+	Credits           float64 `json:"credits,omitempty"`
+	GradingScaleID    int     `json:"grading_scale_id,omitempty"`
+	GradingScaleName  string  `json:"grading_scale_name,omitempty"`
+	GradingMethod     string  `json:"grading_method,omitempty"`
+	AttendancePenalty float64 `json:"attendance_penalty,omitempty"`
+	RankingVisible    bool    `json:"ranking_visible"`
 }
@@ -0,0 +1,9 @@
+package model
+
+type SubjectPrerequisite struct {
+	SubjectID        int     `json:"subject_id"`
+	SubjectName      string  `json:"subject_name,omitempty"`
+	PrerequisiteID   int     `json:"prerequisite_id"`
+	PrerequisiteName string  `json:"prerequisite_name,omitempty"`
+	MinGrade         float64 `json:"min_grade"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+type Term struct {
+	ID        int       `json:"id"`
+	TermName  string    `json:"term_name"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// TimetableRule - повторяющееся занятие расписания. Первое занятие FirstStartsAt-FirstEndsAt повторяется
+// каждые IntervalWeeks недель до RepeatUntil включительно в то же время по часам часового пояса Timezone.
+// Lessons - число занятий, созданных правилом.
+type TimetableRule struct {
+	ID            int       `json:"id"`
+	SubjectID     int       `json:"subject_id"`
+	SubjectName   string    `json:"subject_name,omitempty"`
+	GroupID       int       `json:"group_id"`
+	GroupName     string    `json:"group_name,omitempty"`
+	SubgroupID    int       `json:"subgroup_id,omitempty"`
+	ProfessorID   int       `json:"professor_id,omitempty"`
+	RoomID        int       `json:"room_id,omitempty"`
+	FirstStartsAt time.Time `json:"first_starts_at"`
+	FirstEndsAt   time.Time `json:"first_ends_at"`
+	IntervalWeeks int       `json:"interval_weeks"`
+	RepeatUntil   time.Time `json:"repeat_until"`
+	Timezone      string    `json:"timezone"`
+	Topic         string    `json:"topic,omitempty"`
+	Lessons       int       `json:"lessons"`
+}
+
+// TimetableConflict - занятие Lesson, занимающее того же преподавателя, группу или аудиторию (Kind)
+// в то же время, что и проверяемое занятие StartsAt-EndsAt.
+type TimetableConflict struct {
+	Kind     string    `json:"kind"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Lesson   Lesson    `json:"lesson"`
+}
+
+// TimetableDay - занятия одного дня расписания, Date в формате YYYY-MM-DD
+type TimetableDay struct {
+	Date    string   `json:"date"`
+	Lessons []Lesson `json:"lessons"`
+}
+
+// Timetable - расписание на день или неделю с понедельника по воскресенье
+type Timetable struct {
+	From string         `json:"from"`
+	To   string         `json:"to"`
+	Days []TimetableDay `json:"days"`
+}
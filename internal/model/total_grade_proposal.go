@@ -0,0 +1,23 @@
+package model
+
+// TotalGradeProposal - итоговая оценка, предложенная сервером по оценкам за занятия
+type TotalGradeProposal struct {
+	StudentID        int     `json:"student_id"`
+	StudentFirstname string  `json:"student_firstname"`
+	StudentLastname  string  `json:"student_lastname"`
+	SubjectID        int     `json:"subject_id"`
+	GradingMethod    string  `json:"grading_method"`
+	Score            float64 `json:"score"`
+	Absences         int     `json:"absences"`
+	ProposedGrade    string  `json:"proposed_grade"` // пусто, если у студента нет оценок
+	CurrentGrade     string  `json:"current_grade"`
+	IsOverride       bool    `json:"is_override"`
+}
+
+type AcceptTotalGradeProposals struct {
+	GroupID    int    `json:"group_id"`
+	SubjectID  int    `json:"subject_id"`
+	TermID     int    `json:"term_id,omitempty"`
+	StudentIDs []int  `json:"student_ids,omitempty"` // пусто - принять предложения для всей группы
+	Reason     string `json:"reason,omitempty"`      // обязательна после окончания семестра
+}
@@ -0,0 +1,37 @@
+package model
+
+// Transcript - выписка итоговых оценок студента. Кредиты считаются только по сданным предметам,
+// AttemptedCredits - по всем. GPA - средний процент оценки от максимума шкалы предмета,
+// взвешенный кредитами; зачётные шкалы в GPA не входят.
+type Transcript struct {
+	StudentID        int              `json:"student_id"`
+	StudentFirstname string           `json:"student_firstname"`
+	StudentLastname  string           `json:"student_lastname"`
+	StudentGroupName string           `json:"student_group_name"`
+	Terms            []TranscriptTerm `json:"terms"`
+	TotalCredits     float64          `json:"total_credits"`
+	AttemptedCredits float64          `json:"attempted_credits"`
+	GPA              float64          `json:"gpa"`
+}
+
+// TranscriptTerm - итоговые оценки за один семестр. TermID = 0 для оценок без семестра.
+type TranscriptTerm struct {
+	TermID                     int               `json:"term_id"`
+	TermName                   string            `json:"term_name"`
+	Entries                    []TranscriptEntry `json:"entries"`
+	TermCredits                float64           `json:"term_credits"`
+	TermAttemptedCredits       float64           `json:"term_attempted_credits"`
+	TermGPA                    float64           `json:"term_gpa"`
+	CumulativeCredits          float64           `json:"cumulative_credits"`
+	CumulativeAttemptedCredits float64           `json:"cumulative_attempted_credits"`
+	CumulativeGPA              float64           `json:"cumulative_gpa"`
+}
+
+type TranscriptEntry struct {
+	SubjectID   int     `json:"subject_id"`
+	SubjectCode string  `json:"subject_code"`
+	SubjectName string  `json:"subject_name"`
+	Credits     float64 `json:"credits"`
+	Grade       string  `json:"grade"`
+	Passed      bool    `json:"passed"`
+}
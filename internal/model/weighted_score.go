@@ -0,0 +1,20 @@
+package model
+
+// WeightedScore - взвешенный балл студента по предмету с разбивкой по категориям
+type WeightedScore struct {
+	StudentID        int             `json:"student_id"`
+	StudentFirstname string          `json:"student_firstname"`
+	StudentLastname  string          `json:"student_lastname"`
+	SubjectID        int             `json:"subject_id"`
+	Score            float64         `json:"score"`
+	Categories       []CategoryScore `json:"categories"`
+}
+
+type CategoryScore struct {
+	CategoryID   int     `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Weight       float64 `json:"weight"`
+	GradeCount   int     `json:"grade_count"`
+	DroppedCount int     `json:"dropped_count"`
+	Average      float64 `json:"average"`
+}
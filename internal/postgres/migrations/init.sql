@@ -12,7 +12,27 @@ CREATE SCHEMA IF NOT EXISTS public;
 
 CREATE TABLE IF NOT EXISTS group_uni (
     id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
-    group_name VARCHAR(255)
+    group_name VARCHAR(255),
+    group_kind VARCHAR(20) NOT NULL DEFAULT 'student',
+    CHECK (group_kind IN ('student', 'staff', 'system'))
+);
+
+-- Служебные группы для администраторов и преподавателей
+INSERT INTO group_uni (group_name, group_kind)
+SELECT 'Administrators', 'system'
+WHERE NOT EXISTS (SELECT FROM group_uni WHERE group_kind = 'system');
+
+INSERT INTO group_uni (group_name, group_kind)
+SELECT 'Professors', 'staff'
+WHERE NOT EXISTS (SELECT FROM group_uni WHERE group_kind = 'staff');
+
+-- Подгруппы (например, половины группы на лабораторных работах)
+CREATE TABLE IF NOT EXISTS subgroup (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    group_id INTEGER NOT NULL,
+    subgroup_name VARCHAR(255),
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    UNIQUE (group_id, subgroup_name)
 );
 
 CREATE TABLE IF NOT EXISTS person (
@@ -24,23 +44,86 @@ CREATE TABLE IF NOT EXISTS person (
     email VARCHAR(255),
     phone_number VARCHAR(20),
     group_id INTEGER,
+    subgroup_id INTEGER,
     is_professor BOOL NOT NULL,
     is_admin BOOL NOT NULL,
     sex VARCHAR(10),
     birthdate DATE,
-    FOREIGN KEY (group_id) REFERENCES group_uni(id)
+    FOREIGN KEY (group_id) REFERENCES group_uni(id),
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE SET NULL
 );
 
+-- Шкалы оценивания: допустимые итоговые оценки, их числовые эквиваленты и порог сдачи
+-- Оценки по зачётным шкалам (is_pass_fail) в GPA не входят.
+CREATE TABLE IF NOT EXISTS grading_scale (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    scale_name VARCHAR(255) NOT NULL UNIQUE,
+    passing_threshold NUMERIC(6, 2) NOT NULL,
+    is_pass_fail BOOL NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS grading_scale_value (
+    scale_id INTEGER NOT NULL,
+    value VARCHAR(50) NOT NULL,
+    numeric_value NUMERIC(6, 2) NOT NULL,
+    FOREIGN KEY (scale_id) REFERENCES grading_scale(id) ON DELETE CASCADE,
+    PRIMARY KEY (scale_id, value)
+);
+
+INSERT INTO grading_scale (scale_name, passing_threshold, is_pass_fail)
+VALUES ('5-point', 3, false), ('100-point', 60, false), ('letter', 1, false), ('pass/fail', 1, true)
+ON CONFLICT (scale_name) DO NOTHING;
+
+INSERT INTO grading_scale_value (scale_id, value, numeric_value)
+SELECT gs.id, v.value, v.numeric_value
+FROM grading_scale gs
+JOIN (VALUES
+    ('5-point', '2', 2), ('5-point', '3', 3), ('5-point', '4', 4), ('5-point', '5', 5),
+    ('letter', 'A', 4), ('letter', 'B', 3), ('letter', 'C', 2), ('letter', 'D', 1), ('letter', 'F', 0),
+    ('pass/fail', 'pass', 1), ('pass/fail', 'fail', 0)
+) AS v (scale_name, value, numeric_value) ON gs.scale_name = v.scale_name
+ON CONFLICT DO NOTHING;
+
+INSERT INTO grading_scale_value (scale_id, value, numeric_value)
+SELECT gs.id, n::text, n
+FROM grading_scale gs, generate_series(0, 100) AS n
+WHERE gs.scale_name = '100-point'
+ON CONFLICT DO NOTHING;
+
+-- Без явного указания предмету назначается шкала 5-point.
+-- grading_method задаёт, как предлагается итоговая оценка: среднее, взвешенный балл по категориям
+-- или среднее за вычетом attendance_penalty за каждый пропуск.
 CREATE TABLE IF NOT EXISTS subject (
     id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
-    subject_name VARCHAR(255)
+    subject_name VARCHAR(255),
+    subject_code VARCHAR(20) UNIQUE,
+    credits NUMERIC(4, 1) NOT NULL DEFAULT 0,
+    grading_scale_id INTEGER NOT NULL,
+    grading_method VARCHAR(20) NOT NULL DEFAULT 'average',
+    attendance_penalty NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    ranking_visible BOOLEAN NOT NULL DEFAULT false, -- видят ли студенты своё место в рейтинге группы
+    FOREIGN KEY (grading_scale_id) REFERENCES grading_scale(id) ON DELETE RESTRICT,
+    CHECK (credits >= 0),
+    CHECK (grading_method IN ('average', 'weighted', 'attendance_penalized')),
+    CHECK (attendance_penalty >= 0)
+);
+
+-- Учебные периоды (семестры)
+CREATE TABLE IF NOT EXISTS term (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    term_name VARCHAR(255) NOT NULL UNIQUE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    CHECK (start_date <= end_date)
 );
 
 CREATE TABLE IF NOT EXISTS room (
     id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
     subject_id INTEGER,
+    subgroup_id INTEGER,
     room_name VARCHAR(255),
     FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE CASCADE,
     UNIQUE (subject_id, room_name)
 );
 
@@ -60,30 +143,447 @@ CREATE TABLE IF NOT EXISTS professor_subject (
     PRIMARY KEY (professor_id, subject_id)
 );
 
+-- subgroup_id IS NULL означает, что преподаватель ведёт всю группу
 CREATE TABLE IF NOT EXISTS professor_group (
-    professor_id INTEGER,
-    group_id INTEGER,
+    professor_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    subgroup_id INTEGER,
     FOREIGN KEY (professor_id) REFERENCES person(id) ON DELETE CASCADE,
     FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
-    PRIMARY KEY (professor_id, group_id)
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE CASCADE,
+    UNIQUE NULLS NOT DISTINCT (professor_id, group_id, subgroup_id)
+);
+
+-- Куратор группы - преподаватель, отвечающий за группу, у группы не больше одного куратора
+CREATE TABLE IF NOT EXISTS group_curator (
+    group_id INTEGER PRIMARY KEY,
+    curator_id INTEGER NOT NULL,
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    FOREIGN KEY (curator_id) REFERENCES person(id) ON DELETE CASCADE
+);
+
+-- Повторяющееся занятие расписания: первое занятие повторяется каждые interval_weeks недель до repeat_until
+-- включительно, время начала сохраняется по часам часового пояса timezone. Занятия правила создаются
+-- при его добавлении, изменённые и удалённые занятия правила - исключения из него.
+CREATE TABLE IF NOT EXISTS timetable_rule (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subject_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    subgroup_id INTEGER,
+    professor_id INTEGER,
+    room_id INTEGER,
+    first_starts_at TIMESTAMPTZ NOT NULL,
+    first_ends_at TIMESTAMPTZ NOT NULL,
+    interval_weeks INTEGER NOT NULL DEFAULT 1,
+    repeat_until DATE NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    topic VARCHAR(255),
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE CASCADE,
+    FOREIGN KEY (professor_id) REFERENCES person(id) ON DELETE SET NULL,
+    FOREIGN KEY (room_id) REFERENCES room(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES person(id) ON DELETE SET NULL,
+    CHECK (first_ends_at > first_starts_at),
+    CHECK (interval_weeks > 0)
+);
+
+-- Занятия: предмет, группа (или её подгруппа), преподаватель, аудитория и время
+-- cancelled_at - время отмены занятия, отменённые занятия остаются в календарях как отменённые события.
+-- sequence увеличивается при каждом изменении и отмене занятия, как SEQUENCE события iCalendar.
+CREATE TABLE IF NOT EXISTS lesson (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subject_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    subgroup_id INTEGER,
+    professor_id INTEGER,
+    room_id INTEGER,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    topic VARCHAR(255),
+    kind VARCHAR(10) NOT NULL DEFAULT 'lesson', -- lesson - занятие, exam - экзамен
+    timetable_rule_id INTEGER,
+    cancelled_at TIMESTAMPTZ,
+    sequence INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE CASCADE,
+    FOREIGN KEY (professor_id) REFERENCES person(id) ON DELETE SET NULL,
+    FOREIGN KEY (room_id) REFERENCES room(id) ON DELETE SET NULL,
+    FOREIGN KEY (timetable_rule_id) REFERENCES timetable_rule(id) ON DELETE SET NULL,
+    CHECK (ends_at > starts_at),
+    CHECK (kind IN ('lesson', 'exam'))
+);
+
+CREATE INDEX IF NOT EXISTS lesson_group_starts_at_idx ON lesson (group_id, starts_at);
+CREATE INDEX IF NOT EXISTS lesson_room_starts_at_idx ON lesson (room_id, starts_at);
+CREATE INDEX IF NOT EXISTS lesson_professor_starts_at_idx ON lesson (professor_id, starts_at);
+CREATE INDEX IF NOT EXISTS lesson_timetable_rule_idx ON lesson (timetable_rule_id);
+
+-- Задание по предмету для группы или подгруппы со сроком сдачи due_at.
+-- Отменённое задание остаётся в календарях с пометкой отмены, sequence увеличивается при каждом изменении.
+CREATE TABLE IF NOT EXISTS assignment (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subject_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    subgroup_id INTEGER,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    due_at TIMESTAMPTZ NOT NULL,
+    created_by INTEGER,
+    cancelled_at TIMESTAMPTZ,
+    sequence INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    FOREIGN KEY (subgroup_id) REFERENCES subgroup(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES person(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS assignment_group_due_at_idx ON assignment (group_id, due_at);
+
+-- Секретная ссылка на календарь занятий пользователя в формате iCalendar.
+-- Новый токен отзывает старую ссылку.
+CREATE TABLE IF NOT EXISTS calendar_feed (
+    person_id INTEGER PRIMARY KEY,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (person_id) REFERENCES person(id) ON DELETE CASCADE
+);
+
+-- Посещаемость занятий, отдельно от оценок.
+-- minutes_late заполняется для опозданий, note - пояснение преподавателя
+CREATE TABLE IF NOT EXISTS lesson_attendance (
+    lesson_id INTEGER NOT NULL,
+    student_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    minutes_late INTEGER NOT NULL DEFAULT 0,
+    note TEXT,
+    recorded_by INTEGER,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (lesson_id) REFERENCES lesson(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (recorded_by) REFERENCES person(id) ON DELETE SET NULL,
+    PRIMARY KEY (lesson_id, student_id),
+    CHECK (status IN ('present', 'late', 'excused', 'left_early', 'remote', 'absent')),
+    CHECK (minutes_late >= 0)
+);
+
+-- Правила подсчёта посещаемости предмета. lates_per_absence = 3 - три опоздания считаются одним пропуском,
+-- 0 - опоздания не превращаются в пропуски. Уважительные пропуски без excused_counts_as_present
+-- не входят в число учитываемых занятий.
+-- late_after_minutes и min_presence_percent - пороги автоматической отметки по подключениям к LiveKit:
+-- подключившийся позже late_after_minutes опоздал, пробывший в комнате меньше min_presence_percent
+-- длительности занятия отсутствовал.
+-- alert_below_percent - порог посещаемости в процентах, ниже которого студент, куратор и преподаватели
+-- получают уведомление, 0 - без уведомлений.
+CREATE TABLE IF NOT EXISTS attendance_rule (
+    subject_id INTEGER PRIMARY KEY,
+    lates_per_absence INTEGER NOT NULL DEFAULT 0,
+    left_early_per_absence INTEGER NOT NULL DEFAULT 0,
+    remote_counts_as_present BOOL NOT NULL DEFAULT true,
+    excused_counts_as_present BOOL NOT NULL DEFAULT false,
+    late_after_minutes INTEGER NOT NULL DEFAULT 10,
+    min_presence_percent INTEGER NOT NULL DEFAULT 50,
+    alert_below_percent INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    CHECK (lates_per_absence >= 0),
+    CHECK (left_early_per_absence >= 0),
+    CHECK (late_after_minutes >= 0),
+    CHECK (min_presence_percent BETWEEN 0 AND 100),
+    CHECK (alert_below_percent BETWEEN 0 AND 100)
+);
+
+-- Студенты, чья посещаемость предмета ниже порога и о ком уже отправлено уведомление.
+-- Запись удаляется, когда посещаемость возвращается к порогу, чтобы следующее падение снова уведомило.
+CREATE TABLE IF NOT EXISTS attendance_alert (
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    attendance_percent NUMERIC(5, 2) NOT NULL,
+    alerted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    PRIMARY KEY (student_id, subject_id)
+);
+
+-- Подключения студентов к комнате LiveKit занятия по событиям вебхука LiveKit.
+-- left_at IS NULL - студент ещё в комнате
+CREATE TABLE IF NOT EXISTS lesson_participant_session (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    lesson_id INTEGER NOT NULL,
+    student_id INTEGER NOT NULL,
+    participant_sid VARCHAR(255) NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL,
+    left_at TIMESTAMPTZ,
+    FOREIGN KEY (lesson_id) REFERENCES lesson(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    UNIQUE (lesson_id, participant_sid)
+);
+
+CREATE INDEX IF NOT EXISTS lesson_participant_session_sid_idx ON lesson_participant_session (participant_sid);
+
+-- Окна самостоятельной отметки на очных занятиях. Код окна меняется каждые rotation_seconds секунд
+-- и вычисляется из secret, closed_at заполняется при закрытии окна
+CREATE TABLE IF NOT EXISTS lesson_check_in_window (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    lesson_id INTEGER NOT NULL,
+    secret BYTEA NOT NULL,
+    rotation_seconds INTEGER NOT NULL,
+    opened_by INTEGER,
+    opened_at TIMESTAMPTZ NOT NULL,
+    closes_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    FOREIGN KEY (lesson_id) REFERENCES lesson(id) ON DELETE CASCADE,
+    FOREIGN KEY (opened_by) REFERENCES person(id) ON DELETE SET NULL,
+    CHECK (rotation_seconds BETWEEN 10 AND 300),
+    CHECK (closes_at > opened_at)
+);
+
+-- У занятия открыто не больше одного окна
+CREATE UNIQUE INDEX IF NOT EXISTS lesson_check_in_window_open_idx ON lesson_check_in_window (lesson_id) WHERE closed_at IS NULL;
+
+-- Отметки студентов через окна, не больше одной на занятие.
+-- code_step - номер кода окна, которым студент отметился
+CREATE TABLE IF NOT EXISTS lesson_check_in (
+    lesson_id INTEGER NOT NULL,
+    student_id INTEGER NOT NULL,
+    window_id INTEGER NOT NULL,
+    code_step BIGINT NOT NULL,
+    checked_in_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (lesson_id) REFERENCES lesson(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (window_id) REFERENCES lesson_check_in_window(id) ON DELETE CASCADE,
+    PRIMARY KEY (lesson_id, student_id)
+);
+
+-- Заявления студентов об уважительной причине пропусков с date_from по date_to включительно.
+-- Подтверждающий документ хранится в базе, размер ограничивается при загрузке.
+CREATE TABLE IF NOT EXISTS absence_excuse (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    student_id INTEGER NOT NULL,
+    date_from DATE NOT NULL,
+    date_to DATE NOT NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    content BYTEA NOT NULL,
+    reviewed_by INTEGER,
+    review_comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reviewed_at TIMESTAMPTZ,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (reviewed_by) REFERENCES person(id) ON DELETE SET NULL,
+    CHECK (date_from <= date_to),
+    CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+-- Категории оценок предмета (лабораторные, домашние, экзамен) с весами.
+-- drop_lowest - сколько самых низких оценок категории не учитывать.
+CREATE TABLE IF NOT EXISTS grade_category (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subject_id INTEGER NOT NULL,
+    category_name VARCHAR(255) NOT NULL,
+    weight NUMERIC(6, 2) NOT NULL,
+    drop_lowest INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    UNIQUE (subject_id, category_name),
+    CHECK (weight > 0),
+    CHECK (drop_lowest >= 0)
 );
 
 CREATE TABLE IF NOT EXISTS student_grades (
     id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
     student_id INTEGER,
     subject_id INTEGER,
-    grade INTEGER DEFAULT 0,
-    has_attended BOOL DEFAULT true,
+    category_id INTEGER,
+    grade INTEGER, -- NULL - оценки нет, 0 - оценка шкалы (например, F или незачёт)
+    graded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    lesson_date DATE, -- дата занятия, за которое поставлена оценка
+    description VARCHAR(255),
+    professor_comment TEXT,
+    lesson_id INTEGER, -- у занятия может быть сколько угодно оценок
     FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
-    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES grade_category(id) ON DELETE SET NULL,
+    FOREIGN KEY (lesson_id) REFERENCES lesson(id) ON DELETE SET NULL
 );
 
+CREATE INDEX IF NOT EXISTS student_grades_student_subject_idx ON student_grades (student_id, subject_id, graded_at);
+CREATE INDEX IF NOT EXISTS student_grades_lesson_idx ON student_grades (lesson_id);
+
+-- Итоговая оценка студента по предмету за семестр. Пересдача в другом семестре хранится отдельной записью.
 CREATE TABLE IF NOT EXISTS student_total_grades (
     id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
     student_id INTEGER,
     subject_id INTEGER,
+    term_id INTEGER,
     grade VARCHAR(50),
+    is_override BOOL NOT NULL DEFAULT false,
+    override_reason TEXT,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (term_id) REFERENCES term(id) ON DELETE SET NULL,
+    UNIQUE NULLS NOT DISTINCT (student_id, subject_id, term_id)
+);
+
+-- История изменений оценок. Только добавление: записи не изменяются и не удаляются,
+-- поэтому внешних ключей на оценки и людей нет.
+-- grade_kind = 'lesson' для student_grades, 'total' для student_total_grades, 'attendance' для lesson_attendance
+-- (grade_id - занятие, значения - статусы отметки).
+CREATE TABLE IF NOT EXISTS grade_history (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    grade_kind VARCHAR(10) NOT NULL,
+    grade_id INTEGER NOT NULL,
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    old_value VARCHAR(50),
+    new_value VARCHAR(50),
+    actor_id INTEGER NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reason TEXT,
+    CHECK (grade_kind IN ('lesson', 'total', 'attendance'))
+);
+
+CREATE INDEX IF NOT EXISTS grade_history_student_subject_idx ON grade_history (student_id, subject_id);
+
+CREATE OR REPLACE FUNCTION grade_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER grade_history_append_only
+BEFORE UPDATE OR DELETE ON grade_history
+FOR EACH ROW EXECUTE FUNCTION grade_history_append_only();
+
+-- Итоговая ведомость группы по предмету. Пока запись есть, оценки студентов группы по предмету
+-- не меняются. unlocked_until - до какого момента действует разблокировка администратора.
+-- Оценки студентов из student_ids остаются закрытыми и после перевода в другую группу.
+CREATE TABLE IF NOT EXISTS grade_lock (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    group_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    locked_by INTEGER,
+    locked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    unlocked_until TIMESTAMPTZ,
+    student_ids INTEGER[] NOT NULL DEFAULT '{}',
+    FOREIGN KEY (group_id) REFERENCES group_uni(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (locked_by) REFERENCES person(id) ON DELETE SET NULL,
+    UNIQUE (group_id, subject_id)
+);
+
+-- Журнал разблокировок ведомостей, только добавление
+CREATE TABLE IF NOT EXISTS grade_unlock (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    group_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    admin_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    unlocked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    unlocked_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE OR REPLACE TRIGGER grade_unlock_append_only
+BEFORE UPDATE OR DELETE ON grade_unlock
+FOR EACH ROW EXECUTE FUNCTION grade_history_append_only();
+
+-- Апелляции студентов на оценки. grade_kind и grade_id указывают на student_grades или student_total_grades.
+-- На одну оценку может быть только одна нерешённая апелляция.
+CREATE TABLE IF NOT EXISTS grade_appeal (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    student_id INTEGER NOT NULL,
+    grade_kind VARCHAR(10) NOT NULL,
+    grade_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    professor_id INTEGER,
+    reason TEXT NOT NULL,
+    resolution TEXT,
+    resolved_grade VARCHAR(50),
+    resolved_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ,
+    FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (professor_id) REFERENCES person(id) ON DELETE SET NULL,
+    FOREIGN KEY (resolved_by) REFERENCES person(id) ON DELETE SET NULL,
+    CHECK (grade_kind IN ('lesson', 'total')),
+    CHECK (status IN ('open', 'assigned', 'escalated', 'resolved'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS grade_appeal_active_idx ON grade_appeal (grade_kind, grade_id)
+WHERE status <> 'resolved';
+
+CREATE TABLE IF NOT EXISTS grade_appeal_comment (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    appeal_id INTEGER NOT NULL,
+    author_id INTEGER,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (appeal_id) REFERENCES grade_appeal(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES person(id) ON DELETE SET NULL
+);
+
+-- Вложения хранятся в базе, размер ограничивается при загрузке
+CREATE TABLE IF NOT EXISTS grade_appeal_attachment (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    appeal_id INTEGER NOT NULL,
+    uploaded_by INTEGER,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (appeal_id) REFERENCES grade_appeal(id) ON DELETE CASCADE,
+    FOREIGN KEY (uploaded_by) REFERENCES person(id) ON DELETE SET NULL
+);
+
+-- Факультативы: предметы с индивидуальной записью и ограниченным числом мест
+CREATE TABLE IF NOT EXISTS elective (
+    subject_id INTEGER PRIMARY KEY,
+    capacity INTEGER NOT NULL,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    CHECK (capacity >= 0)
+);
+
+-- Индивидуальная запись студента на предмет (факультатив или пересдача) в дополнение к group_subject
+CREATE TABLE IF NOT EXISTS enrollment (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'enrolled',
+    enrolled_at TIMESTAMP NOT NULL DEFAULT now(),
     FOREIGN KEY (student_id) REFERENCES person(id) ON DELETE CASCADE,
     FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
-    UNIQUE (student_id, subject_id)
-);
\ No newline at end of file
+    UNIQUE (student_id, subject_id),
+    CHECK (status IN ('enrolled', 'waitlisted', 'dropped'))
+);
+
+-- Пререквизиты: для изучения subject_id нужна итоговая оценка по prerequisite_id не ниже min_grade
+CREATE TABLE IF NOT EXISTS subject_prerequisite (
+    subject_id INTEGER NOT NULL,
+    prerequisite_id INTEGER NOT NULL,
+    min_grade NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+    FOREIGN KEY (prerequisite_id) REFERENCES subject(id) ON DELETE CASCADE,
+    PRIMARY KEY (subject_id, prerequisite_id),
+    CHECK (subject_id <> prerequisite_id)
+);
+
+-- Уведомления пользователей, read_at IS NULL - не прочитано
+CREATE TABLE IF NOT EXISTS notification (
+    id INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    recipient_id INTEGER NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    read_at TIMESTAMPTZ,
+    FOREIGN KEY (recipient_id) REFERENCES person(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS notification_recipient_idx ON notification (recipient_id, created_at);
@@ -0,0 +1,739 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Статусы заявлений об уважительной причине пропусков
+const (
+	excuseStatusPending  = "pending"
+	excuseStatusApproved = "approved"
+	excuseStatusRejected = "rejected"
+)
+
+const maxExcuseDocumentSize = 5 << 20
+
+var errExcuseNotFound = errors.New("absence excuse not found")
+
+const absenceExcuseColumns = `
+	ae.id, ae.student_id, p.firstname, p.lastname, COALESCE(p.group_id, 0), ae.date_from, ae.date_to, ae.reason,
+	ae.status, ae.file_name, ae.content_type, octet_length(ae.content), COALESCE(ae.reviewed_by, 0),
+	COALESCE(ae.review_comment, ''), ae.created_at, ae.reviewed_at
+	FROM absence_excuse ae
+	JOIN person p ON ae.student_id = p.id`
+
+func scanAbsenceExcuse(row interface{ Scan(...any) error }) (model.AbsenceExcuse, error) {
+	var excuse model.AbsenceExcuse
+
+	err := row.Scan(
+		&excuse.ID,
+		&excuse.StudentID,
+		&excuse.StudentFirstname,
+		&excuse.StudentLastname,
+		&excuse.GroupID,
+		&excuse.DateFrom,
+		&excuse.DateTo,
+		&excuse.Reason,
+		&excuse.Status,
+		&excuse.FileName,
+		&excuse.ContentType,
+		&excuse.Size,
+		&excuse.ReviewedBy,
+		&excuse.ReviewComment,
+		&excuse.CreatedAt,
+		&excuse.ReviewedAt)
+
+	return excuse, err
+}
+
+func getAbsenceExcuse(excuseID int) (model.AbsenceExcuse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	excuse, err := scanAbsenceExcuse(db.QueryRowContext(ctx, `SELECT `+absenceExcuseColumns+` WHERE ae.id = $1`, excuseID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return excuse, errExcuseNotFound
+	}
+
+	return excuse, err
+}
+
+// canReviewExcuse разрешает рассматривать заявления студента администраторам и куратору его группы
+func canReviewExcuse(issuer string, studentID int) (bool, error) {
+	isAdmin, err := isAdmin(issuer)
+	if err != nil || isAdmin {
+		return isAdmin, err
+	}
+
+	return isCuratorOfStudent(issuer, studentID)
+}
+
+// SubmitAbsenceExcuse подаёт заявление текущего студента об уважительной причине пропусков.
+// Запрос в формате multipart/form-data с полями date_from, date_to, reason и file (подтверждающий документ).
+func SubmitAbsenceExcuse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking student privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isStudent {
+		http.Error(w, "Only students can submit absence excuses", http.StatusUnauthorized)
+		return
+	}
+
+	// Запас в 1 МБ на остальные поля формы
+	r.Body = http.MaxBytesReader(w, r.Body, maxExcuseDocumentSize+1<<20)
+
+	err = r.ParseMultipartForm(maxExcuseDocumentSize)
+	if err != nil {
+		http.Error(w, "Request must be multipart/form-data no bigger than 5 MB", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	excuse := model.AbsenceExcuse{Reason: r.FormValue("reason"), Status: excuseStatusPending}
+
+	excuse.DateFrom, err = time.Parse(time.DateOnly, r.FormValue("date_from"))
+	if err != nil {
+		http.Error(w, "date_from must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+
+	excuse.DateTo, err = time.Parse(time.DateOnly, r.FormValue("date_to"))
+	if err != nil {
+		http.Error(w, "date_to must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+
+	if excuse.DateTo.Before(excuse.DateFrom) {
+		http.Error(w, "date_to cannot be before date_from", http.StatusBadRequest)
+		return
+	}
+
+	if excuse.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(excuse.Reason) > 2000 {
+		http.Error(w, "Maximum reason length is 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxExcuseDocumentSize+1))
+	if err != nil {
+		http.Error(w, "Error reading uploaded file", http.StatusInternalServerError)
+		return
+	}
+
+	if len(content) > maxExcuseDocumentSize {
+		http.Error(w, "Maximum document size is 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if len(content) == 0 {
+		http.Error(w, "Document cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	excuse.FileName = filepath.Base(header.Filename)
+	if utf8.RuneCountInString(excuse.FileName) > 255 {
+		http.Error(w, "Maximum file name length is 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	excuse.ContentType = header.Header.Get("Content-Type")
+	if excuse.ContentType == "" {
+		excuse.ContentType = http.DetectContentType(content)
+	}
+	excuse.Size = len(content)
+
+	submitAbsenceExcuseQuery := `
+		INSERT INTO absence_excuse (student_id, date_from, date_to, reason, file_name, content_type, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, submitAbsenceExcuseQuery, claims.Issuer, excuse.DateFrom, excuse.DateTo, excuse.Reason,
+		excuse.FileName, excuse.ContentType, content).Scan(&excuse.ID, &excuse.CreatedAt)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("SubmitAbsenceExcuse QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	excuse.StudentID, _ = strconv.Atoi(claims.Issuer)
+
+	resp, err := json.Marshal(excuse)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("SubmitAbsenceExcuse failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListAbsenceExcuses возвращает заявления об уважительной причине пропусков, новые первыми.
+// Администраторы видят все заявления, кураторы - заявления студентов своих групп, студенты - свои.
+// status и student_id необязательны.
+func ListAbsenceExcuses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != excuseStatusPending && status != excuseStatusApproved && status != excuseStatusRejected {
+		http.Error(w, "status must be pending, approved or rejected", http.StatusBadRequest)
+		return
+	}
+
+	studentID, err := optionalIntParam(r, "student_id")
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	// Не администратор видит свои заявления и заявления студентов групп, которые курирует
+	listAbsenceExcusesQuery := `SELECT ` + absenceExcuseColumns + `
+		WHERE ($1 = '' OR ae.status = $1) AND ($2 = 0 OR ae.student_id = $2)
+		  AND ($3 OR ae.student_id = $4
+		       OR EXISTS (SELECT 1 FROM group_curator gc WHERE gc.group_id = p.group_id AND gc.curator_id = $4))
+		ORDER BY ae.created_at DESC, ae.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listAbsenceExcusesQuery, status, studentID, isAdmin, claims.Issuer)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListAbsenceExcuses QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	excuses := []model.AbsenceExcuse{}
+
+	for rows.Next() {
+		excuse, err := scanAbsenceExcuse(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		excuses = append(excuses, excuse)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(excuses)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Absence Excuses failed: %v\n", err)
+	}
+}
+
+// GetAbsenceExcuseDocument отдаёт подтверждающий документ заявления id.
+// Доступно автору заявления, администраторам и куратору его группы.
+func GetAbsenceExcuseDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramExcuseID := r.URL.Query().Get("id")
+
+	excuseID, err := strconv.Atoi(paramExcuseID)
+	if err != nil {
+		http.Error(w, "id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var studentID int
+	var fileName, contentType string
+	var content []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	documentQuery := `SELECT student_id, file_name, content_type, content FROM absence_excuse WHERE id = $1`
+
+	err = db.QueryRowContext(ctx, documentQuery, excuseID).Scan(&studentID, &fileName, &contentType, &content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Absence excuse not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetAbsenceExcuseDocument QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if claims.Issuer != strconv.Itoa(studentID) {
+		canReview, err := canReviewExcuse(claims.Issuer, studentID)
+		if err != nil {
+			log.Println("canReviewExcuse error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if !canReview {
+			http.Error(w, "You do not have access to this absence excuse", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(content)
+	if err != nil {
+		log.Printf("Get Absence Excuse Document failed: %v\n", err)
+	}
+}
+
+// excuseLessons отмечает уважительными пропуски студента на занятиях с dateFrom по dateTo.
+// Отметки present и remote не меняются, занятия без отметки получают excused.
+// Возвращает занятия, отметки которых изменились.
+func excuseLessons(ctx context.Context, tx *sql.Tx, excuse model.AbsenceExcuse, actorID string) ([]int, error) {
+	studentLessonsQuery := `
+		SELECT l.id
+		FROM person p
+		JOIN lesson l ON ` + lessonStudentCondition + `
+		WHERE p.id = $1 AND l.starts_at::date BETWEEN $2 AND $3
+		ORDER BY l.starts_at, l.id`
+
+	rows, err := tx.QueryContext(ctx, studentLessonsQuery, excuse.StudentID, excuse.DateFrom, excuse.DateTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var studentLessonIDs []int
+
+	for rows.Next() {
+		var lessonID int
+
+		if err := rows.Scan(&lessonID); err != nil {
+			return nil, err
+		}
+
+		studentLessonIDs = append(studentLessonIDs, lessonID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	minutesLate := 0
+	note := "Absence excuse #" + strconv.Itoa(excuse.ID)
+
+	var lessonIDs []int
+
+	for _, lessonID := range studentLessonIDs {
+		changed, err := recordLessonAttendance(ctx, tx, attendanceChange{
+			lessonID:    lessonID,
+			studentID:   excuse.StudentID,
+			status:      attendanceStatusExcused,
+			minutesLate: &minutesLate,
+			note:        &note,
+			replaceable: []string{attendanceStatusAbsent, attendanceStatusLate, attendanceStatusLeftEarly},
+		}, actorID)
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			lessonIDs = append(lessonIDs, lessonID)
+		}
+	}
+
+	return lessonIDs, nil
+}
+
+// lessonProfessorIDs возвращает преподавателей занятий lessonIDs.
+// Для занятия без преподавателя берутся преподаватели, которые ведут его предмет у его группы.
+func lessonProfessorIDs(ctx context.Context, tx *sql.Tx, lessonIDs []int) ([]int, error) {
+	lessonProfessorsQuery := `
+		SELECT l.professor_id FROM lesson l
+		WHERE l.id = ANY($1) AND l.professor_id IS NOT NULL
+		UNION
+		SELECT ps.professor_id FROM lesson l
+		JOIN professor_subject ps ON ps.subject_id = l.subject_id
+		JOIN professor_group pg ON pg.professor_id = ps.professor_id AND pg.group_id = l.group_id
+		WHERE l.id = ANY($1) AND l.professor_id IS NULL`
+
+	rows, err := tx.QueryContext(ctx, lessonProfessorsQuery, pq.Array(lessonIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var professorIDs []int
+
+	for rows.Next() {
+		var professorID int
+
+		if err := rows.Scan(&professorID); err != nil {
+			return nil, err
+		}
+
+		professorIDs = append(professorIDs, professorID)
+	}
+
+	return professorIDs, rows.Err()
+}
+
+// ReviewAbsenceExcuse одобряет или отклоняет заявление, которое ещё на рассмотрении.
+// Одобрение отмечает пропуски студента за период уважительными и уведомляет преподавателей этих занятий.
+// Студент получает уведомление о решении в обоих случаях.
+func ReviewAbsenceExcuse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var review model.AbsenceExcuseReview
+
+	err = json.NewDecoder(r.Body).Decode(&review)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if review.Status != excuseStatusApproved && review.Status != excuseStatusRejected {
+		http.Error(w, "status must be approved or rejected", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(review.Comment) > 2000 {
+		http.Error(w, "Maximum comment length is 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	excuse, err := getAbsenceExcuse(review.ID)
+	if err != nil {
+		if errors.Is(err, errExcuseNotFound) {
+			http.Error(w, "Absence excuse not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getAbsenceExcuse error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canReview, err := canReviewExcuse(claims.Issuer, excuse.StudentID)
+	if err != nil {
+		log.Println("canReviewExcuse error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canReview {
+		http.Error(w, "Only administrators and the curator of the student's group can review absence excuses", http.StatusUnauthorized)
+		return
+	}
+
+	if excuse.Status != excuseStatusPending {
+		http.Error(w, "Absence excuse is already reviewed", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("ReviewAbsenceExcuse BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Условие на статус защищает от одновременного рассмотрения одного заявления
+	reviewAbsenceExcuseQuery := `
+		UPDATE absence_excuse SET status = $1, review_comment = NULLIF($2, ''), reviewed_by = $3, reviewed_at = now()
+		WHERE id = $4 AND status = 'pending'`
+
+	period := excuse.DateFrom.Format(time.DateOnly) + " - " + excuse.DateTo.Format(time.DateOnly)
+	studentMessage := "Your absence excuse for " + period + " was " + review.Status
+	if review.Comment != "" {
+		studentMessage += ": " + review.Comment
+	}
+
+	var lessonIDs, professorIDs []int
+	var result sql.Result
+	var reviewed int64
+
+	result, err = tx.ExecContext(ctx, reviewAbsenceExcuseQuery, review.Status, review.Comment, claims.Issuer, excuse.ID)
+	if err == nil {
+		reviewed, err = result.RowsAffected()
+	}
+	if err == nil && reviewed == 0 {
+		http.Error(w, "Absence excuse is already reviewed", http.StatusConflict)
+		return
+	}
+	if err == nil && review.Status == excuseStatusApproved {
+		lessonIDs, err = excuseLessons(ctx, tx, excuse, claims.Issuer)
+	}
+	if err == nil && len(lessonIDs) > 0 {
+		professorIDs, err = lessonProfessorIDs(ctx, tx, lessonIDs)
+	}
+	if err == nil && len(professorIDs) > 0 {
+		err = notifyUsers(ctx, tx, professorIDs, notificationAbsenceExcused, excuse.StudentFirstname+" "+
+			excuse.StudentLastname+" has an approved absence excuse for "+period+", "+
+			strconv.Itoa(len(lessonIDs))+" lesson(s) marked as excused")
+	}
+	if err == nil {
+		err = notifyUsers(ctx, tx, []int{excuse.StudentID}, notificationAbsenceExcuseReviewed, studentMessage)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ReviewAbsenceExcuse QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(lessonIDs) > 0 {
+		if err := checkAttendanceAlerts(attendanceFilter{studentID: excuse.StudentID}); err != nil {
+			log.Println("checkAttendanceAlerts error: ", err)
+		}
+	}
+
+	excuse, err = getAbsenceExcuse(excuse.ID)
+	if err != nil {
+		log.Println("getAbsenceExcuse error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	excuse.ExcusedLessons = len(lessonIDs)
+
+	resp, err := json.Marshal(excuse)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("ReviewAbsenceExcuse failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,620 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var errAssignmentNotFound = errors.New("assignment not found")
+
+// Студенты задания - студенты его группы, а для задания подгруппы - только её студенты
+const assignmentStudentCondition = `
+	p.group_id = a.group_id AND (a.subgroup_id IS NULL OR p.subgroup_id = a.subgroup_id)
+	AND p.is_professor = false AND p.is_admin = false`
+
+const assignmentColumns = `
+	a.id, a.subject_id, s.subject_name, a.group_id, g.group_name, COALESCE(a.subgroup_id, 0),
+	a.title, COALESCE(a.description, ''), a.due_at, COALESCE(a.created_by, 0),
+	a.cancelled_at IS NOT NULL, a.sequence, a.updated_at
+	FROM assignment a
+	JOIN subject s ON a.subject_id = s.id
+	JOIN group_uni g ON a.group_id = g.id`
+
+func scanAssignment(row interface{ Scan(...any) error }) (model.Assignment, error) {
+	var assignment model.Assignment
+
+	err := row.Scan(
+		&assignment.ID,
+		&assignment.SubjectID,
+		&assignment.SubjectName,
+		&assignment.GroupID,
+		&assignment.GroupName,
+		&assignment.SubgroupID,
+		&assignment.Title,
+		&assignment.Description,
+		&assignment.DueAt,
+		&assignment.CreatedBy,
+		&assignment.Cancelled,
+		&assignment.Sequence,
+		&assignment.UpdatedAt)
+
+	return assignment, err
+}
+
+func getAssignment(assignmentID int) (model.Assignment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	assignment, err := scanAssignment(db.QueryRowContext(ctx, `SELECT `+assignmentColumns+` WHERE a.id = $1`, assignmentID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return assignment, errAssignmentNotFound
+	}
+
+	return assignment, err
+}
+
+// canManageAssignment разрешает работу с заданием администраторам и преподавателям,
+// которые ведут предмет и группу (или подгруппу) задания
+func canManageAssignment(issuer string, assignment model.Assignment) (bool, error) {
+	isAdmin, err := isAdmin(issuer)
+	if err != nil || isAdmin {
+		return isAdmin, err
+	}
+
+	hasSubject, err := professorHasSubject(issuer, assignment.SubjectID)
+	if err != nil || !hasSubject {
+		return false, err
+	}
+
+	return professorTeachesGroup(issuer, assignment.GroupID, assignment.SubgroupID)
+}
+
+// assignmentProblem проверяет задание перед сохранением.
+// Возвращает пустую строку, если задание корректно, иначе описание ошибки для клиента.
+func assignmentProblem(assignment model.Assignment) (string, error) {
+	if strings.TrimSpace(assignment.Title) == "" {
+		return "title is required", nil
+	}
+
+	if utf8.RuneCountInString(assignment.Title) > 255 {
+		return "Maximum assignment title length is 255 characters", nil
+	}
+
+	if utf8.RuneCountInString(assignment.Description) > 10000 {
+		return "Maximum assignment description length is 10000 characters", nil
+	}
+
+	if assignment.DueAt.IsZero() {
+		return "due_at is required", nil
+	}
+
+	hasSubject, err := groupHasSubject(assignment.GroupID, assignment.SubjectID)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasSubject {
+		return "Group does not study this subject", nil
+	}
+
+	if assignment.SubgroupID != 0 {
+		belongs, err := subgroupBelongsToGroup(assignment.SubgroupID, assignment.GroupID)
+		if err != nil {
+			return "", err
+		}
+
+		if !belongs {
+			return "Subgroup does not belong to this group", nil
+		}
+	}
+
+	return "", nil
+}
+
+// AddAssignment добавляет задание со сроком сдачи due_at для группы или подгруппы
+func AddAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var assignment model.Assignment
+
+	err = json.NewDecoder(r.Body).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	canManage, err := canManageAssignment(claims.Issuer, assignment)
+	if err != nil {
+		log.Println("canManageAssignment error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only add assignments of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	problem, err := assignmentProblem(assignment)
+	if err != nil {
+		log.Println("assignmentProblem error: ", err)
+		http.Error(w, "Error while checking assignment", http.StatusInternalServerError)
+		return
+	}
+
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	addAssignmentQuery := `
+		INSERT INTO assignment (subject_id, group_id, subgroup_id, title, description, due_at, created_by)
+		VALUES ($1, $2, NULLIF($3, 0), $4, NULLIF($5, ''), $6, $7)
+		RETURNING id;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, addAssignmentQuery, assignment.SubjectID, assignment.GroupID, assignment.SubgroupID,
+		assignment.Title, assignment.Description, assignment.DueAt, claims.Issuer).Scan(&assignment.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddAssignment QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Subject, group or subgroup does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(assignment.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Add assignment failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateAssignment изменяет задание id. Проверяются права и на старое, и на новое задание.
+// Отменённое задание изменить нельзя.
+func UpdateAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var assignment model.Assignment
+
+	err = json.NewDecoder(r.Body).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	currentAssignment, err := getAssignment(assignment.ID)
+	if err != nil {
+		if errors.Is(err, errAssignmentNotFound) {
+			http.Error(w, "Assignment not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getAssignment error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageAssignment(claims.Issuer, currentAssignment)
+	if err == nil && canManage {
+		canManage, err = canManageAssignment(claims.Issuer, assignment)
+	}
+
+	if err != nil {
+		log.Println("canManageAssignment error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only update assignments of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	if currentAssignment.Cancelled {
+		http.Error(w, "Assignment is cancelled", http.StatusConflict)
+		return
+	}
+
+	problem, err := assignmentProblem(assignment)
+	if err != nil {
+		log.Println("assignmentProblem error: ", err)
+		http.Error(w, "Error while checking assignment", http.StatusInternalServerError)
+		return
+	}
+
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	updateAssignmentQuery := `
+		UPDATE assignment SET subject_id = $1, group_id = $2, subgroup_id = NULLIF($3, 0), title = $4,
+		    description = NULLIF($5, ''), due_at = $6, sequence = sequence + 1, updated_at = now()
+		WHERE id = $7;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, updateAssignmentQuery, assignment.SubjectID, assignment.GroupID, assignment.SubgroupID,
+		assignment.Title, assignment.Description, assignment.DueAt, assignment.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UpdateAssignment ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Subject, group or subgroup does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Update assignment successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Update assignment failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelAssignment отменяет задание id. Отменённое задание остаётся в списках и календарях с пометкой отмены.
+func CancelAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var assignment model.Assignment
+
+	err = json.NewDecoder(r.Body).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	currentAssignment, err := getAssignment(assignment.ID)
+	if err != nil {
+		if errors.Is(err, errAssignmentNotFound) {
+			http.Error(w, "Assignment not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getAssignment error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageAssignment(claims.Issuer, currentAssignment)
+	if err != nil {
+		log.Println("canManageAssignment error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only cancel assignments of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	cancelAssignmentQuery := `
+		UPDATE assignment SET cancelled_at = now(), sequence = sequence + 1, updated_at = now()
+		WHERE id = $1 AND cancelled_at IS NULL;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, cancelAssignmentQuery, assignment.ID)
+
+	var cancelled int64
+	if err == nil {
+		cancelled, err = result.RowsAffected()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("CancelAssignment ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if cancelled == 0 {
+		http.Error(w, "Assignment is already cancelled", http.StatusConflict)
+		return
+	}
+
+	resp, err := json.Marshal("Cancel assignment successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Cancel assignment failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListAssignments возвращает задания. Фильтры group_id, subject_id, from и to (по сроку сдачи) необязательны.
+// Студент получает только задания своей группы и подгруппы.
+func ListAssignments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID, err := optionalIntParam(r, "group_id")
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	subjectID, err := optionalIntParam(r, "subject_id")
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	studentID := 0
+	if isStudent {
+		studentID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	listAssignmentsQuery := `SELECT ` + assignmentColumns + `
+		WHERE ($1 = 0 OR a.group_id = $1) AND ($2 = 0 OR a.subject_id = $2)
+		  AND ($3::date IS NULL OR a.due_at::date >= $3) AND ($4::date IS NULL OR a.due_at::date <= $4)
+		  AND ($5 = 0 OR EXISTS (SELECT 1 FROM person p WHERE p.id = $5 AND ` + assignmentStudentCondition + `))
+		ORDER BY a.due_at ` + dateFilter.order + `, a.id ` + dateFilter.order
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listAssignmentsQuery, groupID, subjectID, dateFilter.from, dateFilter.to, studentID)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListAssignments QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assignments := []model.Assignment{}
+
+	for rows.Next() {
+		assignment, err := scanAssignment(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(assignments)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Assignments failed: %v\n", err)
+	}
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// assignmentRow - строка assignmentColumns задания 4 предмета 3 группы 2
+func assignmentRow(cancelled bool) [][]driver.Value {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	return [][]driver.Value{{int64(4), int64(3), "Math", int64(2), "G1", int64(0),
+		"Homework 1", "", now.Add(72 * time.Hour), int64(1), cancelled, int64(0), now}}
+}
+
+var assignmentColumnNames = []string{"id", "subject_id", "subject_name", "group_id", "group_name", "subgroup_id",
+	"title", "description", "due_at", "created_by", "cancelled", "sequence", "updated_at"}
+
+func TestAddAssignment(t *testing.T) {
+	tests := []struct {
+		name       string
+		teaches    bool
+		body       string
+		wantStatus int
+	}{
+		{"added", true, `{"subject_id": 3, "group_id": 2, "title": "Homework 1", "due_at": "2024-05-04T12:00:00Z"}`, http.StatusCreated},
+		{"professor does not teach the group", false, `{"subject_id": 3, "group_id": 2, "title": "Homework 1", "due_at": "2024-05-04T12:00:00Z"}`, http.StatusUnauthorized},
+		{"no title", true, `{"subject_id": 3, "group_id": 2, "title": " ", "due_at": "2024-05-04T12:00:00Z"}`, http.StatusBadRequest},
+		{"no due date", true, `{"subject_id": 3, "group_id": 2, "title": "Homework 1"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var teaches [][]driver.Value
+			if test.teaches {
+				teaches = [][]driver.Value{{true}}
+			}
+
+			fake := useFakeDB(t, append(fakePerson(false, true),
+				fakeResult{match: "FROM professor_subject WHERE professor_id", columns: []string{"has_subject"}, rows: [][]driver.Value{{true}}},
+				fakeResult{match: "FROM professor_group", columns: []string{"teaches"}, rows: teaches},
+				fakeResult{match: "FROM group_subject WHERE group_id", columns: []string{"has_subject"}, rows: [][]driver.Value{{true}}},
+				fakeResult{match: "INSERT INTO assignment", columns: []string{"id"}, rows: [][]driver.Value{{int64(4)}}},
+			)...)
+
+			r := httptest.NewRequest(http.MethodPost, "/add-assignment", strings.NewReader(test.body))
+			r.AddCookie(fakeAuthCookie(t, "1"))
+			w := httptest.NewRecorder()
+
+			AddAssignment(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.wantStatus, w.Body.String())
+			}
+
+			if inserted := len(fake.executed("INSERT INTO assignment")) == 1; inserted != (test.wantStatus == http.StatusCreated) {
+				t.Errorf("assignment inserted = %v", inserted)
+			}
+		})
+	}
+}
+
+func TestCancelAssignment(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancelled  int64
+		wantStatus int
+	}{
+		{"cancelled", 1, http.StatusCreated},
+		{"already cancelled", 0, http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeDB(t, append(fakePerson(true, false),
+				fakeResult{match: "FROM assignment a", columns: assignmentColumnNames, rows: assignmentRow(test.cancelled == 0)},
+				fakeResult{match: "UPDATE assignment SET cancelled_at", affected: test.cancelled},
+			)...)
+
+			r := httptest.NewRequest(http.MethodPut, "/cancel-assignment", strings.NewReader(`{"id": 4}`))
+			r.AddCookie(fakeAuthCookie(t, "1"))
+			w := httptest.NewRecorder()
+
+			CancelAssignment(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.wantStatus, w.Body.String())
+			}
+
+			// Отмена увеличивает sequence, чтобы календари обновили событие
+			updates := fake.executed("UPDATE assignment SET cancelled_at")
+			if len(updates) != 1 || !strings.Contains(updates[0].query, "sequence = sequence + 1") {
+				t.Errorf("cancel = %v, want one update that increments sequence", updates)
+			}
+		})
+	}
+}
+
+func TestAssignmentHandlersCheckMethodAndLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+	}{
+		{"AddAssignment", AddAssignment, http.MethodPost},
+		{"UpdateAssignment", UpdateAssignment, http.MethodPut},
+		{"CancelAssignment", CancelAssignment, http.MethodPut},
+		{"ListAssignments", ListAssignments, http.MethodGet},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeAuthCookie(t, "1")
+
+			w := httptest.NewRecorder()
+			test.handler(w, httptest.NewRequest(http.MethodPatch, "/", nil))
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("PATCH status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+			}
+
+			w = httptest.NewRecorder()
+			test.handler(w, httptest.NewRequest(test.method, "/", nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status without login = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
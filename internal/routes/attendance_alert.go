@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// attendanceAlertRecipients возвращает куратора группы студента и преподавателей предмета в его группе
+func attendanceAlertRecipients(ctx context.Context, tx *sql.Tx, studentID, subjectID int) ([]int, error) {
+	recipientsQuery := `
+		SELECT gc.curator_id FROM person p
+		JOIN group_curator gc ON gc.group_id = p.group_id
+		WHERE p.id = $1
+		UNION
+		SELECT pg.professor_id FROM person p
+		JOIN professor_group pg ON pg.group_id = p.group_id
+		    AND (pg.subgroup_id IS NULL OR pg.subgroup_id = p.subgroup_id)
+		JOIN professor_subject ps ON ps.professor_id = pg.professor_id AND ps.subject_id = $2
+		WHERE p.id = $1`
+
+	rows, err := tx.QueryContext(ctx, recipientsQuery, studentID, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipientIDs []int
+
+	for rows.Next() {
+		var recipientID int
+		if err := rows.Scan(&recipientID); err != nil {
+			return nil, err
+		}
+		recipientIDs = append(recipientIDs, recipientID)
+	}
+
+	return recipientIDs, rows.Err()
+}
+
+// checkAttendanceAlerts уведомляет студента, куратора и преподавателей, когда посещаемость предмета
+// опускается ниже порога из правил предмета. Уведомление отправляется один раз за каждое падение:
+// пока студент ниже порога, запись в attendance_alert не даёт отправить его повторно.
+func checkAttendanceAlerts(filter attendanceFilter) error {
+	summaries, err := attendanceSummaries(filter)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rules := map[int]int{}
+
+	for _, summary := range summaries {
+		threshold, ok := rules[summary.SubjectID]
+		if !ok {
+			rule, err := getAttendanceRule(summary.SubjectID)
+			if err != nil {
+				return err
+			}
+			threshold = rule.AlertBelowPercent
+			rules[summary.SubjectID] = threshold
+		}
+
+		percent := roundHundredths(summary.AttendanceRate * 100)
+
+		if threshold == 0 || summary.CountedLessons == 0 || percent >= float64(threshold) {
+			_, err = tx.ExecContext(ctx, `DELETE FROM attendance_alert WHERE student_id = $1 AND subject_id = $2;`,
+				summary.StudentID, summary.SubjectID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO attendance_alert (student_id, subject_id, attendance_percent)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (student_id, subject_id) DO NOTHING;`,
+			summary.StudentID, summary.SubjectID, percent)
+		if err != nil {
+			return err
+		}
+
+		alerted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if alerted == 0 {
+			continue
+		}
+
+		recipientIDs, err := attendanceAlertRecipients(ctx, tx, summary.StudentID, summary.SubjectID)
+		if err == nil && len(recipientIDs) > 0 {
+			err = notifyUsers(ctx, tx, recipientIDs, notificationAttendanceBelowThreshold, fmt.Sprintf(
+				"Attendance of %s %s in %s dropped to %.2f%%, below %d%%",
+				summary.StudentFirstname, summary.StudentLastname, summary.SubjectName, percent, threshold))
+		}
+		if err == nil {
+			err = notifyUsers(ctx, tx, []int{summary.StudentID}, notificationAttendanceBelowThreshold, fmt.Sprintf(
+				"Your attendance in %s dropped to %.2f%%, below %d%%", summary.SubjectName, percent, threshold))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Разрезы отчёта о посещаемости
+const (
+	attendanceReportByStudent = "student"
+	attendanceReportByGroup   = "group"
+	attendanceReportBySubject = "subject"
+)
+
+// attendanceReport сводит посещаемость студентов по предметам в строки по студентам, группам или предметам.
+// Процент считается по сумме учитываемых занятий, поэтому правила каждого предмета уже применены.
+func attendanceReport(summaries []model.AttendanceSummary, by string) []model.AttendanceReportRow {
+	rows := map[int]*model.AttendanceReportRow{}
+	students := map[int]map[int]bool{}
+
+	for _, summary := range summaries {
+		id, name := summary.StudentID, summary.StudentLastname+" "+summary.StudentFirstname
+		switch by {
+		case attendanceReportByGroup:
+			id, name = summary.GroupID, summary.GroupName
+		case attendanceReportBySubject:
+			id, name = summary.SubjectID, summary.SubjectName
+		}
+
+		row, ok := rows[id]
+		if !ok {
+			row = &model.AttendanceReportRow{ID: id, Name: name}
+			rows[id] = row
+			students[id] = map[int]bool{}
+		}
+
+		students[id][summary.StudentID] = true
+		row.RecordedLessons += summary.RecordedLessons
+		row.CountedLessons += summary.CountedLessons
+		row.AttendedLessons += summary.AttendedLessons
+		row.EffectiveAbsences += summary.EffectiveAbsences
+	}
+
+	report := make([]model.AttendanceReportRow, 0, len(rows))
+
+	for id, row := range rows {
+		row.Students = len(students[id])
+		if row.CountedLessons > 0 {
+			row.AttendancePercent = roundHundredths(float64(row.AttendedLessons) * 100 / float64(row.CountedLessons))
+		}
+		report = append(report, *row)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Name != report[j].Name {
+			return report[i].Name < report[j].Name
+		}
+		return report[i].ID < report[j].ID
+	})
+
+	return report
+}
+
+// GetAttendanceReport возвращает процент посещаемости за период по студентам, группам или предметам (by).
+// subject_id, group_id, student_id, from и to необязательны. Администратор видит всё, преподаватель -
+// свои предметы и группы, куратором которых является, студент - только свою посещаемость.
+func GetAttendanceReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = attendanceReportByStudent
+	}
+
+	if by != attendanceReportByStudent && by != attendanceReportByGroup && by != attendanceReportBySubject {
+		http.Error(w, "by must be student, group or subject", http.StatusBadRequest)
+		return
+	}
+
+	var filter attendanceFilter
+	var err error
+
+	if filter.subjectID, err = optionalIntParam(r, "subject_id"); err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.groupID, err = optionalIntParam(r, "group_id"); err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.studentID, err = optionalIntParam(r, "student_id"); err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+	filter.from, filter.to = dateFilter.from, dateFilter.to
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	canView := isAdmin
+
+	if !canView && isStudent {
+		currentStudentID, err := strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if filter.studentID == 0 {
+			filter.studentID = currentStudentID
+		}
+		canView = filter.studentID == currentStudentID
+	}
+
+	if !canView && !isStudent && filter.subjectID != 0 {
+		canView, err = canManageSubject(claims.Issuer, filter.subjectID)
+		if err != nil {
+			log.Println("canManageSubject error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !canView && !isStudent && filter.groupID != 0 {
+		canView, err = isCuratorOfGroup(claims.Issuer, filter.groupID)
+		if err != nil {
+			log.Println("isCuratorOfGroup error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !canView && !isStudent && filter.studentID != 0 {
+		canView, err = isCuratorOfStudent(claims.Issuer, filter.studentID)
+		if err != nil {
+			log.Println("isCuratorOfStudent error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !canView {
+		http.Error(w, "Professors can view attendance reports only for subjects they teach or groups they curate", http.StatusUnauthorized)
+		return
+	}
+
+	summaries, err := attendanceSummaries(filter)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("attendanceSummaries deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("attendanceSummaries error: ", err)
+		http.Error(w, "Error while calculating attendance", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(attendanceReport(summaries, by))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Attendance Report failed: %v\n", err)
+	}
+}
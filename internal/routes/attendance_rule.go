@@ -0,0 +1,537 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultAttendanceRule - правила предмета, для которого они не заданы
+func defaultAttendanceRule(subjectID int) model.AttendanceRule {
+	return model.AttendanceRule{
+		SubjectID:             subjectID,
+		RemoteCountsAsPresent: true,
+		LateAfterMinutes:      10,
+		MinPresencePercent:    50,
+	}
+}
+
+func getAttendanceRule(subjectID int) (model.AttendanceRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rule := defaultAttendanceRule(subjectID)
+
+	attendanceRuleQuery := `
+		SELECT lates_per_absence, left_early_per_absence, remote_counts_as_present, excused_counts_as_present,
+		       late_after_minutes, min_presence_percent, alert_below_percent
+		FROM attendance_rule WHERE subject_id = $1`
+
+	err := db.QueryRowContext(ctx, attendanceRuleQuery, subjectID).Scan(
+		&rule.LatesPerAbsence,
+		&rule.LeftEarlyPerAbsence,
+		&rule.RemoteCountsAsPresent,
+		&rule.ExcusedCountsAsPresent,
+		&rule.LateAfterMinutes,
+		&rule.MinPresencePercent,
+		&rule.AlertBelowPercent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, nil
+	}
+
+	return rule, err
+}
+
+// applyAttendanceRule считает учитываемые занятия, пропуски и долю посещений по счётчикам статусов.
+// Опоздания и ранние уходы засчитываются как присутствие, пока их не наберётся на пропуск.
+func applyAttendanceRule(summary *model.AttendanceSummary, rule model.AttendanceRule) {
+	attended := summary.Present + summary.Late + summary.LeftEarly
+	absences := summary.Absent
+	summary.CountedLessons = summary.RecordedLessons
+
+	if rule.RemoteCountsAsPresent {
+		attended += summary.Remote
+	} else {
+		absences += summary.Remote
+	}
+
+	if rule.ExcusedCountsAsPresent {
+		attended += summary.Excused
+	} else {
+		summary.CountedLessons -= summary.Excused
+	}
+
+	penalty := 0
+	if rule.LatesPerAbsence > 0 {
+		penalty += summary.Late / rule.LatesPerAbsence
+	}
+	if rule.LeftEarlyPerAbsence > 0 {
+		penalty += summary.LeftEarly / rule.LeftEarlyPerAbsence
+	}
+
+	summary.AttendedLessons = attended - penalty
+	summary.EffectiveAbsences = absences + penalty
+	summary.AttendanceRate = 0
+	if summary.CountedLessons > 0 {
+		summary.AttendanceRate = roundHundredths(float64(summary.AttendedLessons) / float64(summary.CountedLessons))
+	}
+}
+
+// attendanceFilter - необязательные условия выборки посещаемости, нулевые значения не ограничивают выборку
+// professorID ограничивает занятия преподавателем занятия, termID - датами семестра.
+type attendanceFilter struct {
+	subjectID   int
+	groupID     int
+	studentID   int
+	professorID int
+	termID      int
+	from        *time.Time
+	to          *time.Time
+}
+
+// attendanceSummaries считает посещаемость занятий по студентам и предметам с учётом правил предметов.
+// Учитываются только занятия с отметкой посещаемости.
+func attendanceSummaries(filter attendanceFilter) ([]model.AttendanceSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	attendanceSummariesQuery := `
+		SELECT p.id, p.firstname, p.lastname, COALESCE(p.group_id, 0), COALESCE(g.group_name, ''),
+		       COALESCE(p.subgroup_id, 0), l.subject_id, s.subject_name, COUNT(*),
+		       COUNT(*) FILTER (WHERE la.status = 'present'),
+		       COUNT(*) FILTER (WHERE la.status = 'late'),
+		       COUNT(*) FILTER (WHERE la.status = 'excused'),
+		       COUNT(*) FILTER (WHERE la.status = 'left_early'),
+		       COUNT(*) FILTER (WHERE la.status = 'remote'),
+		       COUNT(*) FILTER (WHERE la.status = 'absent')
+		FROM lesson_attendance la
+		JOIN lesson l ON la.lesson_id = l.id
+		JOIN person p ON la.student_id = p.id
+		JOIN subject s ON l.subject_id = s.id
+		LEFT JOIN group_uni g ON p.group_id = g.id
+		WHERE ($1 = 0 OR l.subject_id = $1) AND ($2 = 0 OR p.group_id = $2) AND ($3 = 0 OR p.id = $3)
+		  AND ($4::date IS NULL OR l.starts_at::date >= $4) AND ($5::date IS NULL OR l.starts_at::date <= $5)
+		  AND ($6 = 0 OR l.professor_id = $6)
+		  AND ($7 = 0 OR EXISTS (
+		      SELECT 1 FROM term t WHERE t.id = $7 AND l.starts_at::date BETWEEN t.start_date AND t.end_date))
+		GROUP BY p.id, p.firstname, p.lastname, p.group_id, g.group_name, p.subgroup_id, l.subject_id, s.subject_name
+		ORDER BY s.subject_name, l.subject_id, p.lastname, p.firstname, p.id`
+
+	rows, err := db.QueryContext(ctx, attendanceSummariesQuery,
+		filter.subjectID, filter.groupID, filter.studentID, filter.from, filter.to, filter.professorID, filter.termID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []model.AttendanceSummary{}
+
+	for rows.Next() {
+		var summary model.AttendanceSummary
+
+		if err := rows.Scan(
+			&summary.StudentID,
+			&summary.StudentFirstname,
+			&summary.StudentLastname,
+			&summary.GroupID,
+			&summary.GroupName,
+			&summary.SubgroupID,
+			&summary.SubjectID,
+			&summary.SubjectName,
+			&summary.RecordedLessons,
+			&summary.Present,
+			&summary.Late,
+			&summary.Excused,
+			&summary.LeftEarly,
+			&summary.Remote,
+			&summary.Absent); err != nil {
+			return nil, err
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rules := map[int]model.AttendanceRule{}
+
+	for i := range summaries {
+		rule, ok := rules[summaries[i].SubjectID]
+		if !ok {
+			rule, err = getAttendanceRule(summaries[i].SubjectID)
+			if err != nil {
+				return nil, err
+			}
+			rules[summaries[i].SubjectID] = rule
+		}
+
+		applyAttendanceRule(&summaries[i], rule)
+	}
+
+	return summaries, nil
+}
+
+// GetAttendanceRule возвращает правила подсчёта посещаемости предмета subject_id
+func GetAttendanceRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := getAttendanceRule(subjectID)
+	if err != nil {
+		log.Println("getAttendanceRule error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(rule)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Attendance Rule failed: %v\n", err)
+	}
+}
+
+// SetAttendanceRule задаёт правила подсчёта посещаемости предмета.
+// Доступно администраторам и преподавателям предмета.
+func SetAttendanceRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	// Не переданные поля получают значения по умолчанию
+	rule := defaultAttendanceRule(0)
+
+	err = json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	canManage, err := canManageSubject(claims.Issuer, rule.SubjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only set attendance rules of subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	if rule.LatesPerAbsence < 0 || rule.LeftEarlyPerAbsence < 0 || rule.LateAfterMinutes < 0 {
+		http.Error(w, "lates_per_absence, left_early_per_absence and late_after_minutes cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if rule.MinPresencePercent < 0 || rule.MinPresencePercent > 100 {
+		http.Error(w, "min_presence_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	if rule.AlertBelowPercent < 0 || rule.AlertBelowPercent > 100 {
+		http.Error(w, "alert_below_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	setAttendanceRuleQuery := `
+		INSERT INTO attendance_rule
+		    (subject_id, lates_per_absence, left_early_per_absence, remote_counts_as_present, excused_counts_as_present,
+		     late_after_minutes, min_presence_percent, alert_below_percent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (subject_id)
+		DO UPDATE SET lates_per_absence = EXCLUDED.lates_per_absence,
+		    left_early_per_absence = EXCLUDED.left_early_per_absence,
+		    remote_counts_as_present = EXCLUDED.remote_counts_as_present,
+		    excused_counts_as_present = EXCLUDED.excused_counts_as_present,
+		    late_after_minutes = EXCLUDED.late_after_minutes,
+		    min_presence_percent = EXCLUDED.min_presence_percent,
+		    alert_below_percent = EXCLUDED.alert_below_percent;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, setAttendanceRuleQuery, rule.SubjectID, rule.LatesPerAbsence, rule.LeftEarlyPerAbsence,
+		rule.RemoteCountsAsPresent, rule.ExcusedCountsAsPresent, rule.LateAfterMinutes, rule.MinPresencePercent,
+		rule.AlertBelowPercent)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("SetAttendanceRule QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation, subject does not exist: ", err)
+			http.Error(w, "Subject does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Set attendance rule successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Set attendance rule failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListAttendanceSummaries возвращает посещаемость студентов по предмету subject_id с учётом правил предмета.
+// group_id, student_id, from и to необязательны.
+func ListAttendanceSummaries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter attendanceFilter
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+	filter.subjectID = subjectID
+
+	if filter.groupID, err = optionalIntParam(r, "group_id"); err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.studentID, err = optionalIntParam(r, "student_id"); err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+	filter.from, filter.to = dateFilter.from, dateFilter.to
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "Only administrators and professors of this subject can view attendance summaries", http.StatusUnauthorized)
+		return
+	}
+
+	summaries, err := attendanceSummaries(filter)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("attendanceSummaries deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("attendanceSummaries error: ", err)
+		http.Error(w, "Error while calculating attendance", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(summaries)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Attendance Summaries failed: %v\n", err)
+	}
+}
+
+// ListCurrentUserAttendanceSummaries возвращает посещаемость текущего студента по предметам.
+// subject_id, from и to необязательны.
+func ListCurrentUserAttendanceSummaries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter attendanceFilter
+	var err error
+
+	if filter.subjectID, err = optionalIntParam(r, "subject_id"); err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+	filter.from, filter.to = dateFilter.from, dateFilter.to
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	filter.studentID, err = strconv.Atoi(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	summaries, err := attendanceSummaries(filter)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("attendanceSummaries deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("attendanceSummaries error: ", err)
+		http.Error(w, "Error while calculating attendance", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(summaries)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Current User Attendance Summaries failed: %v\n", err)
+	}
+}
@@ -0,0 +1,328 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxBulkGrades = 500
+
+// professorStudentsOfSubject возвращает студентов, которым преподаватель может ставить оценки по предмету:
+// студентов его групп (или подгрупп), изучающих предмет, и индивидуально записанных на предмет.
+func professorStudentsOfSubject(issuer string, subjectID int) (map[int]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	professorStudentsQuery := `
+		SELECT p.id
+		FROM person p
+		JOIN professor_group pg ON pg.group_id = p.group_id
+		    AND (pg.subgroup_id IS NULL OR pg.subgroup_id = p.subgroup_id)
+		JOIN group_subject gs ON gs.group_id = p.group_id
+		WHERE pg.professor_id = $1 AND gs.subject_id = $2
+		UNION
+		SELECT e.student_id
+		FROM enrollment e
+		JOIN professor_subject ps ON e.subject_id = ps.subject_id
+		WHERE ps.professor_id = $1 AND e.subject_id = $2 AND e.status = 'enrolled'`
+
+	rows, err := db.QueryContext(ctx, professorStudentsQuery, issuer, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := map[int]bool{}
+
+	for rows.Next() {
+		var studentID int
+		if err := rows.Scan(&studentID); err != nil {
+			return nil, err
+		}
+		students[studentID] = true
+	}
+
+	return students, rows.Err()
+}
+
+func writeBulkGradeResults(w http.ResponseWriter, status int, results []model.BulkGradeResult) {
+	resp, err := json.Marshal(results)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("InsertGradesOfStudents failed: %v\n", err)
+	}
+}
+
+// InsertGradesOfStudents выставляет оценки за занятие сразу многим студентам одного предмета.
+// Права проверяются один раз на весь пакет, оценки добавляются в одной транзакции:
+// если хотя бы одна оценка не прошла проверку, не добавляется ни одна,
+// а в ответе для каждой оценки указан её id или ошибка.
+func InsertGradesOfStudents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isProfessor, err := isProfessor(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isProfessor {
+		http.Error(w, "You do not have professor privileges", http.StatusUnauthorized)
+		return
+	}
+
+	var entry model.BulkGradeEntry
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(entry.Grades) == 0 {
+		http.Error(w, "Grades cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if len(entry.Grades) > maxBulkGrades {
+		http.Error(w, "Maximum number of grades in one request is "+strconv.Itoa(maxBulkGrades), http.StatusBadRequest)
+		return
+	}
+
+	hasSubject, err := professorHasSubject(claims.Issuer, entry.SubjectID)
+	if err != nil {
+		log.Println("professorHasSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject {
+		http.Error(w, "You can only set grades for subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	students, err := professorStudentsOfSubject(claims.Issuer, entry.SubjectID)
+	if err != nil {
+		log.Println("professorStudentsOfSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	// Проверки шкалы и категорий кэшируются: в пакете обычно несколько различных значений
+	gradeProblems := map[string]string{}
+	categoryProblems := map[int]string{}
+
+	results := make([]model.BulkGradeResult, len(entry.Grades))
+	hasProblems := false
+
+	for i := range entry.Grades {
+		studentGrade := &entry.Grades[i]
+		studentGrade.SubjectID = entry.SubjectID
+		if studentGrade.CategoryID == 0 {
+			studentGrade.CategoryID = entry.CategoryID
+		}
+		if studentGrade.LessonID == 0 {
+			studentGrade.LessonID = entry.LessonID
+		}
+		if studentGrade.LessonDate == nil {
+			studentGrade.LessonDate = entry.LessonDate
+		}
+		if studentGrade.Description == "" {
+			studentGrade.Description = entry.Description
+		}
+		if studentGrade.Reason == "" {
+			studentGrade.Reason = entry.Reason
+		}
+		results[i] = model.BulkGradeResult{Index: i, StudentID: studentGrade.StudentID}
+
+		if contextProblem := gradeContextProblem(*studentGrade); contextProblem != "" {
+			results[i].Error = contextProblem
+			hasProblems = true
+			continue
+		}
+
+		if !students[studentGrade.StudentID] {
+			results[i].Error = "You can only set grades for students from groups that you teach who have this subject"
+			hasProblems = true
+			continue
+		}
+
+		gradeProblem, ok := gradeProblems[lessonGradeValue(studentGrade.Grade)]
+		if !ok {
+			gradeProblem, err = lessonGradeProblem(entry.SubjectID, studentGrade.Grade)
+			if err != nil {
+				log.Println("lessonGradeProblem error: ", err)
+				http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+				return
+			}
+			gradeProblems[lessonGradeValue(studentGrade.Grade)] = gradeProblem
+		}
+
+		if gradeProblem != "" {
+			results[i].Error = gradeProblem
+			hasProblems = true
+			continue
+		}
+
+		if studentGrade.CategoryID != 0 {
+			categoryProblem, ok := categoryProblems[studentGrade.CategoryID]
+			if !ok {
+				belongs, err := categoryBelongsToSubject(studentGrade.CategoryID, entry.SubjectID)
+				if err != nil {
+					log.Println("categoryBelongsToSubject error: ", err)
+					http.Error(w, "Error while checking grade category", http.StatusInternalServerError)
+					return
+				}
+				if !belongs {
+					categoryProblem = "Grade category does not belong to this subject"
+				}
+				categoryProblems[studentGrade.CategoryID] = categoryProblem
+			}
+
+			if categoryProblem != "" {
+				results[i].Error = categoryProblem
+				hasProblems = true
+				continue
+			}
+		}
+
+		if studentGrade.LessonID != 0 {
+			hasStudent, err := lessonHasStudent(studentGrade.LessonID, studentGrade.StudentID, entry.SubjectID)
+			if err != nil {
+				log.Println("lessonHasStudent error: ", err)
+				http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+				return
+			}
+
+			if !hasStudent {
+				results[i].Error = "Lesson does not exist or is not a lesson of this student and subject"
+				hasProblems = true
+				continue
+			}
+		}
+	}
+
+	if hasProblems {
+		writeBulkGradeResults(w, http.StatusBadRequest, results)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("InsertGradesOfStudents BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	hasLocked := false
+
+	for i, studentGrade := range entry.Grades {
+		gradeID, insertErr := insertLessonGrade(ctx, tx, studentGrade, claims.Issuer)
+
+		// Ошибки отдельных оценок не прерывают транзакцию, чтобы сообщить обо всех сразу
+		switch {
+		case errors.Is(insertErr, errGradeLocked):
+			results[i].Error = "Grades of this group and subject are finalized"
+			hasProblems, hasLocked = true, true
+		case errors.Is(insertErr, errReasonRequired):
+			results[i].Error = "Reason is required for changes after the term end date"
+			hasProblems = true
+		case insertErr != nil:
+			err = insertErr
+		default:
+			results[i].GradeID = gradeID
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if hasProblems && err == nil {
+		for i := range results {
+			results[i].GradeID = 0
+		}
+
+		if hasLocked {
+			writeBulkGradeResults(w, http.StatusConflict, results)
+			return
+		}
+		writeBulkGradeResults(w, http.StatusBadRequest, results)
+		return
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("InsertGradesOfStudents QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeBulkGradeResults(w, http.StatusCreated, results)
+}
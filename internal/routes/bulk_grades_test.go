@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bulkGradeResults - ответы базы на пакет оценок преподавателя 1 по предмету 3 студентам 5 и 6.
+// lockResults и insertResults отвечают на проверку ведомости и вставку оценки каждого студента по очереди.
+func bulkGradeResults(lockResults, insertResults []fakeResult) []fakeResult {
+	results := append(fakePerson(false, true),
+		fakeResult{match: "FROM professor_subject WHERE professor_id", columns: []string{"has_subject"}, rows: [][]driver.Value{{true}}},
+		fakeResult{match: "JOIN professor_group pg", columns: []string{"id"}, rows: [][]driver.Value{{int64(5)}, {int64(6)}}},
+		fakeResult{match: "JOIN grading_scale_value gsv", columns: []string{"scale_name", "numeric_value"},
+			rows: [][]driver.Value{{"five-point", 4.0}, {"five-point", 5.0}}},
+	)
+	results = append(results, lockResults...)
+	results = append(results, insertResults...)
+
+	return append(results,
+		deadlineResult("BETWEEN start_date AND end_date", false),
+		deadlineResult("BETWEEN start_date AND end_date", false),
+		gradeHistoryResult,
+	)
+}
+
+func insertGradeResult(id int) fakeResult {
+	return fakeResult{match: "INSERT INTO student_grades", columns: []string{"id", "grade_date"},
+		rows: [][]driver.Value{{int64(id), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}}, once: true}
+}
+
+func lockResult(locked bool) fakeResult {
+	return fakeResult{match: "FROM grade_lock gl", columns: []string{"locked"}, rows: [][]driver.Value{{locked}}, once: true}
+}
+
+const bulkGradesBody = `{"subject_id": 3, "grades": [{"student_id": 5, "grade": 5}, {"student_id": 6, "grade": 4}]}`
+
+// Пакет оценок добавляется целиком или не добавляется вовсе
+func TestInsertGradesOfStudentsIsAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name       string
+		locks      []fakeResult
+		inserts    []fakeResult
+		wantStatus int
+		wantIDs    []int
+		wantErrors []string
+	}{
+		{
+			name:       "all grades are added",
+			locks:      []fakeResult{lockResult(false), lockResult(false)},
+			inserts:    []fakeResult{insertGradeResult(21), insertGradeResult(22)},
+			wantStatus: http.StatusCreated,
+			wantIDs:    []int{21, 22},
+			wantErrors: []string{"", ""},
+		},
+		{
+			name:       "one student's grades are finalized",
+			locks:      []fakeResult{lockResult(false), lockResult(true)},
+			inserts:    []fakeResult{insertGradeResult(21)},
+			wantStatus: http.StatusConflict,
+			wantIDs:    []int{0, 0},
+			wantErrors: []string{"", "Grades of this group and subject are finalized"},
+		},
+		{
+			name:  "database error on the second grade",
+			locks: []fakeResult{lockResult(false), lockResult(false)},
+			inserts: []fakeResult{insertGradeResult(21),
+				{match: "INSERT INTO student_grades", err: errors.New("connection reset"), once: true}},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeDB(t, bulkGradeResults(test.locks, test.inserts)...)
+
+			r := httptest.NewRequest(http.MethodPost, "/insert-grades-of-students", strings.NewReader(bulkGradesBody))
+			r.AddCookie(fakeAuthCookie(t, "1"))
+			w := httptest.NewRecorder()
+
+			InsertGradesOfStudents(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.wantStatus, w.Body.String())
+			}
+
+			committed := len(fake.executed("COMMIT")) == 1
+			if committed != (test.wantStatus == http.StatusCreated) {
+				t.Errorf("committed = %v, want %v", committed, !committed)
+			}
+
+			if !committed && len(fake.executed("ROLLBACK")) != 1 {
+				t.Error("failed batch is not rolled back")
+			}
+
+			if test.wantIDs == nil {
+				return
+			}
+
+			var results []model.BulkGradeResult
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, result := range results {
+				if result.GradeID != test.wantIDs[i] || result.Error != test.wantErrors[i] {
+					t.Errorf("result %d = %+v, want grade_id %d and error %q", i, result, test.wantIDs[i], test.wantErrors[i])
+				}
+			}
+		})
+	}
+}
+
+// Оценки, не прошедшие проверку, отклоняют пакет до начала транзакции
+func TestInsertGradesOfStudentsRejectsInvalidGrades(t *testing.T) {
+	fake := useFakeDB(t, bulkGradeResults(nil, nil)...)
+
+	body := `{"subject_id": 3, "grades": [{"student_id": 5, "grade": 5}, {"student_id": 6, "grade": 7}, {"student_id": 8, "grade": 5}]}`
+	r := httptest.NewRequest(http.MethodPost, "/insert-grades-of-students", strings.NewReader(body))
+	r.AddCookie(fakeAuthCookie(t, "1"))
+	w := httptest.NewRecorder()
+
+	InsertGradesOfStudents(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+
+	var results []model.BulkGradeResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 || results[0].Error != "" || results[1].Error == "" || results[2].Error == "" {
+		t.Errorf("results = %+v, want errors for the grade outside the scale and the student from another group", results)
+	}
+
+	if len(fake.executed("BEGIN")) != 0 {
+		t.Error("transaction is started for an invalid batch")
+	}
+}
+
+func TestInsertGradesOfStudentsRequiresProfessor(t *testing.T) {
+	useFakeDB(t, fakePerson(false, false)...)
+
+	r := httptest.NewRequest(http.MethodPost, "/insert-grades-of-students", strings.NewReader(bulkGradesBody))
+	r.AddCookie(fakeAuthCookie(t, "5"))
+	w := httptest.NewRecorder()
+
+	InsertGradesOfStudents(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,456 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// roomLinkURL - адрес страницы комнаты LiveKit во фронтенде, к нему добавляется id комнаты.
+// Задаётся ROOM_LINK_URL, по умолчанию http://CORS_ORIGIN/room/.
+var roomLinkURL string
+
+const icsTimeFormat = "20060102T150405Z"
+
+// Календарь содержит занятия и сроки заданий за последние 90 дней и на год вперёд
+const (
+	calendarFeedPast   = 90 * 24 * time.Hour
+	calendarFeedFuture = 366 * 24 * time.Hour
+)
+
+// roomLink возвращает ссылку на комнату LiveKit занятия, у занятия без аудитории ссылки нет
+func roomLink(roomID int) string {
+	if roomID == 0 {
+		return ""
+	}
+
+	return roomLinkURL + strconv.Itoa(roomID)
+}
+
+func newCalendarFeedToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+// calendarFeedURL возвращает адрес календаря с токеном на том же хосте, что и запрос
+func calendarFeedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + "/api/calendar-feed?token=" + url.QueryEscape(token)
+}
+
+// icsEscape экранирует текстовое значение свойства iCalendar (RFC 5545, 3.3.11)
+var icsEscape = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`).Replace
+
+// writeICSLine записывает строку iCalendar, перенося её после 75 октетов (RFC 5545, 3.1)
+// без разрыва символов UTF-8
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Пробел в начале продолжения тоже считается
+		limit = 74
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// writeLessonEvent записывает занятие как событие с постоянным UID. Изменения занятия увеличивают SEQUENCE,
+// а отменённое занятие остаётся событием со STATUS:CANCELLED, чтобы календари убрали его у себя.
+func writeLessonEvent(b *strings.Builder, lesson model.Lesson, now time.Time) {
+	summary := lesson.SubjectName
+	if lesson.Topic != "" {
+		summary += ": " + lesson.Topic
+	}
+	if lesson.Kind == lessonKindExam {
+		summary = "Exam: " + summary
+	}
+
+	description := []string{"Group: " + lesson.GroupName}
+	if lesson.ProfessorID != 0 {
+		description = append(description, "Professor: "+lesson.ProfessorFirstname+" "+lesson.ProfessorLastname)
+	}
+
+	link := roomLink(lesson.RoomID)
+	if link != "" {
+		description = append(description, "Room link: "+link)
+	}
+
+	status := "CONFIRMED"
+	if lesson.Cancelled {
+		status = "CANCELLED"
+	}
+
+	writeICSLine(b, "BEGIN:VEVENT")
+	writeICSLine(b, "UID:lesson-"+strconv.Itoa(lesson.ID)+"@learn_live")
+	writeICSLine(b, "DTSTAMP:"+now.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "LAST-MODIFIED:"+lesson.UpdatedAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "SEQUENCE:"+strconv.Itoa(lesson.Sequence))
+	writeICSLine(b, "DTSTART:"+lesson.StartsAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "DTEND:"+lesson.EndsAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "SUMMARY:"+icsEscape(summary))
+	writeICSLine(b, "DESCRIPTION:"+icsEscape(strings.Join(description, "\n")))
+	if lesson.RoomName != "" {
+		writeICSLine(b, "LOCATION:"+icsEscape(lesson.RoomName))
+	}
+	if link != "" {
+		writeICSLine(b, "URL:"+link)
+	}
+	if lesson.Kind == lessonKindExam {
+		writeICSLine(b, "CATEGORIES:EXAM")
+	}
+	writeICSLine(b, "STATUS:"+status)
+	writeICSLine(b, "END:VEVENT")
+}
+
+// writeAssignmentEvent записывает срок сдачи задания как событие без длительности в момент due_at.
+// UID, SEQUENCE и STATUS ведут себя так же, как у занятий.
+func writeAssignmentEvent(b *strings.Builder, assignment model.Assignment, now time.Time) {
+	description := []string{"Group: " + assignment.GroupName}
+	if assignment.Description != "" {
+		description = append(description, assignment.Description)
+	}
+
+	status := "CONFIRMED"
+	if assignment.Cancelled {
+		status = "CANCELLED"
+	}
+
+	writeICSLine(b, "BEGIN:VEVENT")
+	writeICSLine(b, "UID:assignment-"+strconv.Itoa(assignment.ID)+"@learn_live")
+	writeICSLine(b, "DTSTAMP:"+now.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "LAST-MODIFIED:"+assignment.UpdatedAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "SEQUENCE:"+strconv.Itoa(assignment.Sequence))
+	writeICSLine(b, "DTSTART:"+assignment.DueAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "DTEND:"+assignment.DueAt.UTC().Format(icsTimeFormat))
+	writeICSLine(b, "SUMMARY:"+icsEscape("Deadline: "+assignment.SubjectName+": "+assignment.Title))
+	writeICSLine(b, "DESCRIPTION:"+icsEscape(strings.Join(description, "\n")))
+	writeICSLine(b, "CATEGORIES:DEADLINE")
+	writeICSLine(b, "STATUS:"+status)
+	writeICSLine(b, "END:VEVENT")
+}
+
+// calendarFeedResponse отвечает ссылкой на календарь
+func calendarFeedResponse(w http.ResponseWriter, r *http.Request, token string) {
+	resp, err := json.Marshal(model.CalendarFeed{Token: token, URL: calendarFeedURL(r, token)})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Calendar feed response failed: %v\n", err)
+	}
+}
+
+// GetCurrentUserCalendarFeed возвращает ссылку на календарь текущего пользователя, создавая её при первом запросе
+func GetCurrentUserCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	feedToken, err := newCalendarFeedToken()
+	if err != nil {
+		log.Println("newCalendarFeedToken error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Существующая ссылка не меняется
+	getCalendarFeedQuery := `
+		INSERT INTO calendar_feed (person_id, token) VALUES ($1, $2)
+		ON CONFLICT (person_id) DO UPDATE SET token = calendar_feed.token
+		RETURNING token;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, getCalendarFeedQuery, claims.Issuer, feedToken).Scan(&feedToken)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetCurrentUserCalendarFeed QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	calendarFeedResponse(w, r, feedToken)
+}
+
+// ResetCurrentUserCalendarFeed заменяет ссылку на календарь текущего пользователя новой, старая перестаёт работать
+func ResetCurrentUserCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	feedToken, err := newCalendarFeedToken()
+	if err != nil {
+		log.Println("newCalendarFeedToken error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resetCalendarFeedQuery := `
+		INSERT INTO calendar_feed (person_id, token) VALUES ($1, $2)
+		ON CONFLICT (person_id) DO UPDATE SET token = EXCLUDED.token, created_at = now();`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, resetCalendarFeedQuery, claims.Issuer, feedToken)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ResetCurrentUserCalendarFeed ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	calendarFeedResponse(w, r, feedToken)
+}
+
+// GetCalendarFeed возвращает календарь владельца токена token в формате iCalendar (RFC 5545):
+// занятия и экзамены, а также сроки сдачи заданий.
+// Студент получает события своей группы и подгруппы, преподаватель - занятия, которые он ведёт,
+// и задания его предметов в его группах.
+// Запрос не требует входа в систему, чтобы календарь можно было подписать в приложении календаря.
+func GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feedToken := r.URL.Query().Get("token")
+	if feedToken == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var personID string
+
+	err := db.QueryRowContext(ctx, `SELECT person_id FROM calendar_feed WHERE token = $1`, feedToken).Scan(&personID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Calendar feed not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetCalendarFeed QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	isStudent, err := isStudent(personID)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	calendarFeedQuery := `SELECT ` + lessonColumns + ` WHERE l.starts_at >= $2 AND l.starts_at < $3 AND `
+	if isStudent {
+		calendarFeedQuery += `EXISTS (SELECT 1 FROM person p WHERE p.id = $1 AND ` + lessonStudentCondition + `)`
+	} else {
+		calendarFeedQuery += `l.professor_id = $1`
+	}
+	calendarFeedQuery += ` ORDER BY l.starts_at, l.id`
+
+	now := time.Now()
+
+	rows, err := db.QueryContext(ctx, calendarFeedQuery, personID, now.Add(-calendarFeedPast), now.Add(calendarFeedFuture))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetCalendarFeed QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var calendar strings.Builder
+
+	writeICSLine(&calendar, "BEGIN:VCALENDAR")
+	writeICSLine(&calendar, "VERSION:2.0")
+	writeICSLine(&calendar, "PRODID:-//learn_live//Timetable//EN")
+	writeICSLine(&calendar, "CALSCALE:GREGORIAN")
+	writeICSLine(&calendar, "METHOD:PUBLISH")
+	writeICSLine(&calendar, "X-WR-CALNAME:learn_live")
+	writeICSLine(&calendar, "REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	writeICSLine(&calendar, "X-PUBLISHED-TTL:PT1H")
+
+	for rows.Next() {
+		lesson, err := scanLesson(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		writeLessonEvent(&calendar, lesson, now)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	assignmentFeedQuery := `SELECT ` + assignmentColumns + ` WHERE a.due_at >= $2 AND a.due_at < $3 AND `
+	if isStudent {
+		assignmentFeedQuery += `EXISTS (SELECT 1 FROM person p WHERE p.id = $1 AND ` + assignmentStudentCondition + `)`
+	} else {
+		assignmentFeedQuery += `EXISTS (SELECT 1 FROM professor_subject ps WHERE ps.professor_id = $1 AND ps.subject_id = a.subject_id)
+			AND EXISTS (SELECT 1 FROM professor_group pg WHERE pg.professor_id = $1 AND pg.group_id = a.group_id
+				AND (pg.subgroup_id IS NULL OR pg.subgroup_id = a.subgroup_id))`
+	}
+	assignmentFeedQuery += ` ORDER BY a.due_at, a.id`
+
+	assignmentRows, err := db.QueryContext(ctx, assignmentFeedQuery, personID, now.Add(-calendarFeedPast), now.Add(calendarFeedFuture))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetCalendarFeed QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer assignmentRows.Close()
+
+	for assignmentRows.Next() {
+		assignment, err := scanAssignment(assignmentRows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		writeAssignmentEvent(&calendar, assignment, now)
+	}
+
+	if err := assignmentRows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeICSLine(&calendar, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="learn_live.ics"`)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write([]byte(calendar.String()))
+	if err != nil {
+		log.Printf("Get Calendar Feed failed: %v\n", err)
+	}
+}
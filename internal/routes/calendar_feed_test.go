@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestICSEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Math", "Math"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"Group: 1\nProfessor: Ivanov", `Group: 1\nProfessor: Ivanov`},
+		{"a\r\nb", `a\nb`},
+		{`\;`, `\\\;`},
+	}
+
+	for _, test := range tests {
+		if got := icsEscape(test.value); got != test.want {
+			t.Errorf("icsEscape(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestWriteICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "BEGIN:VEVENT", "BEGIN:VEVENT\r\n"},
+		{"exactly 75 octets", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"76 octets", strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{"continuation lines hold 74 octets", strings.Repeat("a", 200),
+			strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n " + strings.Repeat("a", 51) + "\r\n"},
+		// 74 октета ASCII, затем двухбайтовая "ж" не помещается целиком и переносится
+		{"rune on the boundary", strings.Repeat("a", 74) + "жж",
+			strings.Repeat("a", 74) + "\r\n жж\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b strings.Builder
+			writeICSLine(&b, test.line)
+			if b.String() != test.want {
+				t.Errorf("writeICSLine(%q) = %q, want %q", test.line, b.String(), test.want)
+			}
+		})
+	}
+}
+
+// Свёрнутая строка из многобайтовых символов не длиннее 75 октетов, не разрывает символы
+// и после разворачивания (RFC 5545, 3.1) совпадает с исходной
+func TestWriteICSLineFoldsUTF8(t *testing.T) {
+	for _, line := range []string{
+		"SUMMARY:" + strings.Repeat("Математический анализ; ", 10),
+		"DESCRIPTION:" + strings.Repeat("日本語", 40),
+		"SUMMARY:" + strings.Repeat("a", 67) + strings.Repeat("🙂", 30),
+	} {
+		var b strings.Builder
+		writeICSLine(&b, line)
+
+		folded := strings.TrimSuffix(b.String(), "\r\n")
+		for _, physical := range strings.Split(folded, "\r\n") {
+			if len(physical) > 75 {
+				t.Errorf("line of %d octets: %q", len(physical), physical)
+			}
+			if !utf8.ValidString(physical) {
+				t.Errorf("line splits a character: %q", physical)
+			}
+		}
+
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+			t.Errorf("unfolded line = %q, want %q", unfolded, line)
+		}
+	}
+}
+
+func TestWriteLessonEventMarksExams(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lesson := model.Lesson{
+		ID:          7,
+		SubjectName: "Math",
+		GroupName:   "G1",
+		Topic:       "Final",
+		Kind:        lessonKindExam,
+		StartsAt:    now,
+		EndsAt:      now.Add(2 * time.Hour),
+		UpdatedAt:   now,
+	}
+
+	var b strings.Builder
+	writeLessonEvent(&b, lesson, now)
+
+	for _, want := range []string{"UID:lesson-7@learn_live\r\n", "SUMMARY:Exam: Math: Final\r\n", "CATEGORIES:EXAM\r\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("exam event has no %q:\n%s", want, b.String())
+		}
+	}
+
+	lesson.Kind = lessonKindLesson
+	b.Reset()
+	writeLessonEvent(&b, lesson, now)
+
+	if strings.Contains(b.String(), "Exam") || strings.Contains(b.String(), "CATEGORIES") {
+		t.Errorf("lesson event is marked as an exam:\n%s", b.String())
+	}
+}
+
+func TestWriteAssignmentEvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2024, 5, 10, 21, 0, 0, 0, time.UTC)
+	assignment := model.Assignment{
+		ID:          3,
+		SubjectName: "Math",
+		GroupName:   "G1",
+		Title:       "Homework 1",
+		DueAt:       dueAt,
+		Sequence:    2,
+		UpdatedAt:   now,
+	}
+
+	var b strings.Builder
+	writeAssignmentEvent(&b, assignment, now)
+
+	for _, want := range []string{
+		"UID:assignment-3@learn_live\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTART:20240510T210000Z\r\n",
+		"DTEND:20240510T210000Z\r\n",
+		"SUMMARY:Deadline: Math: Homework 1\r\n",
+		"STATUS:CONFIRMED\r\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("assignment event has no %q:\n%s", want, b.String())
+		}
+	}
+
+	assignment.Cancelled = true
+	b.Reset()
+	writeAssignmentEvent(&b, assignment, now)
+
+	if !strings.Contains(b.String(), "STATUS:CANCELLED\r\n") {
+		t.Errorf("cancelled assignment event is not cancelled:\n%s", b.String())
+	}
+}
@@ -0,0 +1,693 @@
+package routes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCheckInRotationSeconds = 30
+	minCheckInRotationSeconds     = 10
+	maxCheckInRotationSeconds     = 300
+	defaultCheckInDurationMinutes = 15
+	maxCheckInDurationMinutes     = 180
+
+	// checkInCodeGrace - сколько после смены кода ещё принимается предыдущий код,
+	// чтобы студент, вводивший код в момент смены, успел отметиться
+	checkInCodeGrace = 5 * time.Second
+
+	// checkInExpiredSteps - сколько предыдущих кодов узнаются как просроченные, а не как неверные
+	checkInExpiredSteps = 20
+)
+
+var errCheckInWindowNotFound = errors.New("check-in window not found")
+
+// checkInCode вычисляет шестизначный код шага step окна по его секрету, как в TOTP
+func checkInCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// checkInStep возвращает номер кода окна, действующего в момент now
+func checkInStep(window model.CheckInWindow, now time.Time) int64 {
+	return int64(now.Sub(window.OpenedAt) / (time.Duration(window.RotationSeconds) * time.Second))
+}
+
+// checkInStepStart возвращает момент, с которого действует код step
+func checkInStepStart(window model.CheckInWindow, step int64) time.Time {
+	return window.OpenedAt.Add(time.Duration(step) * time.Duration(window.RotationSeconds) * time.Second)
+}
+
+// checkInWindowIsOpen проверяет, что окно не закрыто преподавателем и не истекло
+func checkInWindowIsOpen(window model.CheckInWindow, now time.Time) bool {
+	return window.ClosedAt == nil && now.Before(window.ClosesAt)
+}
+
+// getCheckInWindow возвращает окно отметки и его секрет
+func getCheckInWindow(ctx context.Context, windowID int) (model.CheckInWindow, []byte, error) {
+	var window model.CheckInWindow
+	var secret []byte
+	var closedAt sql.NullTime
+
+	checkInWindowQuery := `
+		SELECT id, lesson_id, rotation_seconds, opened_at, closes_at, closed_at, secret
+		FROM lesson_check_in_window WHERE id = $1`
+
+	err := db.QueryRowContext(ctx, checkInWindowQuery, windowID).Scan(
+		&window.ID,
+		&window.LessonID,
+		&window.RotationSeconds,
+		&window.OpenedAt,
+		&window.ClosesAt,
+		&closedAt,
+		&secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return window, nil, errCheckInWindowNotFound
+	}
+
+	if closedAt.Valid {
+		window.ClosedAt = &closedAt.Time
+	}
+
+	return window, secret, err
+}
+
+// OpenCheckInWindow открывает окно самостоятельной отметки на занятии lesson_id.
+// rotation_seconds и duration_minutes необязательны. У занятия может быть открыто только одно окно.
+func OpenCheckInWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var window model.CheckInWindow
+
+	err = json.NewDecoder(r.Body).Decode(&window)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if window.RotationSeconds == 0 {
+		window.RotationSeconds = defaultCheckInRotationSeconds
+	}
+
+	if window.DurationMinutes == 0 {
+		window.DurationMinutes = defaultCheckInDurationMinutes
+	}
+
+	if window.RotationSeconds < minCheckInRotationSeconds || window.RotationSeconds > maxCheckInRotationSeconds {
+		http.Error(w, fmt.Sprintf("rotation_seconds must be between %d and %d",
+			minCheckInRotationSeconds, maxCheckInRotationSeconds), http.StatusBadRequest)
+		return
+	}
+
+	if window.DurationMinutes < 0 || window.DurationMinutes > maxCheckInDurationMinutes {
+		http.Error(w, fmt.Sprintf("duration_minutes must be between 1 and %d", maxCheckInDurationMinutes), http.StatusBadRequest)
+		return
+	}
+
+	lesson, err := getLesson(window.LessonID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only open check-in for lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Println("rand.Read error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	window.OpenedAt = time.Now()
+	window.ClosesAt = window.OpenedAt.Add(time.Duration(window.DurationMinutes) * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("OpenCheckInWindow BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Истёкшие окна закрываются, чтобы не мешать открыть новое
+	closeExpiredWindowsQuery := `
+		UPDATE lesson_check_in_window SET closed_at = closes_at
+		WHERE lesson_id = $1 AND closed_at IS NULL AND closes_at <= $2;`
+
+	openCheckInWindowQuery := `
+		INSERT INTO lesson_check_in_window (lesson_id, secret, rotation_seconds, opened_by, opened_at, closes_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id;`
+
+	_, err = tx.ExecContext(ctx, closeExpiredWindowsQuery, window.LessonID, window.OpenedAt)
+	if err == nil {
+		err = tx.QueryRowContext(ctx, openCheckInWindowQuery, window.LessonID, secret, window.RotationSeconds,
+			claims.Issuer, window.OpenedAt, window.ClosesAt).Scan(&window.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("OpenCheckInWindow QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique violation, check-in window is already open: ", err)
+			http.Error(w, "Check-in window is already open for this lesson", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(window)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Open check-in window failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CloseCheckInWindow закрывает окно отметки id раньше срока
+func CloseCheckInWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var window model.CheckInWindow
+
+	err = json.NewDecoder(r.Body).Decode(&window)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	window, _, err = getCheckInWindow(ctx, window.ID)
+	if err != nil {
+		if errors.Is(err, errCheckInWindowNotFound) {
+			http.Error(w, "Check-in window not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getCheckInWindow error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	lesson, err := getLesson(window.LessonID)
+	if err != nil {
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only close check-in for lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	closeCheckInWindowQuery := `
+		UPDATE lesson_check_in_window SET closed_at = LEAST(now(), closes_at)
+		WHERE id = $1 AND closed_at IS NULL;`
+
+	_, err = db.ExecContext(ctx, closeCheckInWindowQuery, window.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("CloseCheckInWindow ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Close check-in window successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Close check-in window failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetCheckInCode возвращает текущий код окна window_id для показа на экране цифрами или QR-кодом.
+// Изображение QR-кода сервер не формирует: qr_payload - строка, которую клиент сам кодирует в QR-код,
+// например "learn_live:check-in?window_id=12&code=048213". Клиент запрашивает код заново к expires_at.
+// Доступно только тем, кто может управлять занятием.
+func GetCheckInCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramWindowID := r.URL.Query().Get("window_id")
+
+	windowID, err := strconv.Atoi(paramWindowID)
+	if err != nil {
+		http.Error(w, "window_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	window, secret, err := getCheckInWindow(ctx, windowID)
+	if err != nil {
+		if errors.Is(err, errCheckInWindowNotFound) {
+			http.Error(w, "Check-in window not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getCheckInWindow error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	lesson, err := getLesson(window.LessonID)
+	if err != nil {
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only view check-in codes of lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+
+	if !checkInWindowIsOpen(window, now) {
+		http.Error(w, "Check-in window is closed", http.StatusConflict)
+		return
+	}
+
+	step := checkInStep(window, now)
+
+	code := model.CheckInCode{
+		WindowID:  window.ID,
+		Code:      checkInCode(secret, step),
+		ExpiresAt: checkInStepStart(window, step+1),
+	}
+	code.QRPayload = fmt.Sprintf("learn_live:check-in?window_id=%d&code=%s", window.ID, code.Code)
+
+	if code.ExpiresAt.After(window.ClosesAt) {
+		code.ExpiresAt = window.ClosesAt
+	}
+
+	resp, err := json.Marshal(code)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Check-in Code failed: %v\n", err)
+	}
+}
+
+// CheckInToLesson отмечает текущего студента на занятии по коду окна отметки.
+// Принимается только действующий код (и предыдущий в течение checkInCodeGrace после смены),
+// повторная отметка на том же занятии отклоняется. Опоздание определяется по late_after_minutes правил предмета.
+func CheckInToLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var checkIn model.CheckIn
+
+	err = json.NewDecoder(r.Body).Decode(&checkIn)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	studentID, err := strconv.Atoi(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	window, secret, err := getCheckInWindow(ctx, checkIn.WindowID)
+	if err != nil {
+		if errors.Is(err, errCheckInWindowNotFound) {
+			http.Error(w, "Check-in window not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getCheckInWindow error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	lesson, err := getLesson(window.LessonID)
+	if err != nil {
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hasStudent, err := lessonHasStudent(lesson.ID, studentID, lesson.SubjectID)
+	if err != nil {
+		log.Println("lessonHasStudent error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasStudent {
+		http.Error(w, "You can only check in to lessons that you attend", http.StatusUnauthorized)
+		return
+	}
+
+	if !checkInWindowIsOpen(window, now) {
+		http.Error(w, "Check-in window is closed", http.StatusConflict)
+		return
+	}
+
+	// Код сравнивается с действующим, а в первые секунды после смены - и с предыдущим
+	step := checkInStep(window, now)
+	acceptedStep := int64(-1)
+
+	if hmac.Equal([]byte(checkIn.Code), []byte(checkInCode(secret, step))) {
+		acceptedStep = step
+	} else if step > 0 && now.Sub(checkInStepStart(window, step)) < checkInCodeGrace &&
+		hmac.Equal([]byte(checkIn.Code), []byte(checkInCode(secret, step-1))) {
+		acceptedStep = step - 1
+	}
+
+	if acceptedStep < 0 {
+		for previous := step - 1; previous >= 0 && previous >= step-checkInExpiredSteps; previous-- {
+			if hmac.Equal([]byte(checkIn.Code), []byte(checkInCode(secret, previous))) {
+				http.Error(w, "Check-in code has expired", http.StatusBadRequest)
+				return
+			}
+		}
+
+		http.Error(w, "Invalid check-in code", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := getAttendanceRule(lesson.SubjectID)
+	if err != nil {
+		log.Println("getAttendanceRule error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	attendance := model.LessonAttendance{StudentID: studentID, Status: attendanceStatusPresent}
+
+	minutesLate := int(now.Sub(lesson.StartsAt).Minutes())
+	if minutesLate > rule.LateAfterMinutes {
+		attendance.Status = attendanceStatusLate
+		attendance.MinutesLate = minutesLate
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("CheckInToLesson BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	checkInQuery := `
+		INSERT INTO lesson_check_in (lesson_id, student_id, window_id, code_step, checked_in_at)
+		VALUES ($1, $2, $3, $4, $5);`
+
+	_, err = tx.ExecContext(ctx, checkInQuery, lesson.ID, studentID, window.ID, acceptedStep, now)
+	if err == nil {
+		// Отметка, уже поставленная преподавателем, заменяется только если это пропуск
+		_, err = recordLessonAttendance(ctx, tx, attendanceChange{
+			lessonID:    lesson.ID,
+			studentID:   studentID,
+			status:      attendance.Status,
+			minutesLate: &attendance.MinutesLate,
+			replaceable: []string{attendanceStatusAbsent},
+		}, claims.Issuer)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("CheckInToLesson QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique violation, student already checked in: ", err)
+			http.Error(w, "You have already checked in to this lesson", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := checkAttendanceAlerts(attendanceFilter{subjectID: lesson.SubjectID, studentID: studentID}); err != nil {
+		log.Println("checkAttendanceAlerts error: ", err)
+	}
+
+	resp, err := json.Marshal(attendance)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Check in to lesson failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+	"time"
+)
+
+// Ожидаемые коды посчитаны отдельно по той же схеме: HMAC-SHA256 от номера шага, динамическое усечение, 6 цифр
+func TestCheckInCode(t *testing.T) {
+	tests := []struct {
+		secret string
+		step   int64
+		code   string
+	}{
+		{"12345678901234567890", 0, "875740"},
+		{"12345678901234567890", 1, "247374"},
+		{"12345678901234567890", 59, "465435"},
+		{"other secret", 0, "616685"},
+	}
+
+	for _, test := range tests {
+		if code := checkInCode([]byte(test.secret), test.step); code != test.code {
+			t.Errorf("checkInCode(%q, %d) = %q, want %q", test.secret, test.step, code, test.code)
+		}
+	}
+}
+
+func TestCheckInStep(t *testing.T) {
+	openedAt := time.Date(2024, 10, 7, 10, 0, 0, 0, time.UTC)
+	window := model.CheckInWindow{RotationSeconds: 30, OpenedAt: openedAt}
+
+	tests := []struct {
+		elapsed time.Duration
+		step    int64
+	}{
+		{0, 0},
+		{29*time.Second + 999*time.Millisecond, 0},
+		{30 * time.Second, 1},
+		{59 * time.Second, 1},
+		{15 * time.Minute, 30},
+	}
+
+	for _, test := range tests {
+		step := checkInStep(window, openedAt.Add(test.elapsed))
+		if step != test.step {
+			t.Errorf("checkInStep after %v = %d, want %d", test.elapsed, step, test.step)
+		}
+		if start := checkInStepStart(window, step); start.After(openedAt.Add(test.elapsed)) ||
+			!openedAt.Add(test.elapsed).Before(start.Add(30*time.Second)) {
+			t.Errorf("checkInStepStart(%d) = %v does not contain %v", step, start, test.elapsed)
+		}
+	}
+}
+
+func TestCheckInWindowIsOpen(t *testing.T) {
+	openedAt := time.Date(2024, 10, 7, 10, 0, 0, 0, time.UTC)
+	closesAt := openedAt.Add(15 * time.Minute)
+	closedAt := openedAt.Add(5 * time.Minute)
+
+	tests := []struct {
+		name   string
+		window model.CheckInWindow
+		now    time.Time
+		open   bool
+	}{
+		{"open", model.CheckInWindow{OpenedAt: openedAt, ClosesAt: closesAt}, openedAt.Add(time.Minute), true},
+		{"expired", model.CheckInWindow{OpenedAt: openedAt, ClosesAt: closesAt}, closesAt, false},
+		{"closed by professor", model.CheckInWindow{OpenedAt: openedAt, ClosesAt: closesAt, ClosedAt: &closedAt},
+			openedAt.Add(6 * time.Minute), false},
+	}
+
+	for _, test := range tests {
+		if open := checkInWindowIsOpen(test.window, test.now); open != test.open {
+			t.Errorf("%s: checkInWindowIsOpen = %v, want %v", test.name, open, test.open)
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Статусы индивидуальной записи на предмет
+const (
+	enrollmentStatusEnrolled   = "enrolled"
+	enrollmentStatusWaitlisted = "waitlisted"
+)
+
+// promoteWaitlist зачисляет студентов из листа ожидания в порядке очереди, пока есть свободные места.
+// Если предмет не является факультативом (нет строки в elective), зачисляется весь лист ожидания.
+func promoteWaitlist(ctx context.Context, tx *sql.Tx, subjectID int) error {
+	promoteWaitlistQuery := `
+		UPDATE enrollment SET status = 'enrolled'
+		WHERE id IN (
+			SELECT id FROM enrollment
+			WHERE subject_id = $1 AND status = 'waitlisted'
+			ORDER BY enrolled_at, id
+			LIMIT (
+				SELECT GREATEST(e.capacity - COUNT(en.id), 0)
+				FROM elective e
+				LEFT JOIN enrollment en ON e.subject_id = en.subject_id AND en.status = 'enrolled'
+				WHERE e.subject_id = $1
+				GROUP BY e.capacity));`
+
+	_, err := tx.ExecContext(ctx, promoteWaitlistQuery, subjectID)
+
+	return err
+}
+
+func ListCurrentUserEnrollments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if !isStudent {
+		http.Error(w, "Only students have enrollments", http.StatusUnauthorized)
+		return
+	}
+
+	listCurrentUserEnrollmentsQuery := `
+		SELECT e.id, e.subject_id, s.subject_name, e.status, e.enrolled_at
+		FROM enrollment e
+		JOIN subject s ON e.subject_id = s.id
+		WHERE e.student_id = $1 AND e.status <> 'dropped'
+		ORDER BY e.subject_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listCurrentUserEnrollmentsQuery, claims.Issuer)
+	defer rows.Close()
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListCurrentUserEnrollments QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var enrollment model.Enrollment
+	var enrollments []model.Enrollment
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&enrollment.ID,
+			&enrollment.SubjectID,
+			&enrollment.SubjectName,
+			&enrollment.Status,
+			&enrollment.EnrolledAt); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		enrollments = append(enrollments, enrollment)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(enrollments)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Current User Enrollments failed: %v\n", err)
+	}
+}
+
+func ListEnrollmentsOfASubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if isStudent {
+		http.Error(w, "Only professors and admins can list enrollments of a subject", http.StatusUnauthorized)
+		return
+	}
+
+	// Лист ожидания возвращается в порядке очереди после зачисленных студентов
+	listEnrollmentsOfASubjectQuery := `
+		SELECT e.id, e.student_id, p.firstname, p.lastname, COALESCE(p.group_id, 0),
+		       e.subject_id, s.subject_name, e.status, e.enrolled_at
+		FROM enrollment e
+		JOIN person p ON e.student_id = p.id
+		JOIN subject s ON e.subject_id = s.id
+		WHERE e.subject_id = $1 AND e.status <> 'dropped'
+		ORDER BY e.status, e.enrolled_at, e.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listEnrollmentsOfASubjectQuery, subjectID)
+	defer rows.Close()
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListEnrollmentsOfASubject QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var enrollment model.Enrollment
+	var enrollments []model.Enrollment
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&enrollment.ID,
+			&enrollment.StudentID,
+			&enrollment.StudentFirstname,
+			&enrollment.StudentLastname,
+			&enrollment.StudentGroupID,
+			&enrollment.SubjectID,
+			&enrollment.SubjectName,
+			&enrollment.Status,
+			&enrollment.EnrolledAt); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		enrollments = append(enrollments, enrollment)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(enrollments)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Enrollments Of A Subject failed: %v\n", err)
+	}
+}
+
+// EnrollStudent записывает студента на предмет. Студент может записаться сам, но только на факультатив.
+// Администратор может записать любого студента на любой предмет, например на пересдачу.
+// Если мест на факультативе нет, студент попадает в лист ожидания.
+func EnrollStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin && !isStudent {
+		http.Error(w, "Only students and admins can enroll in subjects", http.StatusUnauthorized)
+		return
+	}
+
+	var enrollment model.Enrollment
+	err = json.NewDecoder(r.Body).Decode(&enrollment)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if isStudent {
+		enrollment.StudentID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Администратор может записать студента без выполненных пререквизитов, передав force=true
+	if !isAdmin || r.URL.Query().Get("force") != "true" {
+		reports, err := prerequisiteReports(0, enrollment.StudentID, enrollment.SubjectID)
+		if err != nil {
+			log.Println("prerequisiteReports error: ", err)
+			http.Error(w, "Error while checking subject prerequisites", http.StatusInternalServerError)
+			return
+		}
+
+		if len(reports) > 0 {
+			resp, err := json.Marshal(reports[0])
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, err = w.Write(resp)
+			if err != nil {
+				log.Printf("EnrollStudent failed: %v\n", err)
+			}
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("EnrollStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var isStaff bool
+
+	isStaffQuery := `SELECT is_admin OR is_professor FROM person WHERE id = $1`
+
+	err = tx.QueryRowContext(ctx, isStaffQuery, enrollment.StudentID).Scan(&isStaff)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && isStaff) {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+
+	// Блокировка строки факультатива сериализует одновременные записи на один предмет
+	var capacity int
+	isElective := true
+
+	if err == nil {
+		electiveCapacityQuery := `SELECT capacity FROM elective WHERE subject_id = $1 FOR UPDATE`
+
+		err = tx.QueryRowContext(ctx, electiveCapacityQuery, enrollment.SubjectID).Scan(&capacity)
+		if errors.Is(err, sql.ErrNoRows) {
+			isElective = false
+			err = nil
+		}
+	}
+
+	if err == nil && isStudent && !isElective {
+		http.Error(w, "You can only enroll yourself in electives", http.StatusBadRequest)
+		return
+	}
+
+	var hasGroupSubject bool
+
+	if err == nil {
+		hasGroupSubjectQuery := `
+			SELECT EXISTS (
+				SELECT FROM person p
+				JOIN group_subject gs ON p.group_id = gs.group_id
+				WHERE p.id = $1 AND gs.subject_id = $2)`
+
+		err = tx.QueryRowContext(ctx, hasGroupSubjectQuery, enrollment.StudentID, enrollment.SubjectID).Scan(&hasGroupSubject)
+	}
+
+	if err == nil && hasGroupSubject {
+		http.Error(w, "Student already has this subject through their group", http.StatusBadRequest)
+		return
+	}
+
+	enrollment.Status = enrollmentStatusEnrolled
+
+	if err == nil && isElective {
+		var enrolledCount int
+
+		enrolledCountQuery := `SELECT COUNT(*) FROM enrollment WHERE subject_id = $1 AND status = 'enrolled'`
+
+		err = tx.QueryRowContext(ctx, enrolledCountQuery, enrollment.SubjectID).Scan(&enrolledCount)
+
+		if enrolledCount >= capacity {
+			enrollment.Status = enrollmentStatusWaitlisted
+		}
+	}
+
+	// Повторная запись после отчисления разрешена и ставит студента в конец очереди
+	if err == nil {
+		enrollQuery := `
+			INSERT INTO enrollment (student_id, subject_id, status)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (student_id, subject_id) DO UPDATE SET status = EXCLUDED.status, enrolled_at = now()
+			WHERE enrollment.status = 'dropped'
+			RETURNING id, enrolled_at`
+
+		err = tx.QueryRowContext(ctx, enrollQuery, enrollment.StudentID, enrollment.SubjectID, enrollment.Status).
+			Scan(&enrollment.ID, &enrollment.EnrolledAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Student is already enrolled or waitlisted for this subject", http.StatusConflict)
+			return
+		}
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("EnrollStudent QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == "23503" {
+			http.Error(w, "Subject not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(enrollment)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("EnrollStudent failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DropEnrollment отчисляет студента с предмета и зачисляет следующего из листа ожидания.
+// Студент отчисляется сам, администратор передаёт student_id.
+func DropEnrollment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin && !isStudent {
+		http.Error(w, "Only students and admins can drop enrollments", http.StatusUnauthorized)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramStudentID := r.URL.Query().Get("student_id")
+	if isStudent {
+		paramStudentID = claims.Issuer
+	}
+
+	studentID, err := strconv.Atoi(paramStudentID)
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("DropEnrollment BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	lockElectiveQuery := `SELECT FROM elective WHERE subject_id = $1 FOR UPDATE`
+
+	_, err = tx.ExecContext(ctx, lockElectiveQuery, subjectID)
+
+	var enrollmentID int
+
+	if err == nil {
+		dropEnrollmentQuery := `
+			UPDATE enrollment SET status = 'dropped'
+			WHERE student_id = $1 AND subject_id = $2 AND status <> 'dropped'
+			RETURNING id`
+
+		err = tx.QueryRowContext(ctx, dropEnrollmentQuery, studentID, subjectID).Scan(&enrollmentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Enrollment not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if err == nil {
+		err = promoteWaitlist(ctx, tx, subjectID)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DropEnrollment QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Dropping enrollment successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("DropEnrollment failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,153 @@
+package routes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// enrollmentResults - ответы базы на запись студента 5 на факультатив 3 с заданной вместимостью и числом записанных
+func enrollmentResults(capacity, enrolledCount int) []fakeResult {
+	return append(fakePerson(true, false),
+		fakeResult{match: "SELECT is_admin OR is_professor FROM person", columns: []string{"is_staff"}, rows: [][]driver.Value{{false}}},
+		fakeResult{match: "SELECT capacity FROM elective", columns: []string{"capacity"}, rows: [][]driver.Value{{int64(capacity)}}},
+		fakeResult{match: "JOIN group_subject gs", columns: []string{"exists"}, rows: [][]driver.Value{{false}}},
+		fakeResult{match: "SELECT COUNT(*) FROM enrollment", columns: []string{"count"}, rows: [][]driver.Value{{int64(enrolledCount)}}},
+		fakeResult{match: "INSERT INTO enrollment", columns: []string{"id", "enrolled_at"},
+			rows: [][]driver.Value{{int64(11), time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}}},
+	)
+}
+
+func TestEnrollStudentWaitlistsWhenElectiveIsFull(t *testing.T) {
+	tests := []struct {
+		name          string
+		capacity      int
+		enrolledCount int
+		want          string
+	}{
+		{"free places", 2, 1, enrollmentStatusEnrolled},
+		{"full", 2, 2, enrollmentStatusWaitlisted},
+		{"over capacity after it was reduced", 1, 3, enrollmentStatusWaitlisted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeDB(t, enrollmentResults(test.capacity, test.enrolledCount)...)
+
+			r := httptest.NewRequest(http.MethodPost, "/enroll-student?force=true", strings.NewReader(`{"student_id": 5, "subject_id": 3}`))
+			r.AddCookie(fakeAuthCookie(t, "1"))
+			w := httptest.NewRecorder()
+
+			EnrollStudent(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+			}
+
+			var enrollment model.Enrollment
+			if err := json.Unmarshal(w.Body.Bytes(), &enrollment); err != nil {
+				t.Fatal(err)
+			}
+
+			if enrollment.Status != test.want {
+				t.Errorf("status = %q, want %q", enrollment.Status, test.want)
+			}
+
+			inserts := fake.executed("INSERT INTO enrollment")
+			if len(inserts) != 1 || inserts[0].args[2] != test.want {
+				t.Errorf("INSERT INTO enrollment = %v, want status %q", inserts, test.want)
+			}
+
+			if len(fake.executed("COMMIT")) != 1 {
+				t.Error("enrollment is not committed")
+			}
+		})
+	}
+}
+
+// Лист ожидания продвигается в той же транзакции, что и отчисление, до её фиксации
+func TestDropEnrollmentPromotesWaitlist(t *testing.T) {
+	fake := useFakeDB(t, append(fakePerson(true, false),
+		fakeResult{match: "SELECT FROM elective"},
+		fakeResult{match: "UPDATE enrollment SET status = 'dropped'", columns: []string{"id"}, rows: [][]driver.Value{{int64(11)}}},
+		fakeResult{match: "UPDATE enrollment SET status = 'enrolled'", affected: 1},
+	)...)
+
+	r := httptest.NewRequest(http.MethodDelete, "/drop-enrollment?subject_id=3&student_id=5", nil)
+	r.AddCookie(fakeAuthCookie(t, "1"))
+	w := httptest.NewRecorder()
+
+	DropEnrollment(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var order []string
+	for _, statement := range fake.executed("") {
+		switch {
+		case strings.Contains(statement.query, "status = 'dropped'"):
+			order = append(order, "drop")
+		case strings.Contains(statement.query, "status = 'enrolled'"):
+			order = append(order, "promote")
+		case statement.query == "COMMIT":
+			order = append(order, "commit")
+		}
+	}
+
+	if strings.Join(order, ",") != "drop,promote,commit" {
+		t.Errorf("statements = %v, want drop, promote, commit", order)
+	}
+}
+
+func TestDropEnrollmentNotFound(t *testing.T) {
+	fake := useFakeDB(t, append(fakePerson(true, false),
+		fakeResult{match: "SELECT FROM elective"},
+		fakeResult{match: "UPDATE enrollment SET status = 'dropped'", columns: []string{"id"}},
+	)...)
+
+	r := httptest.NewRequest(http.MethodDelete, "/drop-enrollment?subject_id=3&student_id=5", nil)
+	r.AddCookie(fakeAuthCookie(t, "1"))
+	w := httptest.NewRecorder()
+
+	DropEnrollment(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	if len(fake.executed("status = 'enrolled'")) != 0 || len(fake.executed("COMMIT")) != 0 {
+		t.Error("waitlist is promoted after a missing enrollment")
+	}
+}
+
+func TestEnrollmentHandlersRequireLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+	}{
+		{"EnrollStudent", EnrollStudent, http.MethodPost},
+		{"DropEnrollment", DropEnrollment, http.MethodDelete},
+		{"ListCurrentUserEnrollments", ListCurrentUserEnrollments, http.MethodGet},
+		{"ListEnrollmentsOfASubject", ListEnrollmentsOfASubject, http.MethodGet},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeAuthCookie(t, "1")
+
+			w := httptest.NewRecorder()
+			test.handler(w, httptest.NewRequest(test.method, "/?subject_id=3", nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
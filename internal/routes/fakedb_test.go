@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResult - ответ тестовой базы на запрос, текст которого содержит match.
+// Ответ с once используется один раз, после него срабатывают следующие подходящие ответы.
+type fakeResult struct {
+	match    string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+	once     bool
+}
+
+// fakeStatement - выполненный запрос с аргументами. BEGIN, COMMIT и ROLLBACK записываются без аргументов.
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDB заменяет Postgres в тестах обработчиков: отвечает на запросы по сценарию
+// и записывает выполненные запросы. Запрос без подходящего ответа завершается ошибкой.
+type fakeDB struct {
+	mu         sync.Mutex
+	results    []fakeResult
+	statements []fakeStatement
+}
+
+// useFakeDB подменяет db на время теста
+func useFakeDB(t *testing.T, results ...fakeResult) *fakeDB {
+	t.Helper()
+
+	fake := &fakeDB{results: results}
+
+	oldDB, oldQueryTimeLimit := db, queryTimeLimit
+	db, queryTimeLimit = sql.OpenDB(fake), 5
+
+	t.Cleanup(func() {
+		db.Close()
+		db, queryTimeLimit = oldDB, oldQueryTimeLimit
+	})
+
+	return fake
+}
+
+// fakeAuthCookie возвращает cookie с JWT пользователя issuer
+func fakeAuthCookie(t *testing.T, issuer string) *http.Cookie {
+	t.Helper()
+
+	oldSecretKey, oldJwtName := secretKey, jwtName
+	secretKey, jwtName = "test-secret", "test-jwt"
+
+	t.Cleanup(func() {
+		secretKey, jwtName = oldSecretKey, oldJwtName
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{Issuer: issuer}).SignedString([]byte(secretKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Cookie{Name: jwtName, Value: token}
+}
+
+// fakePerson отвечает на проверки пользователя из middleware.go
+func fakePerson(isAdmin, isProfessor bool) []fakeResult {
+	return []fakeResult{
+		{match: "SELECT username FROM person", columns: []string{"username"}, rows: [][]driver.Value{{"user"}}},
+		{match: "SELECT is_admin FROM person", columns: []string{"is_admin"}, rows: [][]driver.Value{{isAdmin}}},
+		{match: "SELECT is_professor FROM person", columns: []string{"is_professor"}, rows: [][]driver.Value{{isProfessor}}},
+		{match: "SELECT is_admin, is_professor FROM person", columns: []string{"is_admin", "is_professor"},
+			rows: [][]driver.Value{{isAdmin, isProfessor}}},
+	}
+}
+
+// executed возвращает выполненные запросы, текст которых содержит match
+func (f *fakeDB) executed(match string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var statements []fakeStatement
+	for _, statement := range f.statements {
+		if strings.Contains(statement.query, match) {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	f.statements = append(f.statements, fakeStatement{query: query, args: values})
+}
+
+func (f *fakeDB) find(query string, args []driver.NamedValue) (fakeResult, error) {
+	f.record(query, args)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, result := range f.results {
+		if !strings.Contains(query, result.match) {
+			continue
+		}
+
+		if result.once {
+			f.results = append(f.results[:i:i], f.results[i+1:]...)
+		}
+
+		return result, result.err
+	}
+
+	return fakeResult{}, fmt.Errorf("fakeDB: unexpected query %s", query)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDB: use sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.find(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.find(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(result.affected), nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	tx.db.record("COMMIT", nil)
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package routes
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/livekit/protocol/auth"
 	"log"
@@ -10,6 +13,16 @@ import (
 	"time"
 )
 
+// livekitAPIKey и livekitAPISecret - ключи LiveKit для токенов и проверки вебхуков.
+// Без LIVEKIT_API_KEY токены подписываются ключами dev-сервера, а вебхуки не принимаются:
+// их подпись ключами dev-сервера может подделать кто угодно.
+var (
+	livekitAPIKey    = "devkey"
+	livekitAPISecret = "secret"
+
+	livekitKeysConfigured bool
+)
+
 func GetToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
@@ -51,9 +64,26 @@ func GetToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := getJoinToken(roomID, claims.Subject)
+	canJoin, err := canJoinRoom(claims.Issuer, roomID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		log.Println("canJoinRoom error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canJoin {
+		http.Error(w, "This room is only for students of another subgroup", http.StatusUnauthorized)
+		return
+	}
+
+	res, err := getJoinToken(roomID, claims.Issuer, claims.Subject)
 	if err != nil {
 		http.Error(w, "Failed to get token", http.StatusInternalServerError)
+		return
 	}
 
 	domain := getDomain(r.Host)
@@ -78,8 +108,28 @@ func GetToken(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-func getJoinToken(roomID int, username string) (string, error) {
-	at := auth.NewAccessToken("devkey", "secret")
+// canJoinRoom проверяет, что пользователь может войти в комнату. В комнату подгруппы входят только студенты
+// этой подгруппы, преподаватели и администраторы. Для несуществующей комнаты возвращается sql.ErrNoRows.
+func canJoinRoom(issuer string, roomID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var canJoin bool
+
+	canJoinRoomQuery := `
+		SELECT r.subgroup_id IS NULL OR p.is_admin OR p.is_professor OR p.subgroup_id IS NOT DISTINCT FROM r.subgroup_id
+		FROM room r, person p
+		WHERE r.id = $1 AND p.id = $2`
+
+	err := db.QueryRowContext(ctx, canJoinRoomQuery, roomID, issuer).Scan(&canJoin)
+
+	return canJoin, err
+}
+
+// getJoinToken выдаёт токен комнаты LiveKit. Идентификатор участника - id пользователя, по нему вебхук
+// находит студента, а отображаемое имя - имя пользователя.
+func getJoinToken(roomID int, userID, name string) (string, error) {
+	at := auth.NewAccessToken(livekitAPIKey, livekitAPISecret)
 
 	room := strconv.Itoa(roomID)
 
@@ -89,8 +139,8 @@ func getJoinToken(roomID int, username string) (string, error) {
 		Room:       room,
 	}
 
-	log.Println("getJoinToken. roomID: ", roomID, "username: ", username)
+	log.Println("getJoinToken. roomID: ", roomID, "userID: ", userID)
 	log.Printf("%+v\n", grant)
-	at.AddGrant(grant).SetIdentity(username).SetValidFor(24 * time.Hour)
+	at.AddGrant(grant).SetIdentity(userID).SetName(name).SetValidFor(24 * time.Hour)
 	return at.ToJWT()
 }
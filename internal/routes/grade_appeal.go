@@ -0,0 +1,868 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	appealStatusOpen      = "open"
+	appealStatusAssigned  = "assigned"
+	appealStatusEscalated = "escalated"
+	appealStatusResolved  = "resolved"
+)
+
+var errAppealNotFound = errors.New("grade appeal not found")
+
+const gradeAppealColumns = `
+	a.id, a.student_id, st.firstname, st.lastname, a.grade_kind, a.grade_id, a.subject_id, s.subject_name,
+	a.status, COALESCE(a.professor_id, 0), COALESCE(pr.firstname, ''), COALESCE(pr.lastname, ''),
+	a.reason, COALESCE(a.resolution, ''), COALESCE(a.resolved_grade, ''), COALESCE(a.resolved_by, 0),
+	a.created_at, a.updated_at, a.resolved_at
+	FROM grade_appeal a
+	JOIN person st ON a.student_id = st.id
+	JOIN subject s ON a.subject_id = s.id
+	LEFT JOIN person pr ON a.professor_id = pr.id`
+
+func scanGradeAppeal(row interface{ Scan(...any) error }) (model.GradeAppeal, error) {
+	var appeal model.GradeAppeal
+
+	err := row.Scan(
+		&appeal.ID,
+		&appeal.StudentID,
+		&appeal.StudentFirstname,
+		&appeal.StudentLastname,
+		&appeal.GradeKind,
+		&appeal.GradeID,
+		&appeal.SubjectID,
+		&appeal.SubjectName,
+		&appeal.Status,
+		&appeal.ProfessorID,
+		&appeal.ProfessorFirstname,
+		&appeal.ProfessorLastname,
+		&appeal.Reason,
+		&appeal.Resolution,
+		&appeal.ResolvedGrade,
+		&appeal.ResolvedBy,
+		&appeal.CreatedAt,
+		&appeal.UpdatedAt,
+		&appeal.ResolvedAt)
+
+	return appeal, err
+}
+
+func getGradeAppeal(appealID int) (model.GradeAppeal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	appeal, err := scanGradeAppeal(db.QueryRowContext(ctx, `SELECT `+gradeAppealColumns+` WHERE a.id = $1`, appealID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return appeal, errAppealNotFound
+	}
+
+	return appeal, err
+}
+
+// canAccessAppeal разрешает работу с апелляцией её студенту, назначенному преподавателю и администраторам
+func canAccessAppeal(issuer string, appeal model.GradeAppeal) (bool, error) {
+	if strconv.Itoa(appeal.StudentID) == issuer {
+		return true, nil
+	}
+
+	if appeal.ProfessorID != 0 && strconv.Itoa(appeal.ProfessorID) == issuer {
+		return true, nil
+	}
+
+	return isAdmin(issuer)
+}
+
+// OpenGradeAppeal открывает апелляцию студента на свою оценку (grade_kind, grade_id, reason).
+// Апелляция сразу назначается преподавателю, который последним менял оценку,
+// а если такого нет - преподавателю предмета у группы студента.
+func OpenGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if !isStudent {
+		http.Error(w, "Only students can appeal their grades", http.StatusUnauthorized)
+		return
+	}
+
+	var appeal model.GradeAppeal
+	err = json.NewDecoder(r.Body).Decode(&appeal)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var gradeOwnerQuery string
+	switch appeal.GradeKind {
+	case gradeKindLesson:
+		gradeOwnerQuery = `SELECT subject_id FROM student_grades WHERE id = $1 AND student_id = $2`
+	case gradeKindTotal:
+		gradeOwnerQuery = `SELECT subject_id FROM student_total_grades WHERE id = $1 AND student_id = $2`
+	default:
+		http.Error(w, "grade_kind must be lesson or total", http.StatusBadRequest)
+		return
+	}
+
+	appeal.Reason = strings.TrimSpace(appeal.Reason)
+	if appeal.Reason == "" {
+		http.Error(w, "Appeal reason cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(appeal.Reason) > 5000 {
+		http.Error(w, "Maximum appeal reason length is 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, gradeOwnerQuery, appeal.GradeID, claims.Issuer).Scan(&appeal.SubjectID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Grade not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	openGradeAppealQuery := `
+		INSERT INTO grade_appeal (student_id, grade_kind, grade_id, subject_id, reason, professor_id, status)
+		SELECT $1, $2, $3, $4, $5, pr.id, CASE WHEN pr.id IS NULL THEN 'open' ELSE 'assigned' END
+		FROM (SELECT COALESCE(
+		    (SELECT gh.actor_id
+		     FROM grade_history gh
+		     JOIN person p ON gh.actor_id = p.id AND p.is_professor = true
+		     WHERE gh.grade_kind = $2 AND gh.grade_id = $3
+		     ORDER BY gh.changed_at DESC, gh.id DESC LIMIT 1),
+		    (SELECT ps.professor_id
+		     FROM professor_subject ps
+		     JOIN professor_group pg ON pg.professor_id = ps.professor_id
+		     JOIN person st ON st.id = $1 AND pg.group_id = st.group_id
+		         AND (pg.subgroup_id IS NULL OR pg.subgroup_id = st.subgroup_id)
+		     WHERE ps.subject_id = $4
+		     ORDER BY ps.professor_id LIMIT 1)) AS id) pr
+		RETURNING id`
+
+	err = db.QueryRowContext(
+		ctx,
+		openGradeAppealQuery,
+		claims.Issuer,
+		appeal.GradeKind,
+		appeal.GradeID,
+		appeal.SubjectID,
+		appeal.Reason).Scan(&appeal.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("OpenGradeAppeal QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, appeal already in progress: ", err)
+			http.Error(w, "An appeal for this grade is already in progress", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	appeal, err = getGradeAppeal(appeal.ID)
+	if err != nil {
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(appeal)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("OpenGradeAppeal failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListGradeAppeals возвращает апелляции: студенту - свои, преподавателю - назначенные ему,
+// администратору - все. status необязателен.
+func ListGradeAppeals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", appealStatusOpen, appealStatusAssigned, appealStatusEscalated, appealStatusResolved:
+	default:
+		http.Error(w, "status must be open, assigned, escalated or resolved", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	isProfessor, err := isProfessor(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	studentID, professorID := "0", "0"
+	switch {
+	case isAdmin:
+	case isProfessor:
+		professorID = claims.Issuer
+	default:
+		studentID = claims.Issuer
+	}
+
+	listGradeAppealsQuery := `SELECT ` + gradeAppealColumns + `
+		WHERE ($1 = 0 OR a.student_id = $1) AND ($2 = 0 OR a.professor_id = $2) AND ($3 = '' OR a.status = $3)
+		ORDER BY a.created_at DESC, a.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradeAppealsQuery, studentID, professorID, status)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradeAppeals QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	appeals := []model.GradeAppeal{}
+
+	for rows.Next() {
+		appeal, err := scanGradeAppeal(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		appeals = append(appeals, appeal)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(appeals)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade Appeals failed: %v\n", err)
+	}
+}
+
+// AssignGradeAppeal назначает апелляцию преподавателю предмета. Только для администраторов,
+// в том числе чтобы вернуть переданную им апелляцию преподавателю.
+func AssignGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to assign appeals", http.StatusUnauthorized)
+		return
+	}
+
+	var action model.GradeAppealAction
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	appeal, err := getGradeAppeal(action.AppealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if appeal.Status == appealStatusResolved {
+		http.Error(w, "Appeal is already resolved", http.StatusConflict)
+		return
+	}
+
+	hasSubject, err := professorHasSubject(strconv.Itoa(action.ProfessorID), appeal.SubjectID)
+	if err != nil {
+		log.Println("professorHasSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject {
+		http.Error(w, "Appeals can only be assigned to professors who teach the subject", http.StatusBadRequest)
+		return
+	}
+
+	assignGradeAppealQuery := `
+		UPDATE grade_appeal SET professor_id = $1, status = 'assigned', updated_at = now()
+		WHERE id = $2 AND status <> 'resolved'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, assignGradeAppealQuery, action.ProfessorID, action.AppealID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AssignGradeAppeal QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Assign Grade Appeal Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("AssignGradeAppeal failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// EscalateGradeAppeal передаёт нерешённую апелляцию администратору.
+// Передать может студент или назначенный преподаватель, comment необязателен.
+func EscalateGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var action model.GradeAppealAction
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	appeal, err := getGradeAppeal(action.AppealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	isParticipant := strconv.Itoa(appeal.StudentID) == claims.Issuer ||
+		(appeal.ProfessorID != 0 && strconv.Itoa(appeal.ProfessorID) == claims.Issuer)
+	if !isParticipant {
+		http.Error(w, "Only the student and the assigned professor can escalate an appeal", http.StatusUnauthorized)
+		return
+	}
+
+	if appeal.Status != appealStatusOpen && appeal.Status != appealStatusAssigned {
+		http.Error(w, "Only open or assigned appeals can be escalated", http.StatusConflict)
+		return
+	}
+
+	action.Comment = strings.TrimSpace(action.Comment)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("EscalateGradeAppeal BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	escalateGradeAppealQuery := `
+		UPDATE grade_appeal SET status = 'escalated', updated_at = now()
+		WHERE id = $1 AND status IN ('open', 'assigned')`
+
+	_, err = tx.ExecContext(ctx, escalateGradeAppealQuery, action.AppealID)
+	if err == nil && action.Comment != "" {
+		_, err = tx.ExecContext(ctx, `INSERT INTO grade_appeal_comment (appeal_id, author_id, body) VALUES ($1, $2, $3)`,
+			action.AppealID, claims.Issuer, action.Comment)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("EscalateGradeAppeal QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Escalate Grade Appeal Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("EscalateGradeAppeal failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ResolveGradeAppeal решает апелляцию. Назначенная апелляция решается её преподавателем,
+// любая нерешённая - администратором. Если передана new_grade, оценка меняется
+// с записью в историю, итоговая оценка при этом помечается как ручная.
+func ResolveGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	var action model.GradeAppealAction
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	appeal, err := getGradeAppeal(action.AppealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if appeal.Status == appealStatusResolved {
+		http.Error(w, "Appeal is already resolved", http.StatusConflict)
+		return
+	}
+
+	isAssignedProfessor := appeal.Status == appealStatusAssigned && strconv.Itoa(appeal.ProfessorID) == claims.Issuer
+	if !isAdmin && !isAssignedProfessor {
+		http.Error(w, "Only the assigned professor or an administrator can resolve this appeal", http.StatusUnauthorized)
+		return
+	}
+
+	action.Resolution = strings.TrimSpace(action.Resolution)
+	if action.Resolution == "" {
+		http.Error(w, "Resolution cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	action.NewGrade = strings.TrimSpace(action.NewGrade)
+
+	var lessonGrade *int
+	var gradeProblem string
+
+	if action.NewGrade != "" {
+		if appeal.GradeKind == gradeKindLesson {
+			var grade int
+			grade, err = strconv.Atoi(action.NewGrade)
+			if err != nil {
+				http.Error(w, "new_grade must be an integer for lesson grades", http.StatusBadRequest)
+				return
+			}
+			lessonGrade = &grade
+			gradeProblem, err = lessonGradeProblem(appeal.SubjectID, lessonGrade)
+		} else {
+			if utf8.RuneCountInString(action.NewGrade) > 50 {
+				http.Error(w, "Grade length cannot be bigger than 50 characters", http.StatusBadRequest)
+				return
+			}
+			gradeProblem, err = totalGradeProblem(appeal.SubjectID, action.NewGrade)
+		}
+		if err != nil {
+			log.Println("gradeProblem error: ", err)
+			http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+			return
+		}
+
+		if gradeProblem != "" {
+			http.Error(w, gradeProblem, http.StatusBadRequest)
+			return
+		}
+	}
+
+	historyReason := "Appeal #" + strconv.Itoa(appeal.ID) + ": " + action.Resolution
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("ResolveGradeAppeal BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Статус перечитывается под блокировкой, чтобы апелляцию не решили дважды
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM grade_appeal WHERE id = $1 FOR UPDATE`, appeal.ID).Scan(&status)
+	if err == nil && status != appeal.Status {
+		http.Error(w, "Appeal was changed by another request, try again", http.StatusConflict)
+		return
+	}
+
+	if err == nil && action.NewGrade != "" && appeal.GradeKind == gradeKindLesson {
+		studentGrade := model.StudentGrade{
+			ID:        appeal.GradeID,
+			StudentID: appeal.StudentID,
+			SubjectID: appeal.SubjectID,
+			Grade:     lessonGrade,
+			Reason:    historyReason,
+		}
+
+		// Остальные поля оценки сохраняются как есть
+		currentGradeQuery := `
+			SELECT COALESCE(category_id, 0), lesson_date,
+			       COALESCE(description, ''), COALESCE(professor_comment, ''), COALESCE(lesson_id, 0)
+			FROM student_grades WHERE id = $1`
+
+		err = tx.QueryRowContext(ctx, currentGradeQuery, appeal.GradeID).Scan(
+			&studentGrade.CategoryID,
+			&studentGrade.LessonDate,
+			&studentGrade.Description,
+			&studentGrade.ProfessorComment,
+			&studentGrade.LessonID)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errGradeNotFound
+		}
+		if err == nil {
+			err = updateLessonGrade(ctx, tx, studentGrade, claims.Issuer)
+		}
+	}
+
+	if err == nil && action.NewGrade != "" && appeal.GradeKind == gradeKindTotal {
+		// Меняется обжалованная попытка, а не оценка текущего семестра
+		studentTotalGrade := model.StudentTotalGrade{
+			ID:             appeal.GradeID,
+			StudentID:      appeal.StudentID,
+			SubjectID:      appeal.SubjectID,
+			Grade:          action.NewGrade,
+			OverrideReason: historyReason,
+			Reason:         historyReason,
+		}
+
+		_, err = upsertTotalGrade(ctx, tx, studentTotalGrade, true, claims.Issuer)
+	}
+
+	if err == nil {
+		resolveGradeAppealQuery := `
+			UPDATE grade_appeal
+			SET status = 'resolved', resolution = $1, resolved_grade = NULLIF($2, ''), resolved_by = $3,
+			    resolved_at = now(), updated_at = now()
+			WHERE id = $4`
+
+		_, err = tx.ExecContext(ctx, resolveGradeAppealQuery, action.Resolution, action.NewGrade, claims.Issuer, appeal.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(err, errGradeNotFound) {
+			http.Error(w, "Appealed grade no longer exists", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ResolveGradeAppeal QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Resolve Grade Appeal Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("ResolveGradeAppeal failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
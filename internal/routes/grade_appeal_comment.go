@@ -0,0 +1,589 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Максимальный размер вложения апелляции - 5 МБ
+const maxAppealAttachmentSize = 5 << 20
+
+// GetGradeAppeal возвращает апелляцию appeal_id с комментариями и списком вложений
+func GetGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramAppealID := r.URL.Query().Get("appeal_id")
+
+	appealID, err := strconv.Atoi(paramAppealID)
+	if err != nil {
+		http.Error(w, "appeal_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	appeal, err := getGradeAppeal(appealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canAccess, err := canAccessAppeal(claims.Issuer, appeal)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canAccess {
+		http.Error(w, "You do not have access to this appeal", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	commentsQuery := `
+		SELECT c.id, c.appeal_id, COALESCE(c.author_id, 0), COALESCE(p.firstname, ''), COALESCE(p.lastname, ''),
+		       c.body, c.created_at
+		FROM grade_appeal_comment c
+		LEFT JOIN person p ON c.author_id = p.id
+		WHERE c.appeal_id = $1
+		ORDER BY c.created_at, c.id`
+
+	rows, err := db.QueryContext(ctx, commentsQuery, appealID)
+	if err != nil {
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	appeal.Comments = []model.GradeAppealComment{}
+
+	for rows.Next() {
+		var comment model.GradeAppealComment
+
+		if err := rows.Scan(
+			&comment.ID,
+			&comment.AppealID,
+			&comment.AuthorID,
+			&comment.AuthorFirstname,
+			&comment.AuthorLastname,
+			&comment.Body,
+			&comment.CreatedAt); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		appeal.Comments = append(appeal.Comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	attachmentsQuery := `
+		SELECT id, appeal_id, COALESCE(uploaded_by, 0), file_name, content_type, octet_length(content), created_at
+		FROM grade_appeal_attachment
+		WHERE appeal_id = $1
+		ORDER BY created_at, id`
+
+	attachmentRows, err := db.QueryContext(ctx, attachmentsQuery, appealID)
+	if err != nil {
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer attachmentRows.Close()
+
+	appeal.Attachments = []model.GradeAppealAttachment{}
+
+	for attachmentRows.Next() {
+		var attachment model.GradeAppealAttachment
+
+		if err := attachmentRows.Scan(
+			&attachment.ID,
+			&attachment.AppealID,
+			&attachment.UploadedBy,
+			&attachment.FileName,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.CreatedAt); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		appeal.Attachments = append(appeal.Attachments, attachment)
+	}
+
+	if err := attachmentRows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(appeal)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Grade Appeal failed: %v\n", err)
+	}
+}
+
+// CommentGradeAppeal добавляет комментарий (appeal_id, comment) к нерешённой апелляции
+func CommentGradeAppeal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var action model.GradeAppealAction
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	action.Comment = strings.TrimSpace(action.Comment)
+	if action.Comment == "" {
+		http.Error(w, "Comment cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(action.Comment) > 5000 {
+		http.Error(w, "Maximum comment length is 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	appeal, err := getGradeAppeal(action.AppealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canAccess, err := canAccessAppeal(claims.Issuer, appeal)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canAccess {
+		http.Error(w, "You do not have access to this appeal", http.StatusUnauthorized)
+		return
+	}
+
+	if appeal.Status == appealStatusResolved {
+		http.Error(w, "Appeal is already resolved", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("CommentGradeAppeal BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO grade_appeal_comment (appeal_id, author_id, body) VALUES ($1, $2, $3)`,
+		action.AppealID, claims.Issuer, action.Comment)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `UPDATE grade_appeal SET updated_at = now() WHERE id = $1`, action.AppealID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("CommentGradeAppeal QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Comment Grade Appeal Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("CommentGradeAppeal failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UploadGradeAppealAttachment прикрепляет файл к нерешённой апелляции.
+// Запрос в формате multipart/form-data с полями appeal_id и file.
+func UploadGradeAppealAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	// Запас в 1 МБ на остальные поля формы
+	r.Body = http.MaxBytesReader(w, r.Body, maxAppealAttachmentSize+1<<20)
+
+	err = r.ParseMultipartForm(maxAppealAttachmentSize)
+	if err != nil {
+		http.Error(w, "Request must be multipart/form-data no bigger than 5 MB", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	appealID, err := strconv.Atoi(r.FormValue("appeal_id"))
+	if err != nil {
+		http.Error(w, "appeal_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxAppealAttachmentSize+1))
+	if err != nil {
+		http.Error(w, "Error reading uploaded file", http.StatusInternalServerError)
+		return
+	}
+
+	if len(content) > maxAppealAttachmentSize {
+		http.Error(w, "Maximum attachment size is 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if len(content) == 0 {
+		http.Error(w, "Attachment cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	fileName := filepath.Base(header.Filename)
+	if utf8.RuneCountInString(fileName) > 255 {
+		http.Error(w, "Maximum file name length is 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+
+	appeal, err := getGradeAppeal(appealID)
+	if err != nil {
+		if errors.Is(err, errAppealNotFound) {
+			http.Error(w, "Appeal not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canAccess, err := canAccessAppeal(claims.Issuer, appeal)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canAccess {
+		http.Error(w, "You do not have access to this appeal", http.StatusUnauthorized)
+		return
+	}
+
+	if appeal.Status == appealStatusResolved {
+		http.Error(w, "Appeal is already resolved", http.StatusConflict)
+		return
+	}
+
+	uploadAttachmentQuery := `
+		INSERT INTO grade_appeal_attachment (appeal_id, uploaded_by, file_name, content_type, content)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	attachment := model.GradeAppealAttachment{
+		AppealID:    appealID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        len(content),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, uploadAttachmentQuery, appealID, claims.Issuer, fileName, contentType, content).
+		Scan(&attachment.ID, &attachment.CreatedAt)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UploadGradeAppealAttachment QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	attachment.UploadedBy, _ = strconv.Atoi(claims.Issuer)
+
+	resp, err := json.Marshal(attachment)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("UploadGradeAppealAttachment failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetGradeAppealAttachment отдаёт содержимое вложения attachment_id
+func GetGradeAppealAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramAttachmentID := r.URL.Query().Get("attachment_id")
+
+	attachmentID, err := strconv.Atoi(paramAttachmentID)
+	if err != nil {
+		http.Error(w, "attachment_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var appealID int
+	var fileName, contentType string
+	var content []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	attachmentQuery := `SELECT appeal_id, file_name, content_type, content FROM grade_appeal_attachment WHERE id = $1`
+
+	err = db.QueryRowContext(ctx, attachmentQuery, attachmentID).Scan(&appealID, &fileName, &contentType, &content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetGradeAppealAttachment QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	appeal, err := getGradeAppeal(appealID)
+	if err != nil {
+		log.Println("getGradeAppeal error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canAccess, err := canAccessAppeal(claims.Issuer, appeal)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canAccess {
+		http.Error(w, "You do not have access to this appeal", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(content)
+	if err != nil {
+		log.Printf("Get Grade Appeal Attachment failed: %v\n", err)
+	}
+}
@@ -0,0 +1,744 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func ListGradeCategoriesOfASubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	listGradeCategoriesQuery := `
+		SELECT id, subject_id, category_name, weight, drop_lowest 
+		FROM grade_category 
+		WHERE subject_id = $1 
+		ORDER BY category_name;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradeCategoriesQuery, subjectID)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradeCategoriesOfASubject QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var gradeCategory model.GradeCategory
+	var gradeCategories []model.GradeCategory
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&gradeCategory.ID,
+			&gradeCategory.SubjectID,
+			&gradeCategory.CategoryName,
+			&gradeCategory.Weight,
+			&gradeCategory.DropLowest); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		gradeCategories = append(gradeCategories, gradeCategory)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradeCategories)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade Categories Of A Subject failed: %v\n", err)
+	}
+}
+
+func AddGradeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var gradeCategory model.GradeCategory
+
+	err = json.NewDecoder(r.Body).Decode(&gradeCategory)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	canManage, err := canManageSubject(claims.Issuer, gradeCategory.SubjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only add grade categories of subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	if len([]rune(gradeCategory.CategoryName)) == 0 {
+		http.Error(w, "Grade category name cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if len([]rune(gradeCategory.CategoryName)) > 255 {
+		http.Error(w, "Maximum grade category name length is 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	if gradeCategory.Weight <= 0 {
+		http.Error(w, "Weight must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if gradeCategory.DropLowest < 0 {
+		http.Error(w, "drop_lowest cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	insertGradeCategoryQuery := `
+		INSERT INTO grade_category (subject_id, category_name, weight, drop_lowest) 
+		VALUES ($1, $2::text, $3, $4);`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, insertGradeCategoryQuery, gradeCategory.SubjectID, gradeCategory.CategoryName, gradeCategory.Weight, gradeCategory.DropLowest)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddGradeCategory QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, grade category already exists: ", err)
+			http.Error(w, "Grade category already exists", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Inserting grade category successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("add-grade-category failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateGradeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var gradeCategory model.GradeCategory
+
+	err = json.NewDecoder(r.Body).Decode(&gradeCategory)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	canManage, err := canManageSubject(claims.Issuer, gradeCategory.SubjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only update grade categories of subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	if len([]rune(gradeCategory.CategoryName)) == 0 {
+		http.Error(w, "Grade category name cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if len([]rune(gradeCategory.CategoryName)) > 255 {
+		http.Error(w, "Maximum grade category name length is 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	if gradeCategory.Weight <= 0 {
+		http.Error(w, "Weight must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if gradeCategory.DropLowest < 0 {
+		http.Error(w, "drop_lowest cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	// Категория не переносится в другой предмет
+	updateGradeCategoryQuery := `
+		UPDATE grade_category SET category_name = $1::text, weight = $2, drop_lowest = $3 
+		WHERE id = $4 AND subject_id = $5;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, updateGradeCategoryQuery, gradeCategory.CategoryName, gradeCategory.Weight, gradeCategory.DropLowest, gradeCategory.ID, gradeCategory.SubjectID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UpdateGradeCategory QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, grade category already exists: ", err)
+			http.Error(w, "Grade category already exists", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Update grade category successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Update grade category failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteGradeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	idParam := r.URL.Query().Get("id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		http.Error(w, "id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only delete grade categories of subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	deleteGradeCategoryQuery := `DELETE FROM grade_category WHERE id = $1 AND subject_id = $2;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, deleteGradeCategoryQuery, id, subjectID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DeleteGradeCategory QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Delete grade category successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Delete grade category failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// weightedScores считает взвешенные баллы по предмету subjectID для студента studentID
+// или для всех студентов группы groupID, если studentID = 0.
+// В каждой категории отбрасываются drop_lowest самых низких оценок (но не последняя),
+// вес категории без оценок не учитывается. Оценки без категории и записи без оценки в балл не входят.
+func weightedScores(groupID, studentID, subjectID int) ([]model.WeightedScore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	categoriesQuery := `
+		SELECT id, category_name, weight, drop_lowest 
+		FROM grade_category 
+		WHERE subject_id = $1 
+		ORDER BY category_name`
+
+	categoryRows, err := db.QueryContext(ctx, categoriesQuery, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer categoryRows.Close()
+
+	var categories []model.GradeCategory
+
+	for categoryRows.Next() {
+		var category model.GradeCategory
+		if err := categoryRows.Scan(&category.ID, &category.CategoryName, &category.Weight, &category.DropLowest); err != nil {
+			return nil, err
+		}
+		category.SubjectID = subjectID
+		categories = append(categories, category)
+	}
+
+	if err := categoryRows.Err(); err != nil {
+		return nil, err
+	}
+
+	gradesQuery := `
+		SELECT p.id, p.firstname, p.lastname, COALESCE(sg.category_id, 0), COALESCE(sg.grade, 0)
+		FROM person p
+		LEFT JOIN student_grades sg ON sg.student_id = p.id AND sg.subject_id = $1 
+		    AND sg.category_id IS NOT NULL AND sg.grade IS NOT NULL
+		WHERE p.is_professor = false AND p.is_admin = false 
+		  AND (($3 = 0 AND p.group_id = $2) OR p.id = $3)
+		ORDER BY p.lastname, p.firstname, p.id`
+
+	gradeRows, err := db.QueryContext(ctx, gradesQuery, subjectID, groupID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer gradeRows.Close()
+
+	var scores []model.WeightedScore
+	var grades []map[int][]int
+
+	for gradeRows.Next() {
+		var score model.WeightedScore
+		var categoryID, grade int
+
+		if err := gradeRows.Scan(&score.StudentID, &score.StudentFirstname, &score.StudentLastname, &categoryID, &grade); err != nil {
+			return nil, err
+		}
+
+		if len(scores) == 0 || scores[len(scores)-1].StudentID != score.StudentID {
+			score.SubjectID = subjectID
+			scores = append(scores, score)
+			grades = append(grades, map[int][]int{})
+		}
+
+		if categoryID != 0 {
+			grades[len(grades)-1][categoryID] = append(grades[len(grades)-1][categoryID], grade)
+		}
+	}
+
+	if err := gradeRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range scores {
+		var weightedSum, weightSum float64
+
+		scores[i].Categories = []model.CategoryScore{}
+
+		for _, category := range categories {
+			categoryGrades := grades[i][category.ID]
+			sort.Ints(categoryGrades)
+
+			categoryScore := model.CategoryScore{
+				CategoryID:   category.ID,
+				CategoryName: category.CategoryName,
+				Weight:       category.Weight,
+				GradeCount:   len(categoryGrades),
+			}
+
+			if len(categoryGrades) > 0 {
+				categoryScore.DroppedCount = min(category.DropLowest, len(categoryGrades)-1)
+
+				sum := 0
+				for _, grade := range categoryGrades[categoryScore.DroppedCount:] {
+					sum += grade
+				}
+				average := float64(sum) / float64(len(categoryGrades)-categoryScore.DroppedCount)
+				categoryScore.Average = math.Round(average*100) / 100
+
+				weightedSum += average * category.Weight
+				weightSum += category.Weight
+			}
+
+			scores[i].Categories = append(scores[i].Categories, categoryScore)
+		}
+
+		if weightSum > 0 {
+			scores[i].Score = math.Round(weightedSum/weightSum*100) / 100
+		}
+	}
+
+	return scores, nil
+}
+
+// ListWeightedScoresOfAGroup возвращает взвешенные баллы всех студентов группы по предмету.
+// Доступно администраторам и преподавателям предмета.
+func ListWeightedScoresOfAGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "Only administrators and professors of this subject can view weighted scores of a group", http.StatusUnauthorized)
+		return
+	}
+
+	scores, err := weightedScores(groupID, 0, subjectID)
+	if err != nil {
+		log.Println("weightedScores error: ", err)
+		http.Error(w, "Error while computing weighted scores", http.StatusInternalServerError)
+		return
+	}
+
+	if scores == nil {
+		scores = []model.WeightedScore{}
+	}
+
+	resp, err := json.Marshal(scores)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Weighted Scores Of A Group failed: %v\n", err)
+	}
+}
+
+// GetWeightedScoreOfAStudent возвращает взвешенный балл студента по предмету.
+// Студент получает свой балл, преподаватели и администраторы передают student_id.
+func GetWeightedScoreOfAStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	paramStudentID := r.URL.Query().Get("student_id")
+	if isStudent {
+		paramStudentID = claims.Issuer
+	}
+
+	studentID, err := strconv.Atoi(paramStudentID)
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	scores, err := weightedScores(0, studentID, subjectID)
+	if err != nil {
+		log.Println("weightedScores error: ", err)
+		http.Error(w, "Error while computing weighted scores", http.StatusInternalServerError)
+		return
+	}
+
+	if len(scores) == 0 {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+
+	resp, err := json.Marshal(scores[0])
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Weighted Score Of A Student failed: %v\n", err)
+	}
+}
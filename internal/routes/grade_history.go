@@ -0,0 +1,300 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	gradeKindLesson     = "lesson"
+	gradeKindTotal      = "total"
+	gradeKindAttendance = "attendance" // отметка посещаемости, grade_id - занятие
+)
+
+// gradeDeadlinePassed проверяет, закончился ли семестр termID итоговой оценки. termID = 0 означает
+// оценку без семестра или за текущий семестр, для неё срок не ограничен.
+func gradeDeadlinePassed(ctx context.Context, tx *sql.Tx, termID int) (bool, error) {
+	if termID == 0 {
+		return false, nil
+	}
+
+	var deadlinePassed bool
+
+	deadlinePassedQuery := `SELECT COALESCE(bool_or(end_date < CURRENT_DATE), false) FROM term WHERE id = $1`
+
+	err := tx.QueryRowContext(ctx, deadlinePassedQuery, termID).Scan(&deadlinePassed)
+	if err != nil {
+		return false, err
+	}
+
+	return deadlinePassed, nil
+}
+
+// lessonGradeDeadlinePassed проверяет, закончился ли семестр, в который попадает дата оценки за занятие
+// (дата занятия или выставления оценки). Если дата не попадает ни в один семестр, срок не ограничен.
+func lessonGradeDeadlinePassed(ctx context.Context, tx *sql.Tx, gradeDate time.Time) (bool, error) {
+	var deadlinePassed bool
+
+	deadlinePassedQuery := `
+		SELECT COALESCE(bool_or(end_date < CURRENT_DATE), false)
+		FROM term
+		WHERE $1::date BETWEEN start_date AND end_date`
+
+	err := tx.QueryRowContext(ctx, deadlinePassedQuery, gradeDate).Scan(&deadlinePassed)
+	if err != nil {
+		return false, err
+	}
+
+	return deadlinePassed, nil
+}
+
+func recordGradeChange(ctx context.Context, tx *sql.Tx, change model.GradeChange, actorID string) error {
+	recordGradeChangeQuery := `
+		INSERT INTO grade_history
+		    (grade_kind, grade_id, student_id, subject_id, old_value, new_value, actor_id, reason)
+		VALUES ($1, $2, $3, $4, NULLIF($5::text, ''), NULLIF($6::text, ''), $7, NULLIF($8::text, ''));`
+
+	_, err := tx.ExecContext(
+		ctx,
+		recordGradeChangeQuery,
+		change.GradeKind,
+		change.GradeID,
+		change.StudentID,
+		change.SubjectID,
+		change.OldValue,
+		change.NewValue,
+		actorID,
+		change.Reason)
+
+	return err
+}
+
+// gradeHistory возвращает историю одной оценки (gradeKind и gradeID)
+// либо всех оценок студента по предмету, если gradeID = 0. studentID = 0 не ограничивает студента.
+func gradeHistory(gradeKind string, gradeID, studentID, subjectID int) ([]model.GradeChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	gradeHistoryQuery := `
+		SELECT gh.id, gh.grade_kind, gh.grade_id, gh.student_id, gh.subject_id,
+		       COALESCE(gh.old_value, ''), COALESCE(gh.new_value, ''),
+		       gh.actor_id, COALESCE(p.firstname, ''), COALESCE(p.lastname, ''), gh.changed_at, COALESCE(gh.reason, '')
+		FROM grade_history gh
+		LEFT JOIN person p ON gh.actor_id = p.id
+		WHERE ($2 = 0 OR (gh.grade_kind = $1 AND gh.grade_id = $2))
+		  AND ($3 = 0 OR gh.student_id = $3)
+		  AND ($4 = 0 OR gh.subject_id = $4)
+		ORDER BY gh.changed_at, gh.id`
+
+	rows, err := db.QueryContext(ctx, gradeHistoryQuery, gradeKind, gradeID, studentID, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []model.GradeChange{}
+
+	for rows.Next() {
+		var change model.GradeChange
+
+		if err := rows.Scan(
+			&change.ID,
+			&change.GradeKind,
+			&change.GradeID,
+			&change.StudentID,
+			&change.SubjectID,
+			&change.OldValue,
+			&change.NewValue,
+			&change.ActorID,
+			&change.ActorFirstname,
+			&change.ActorLastname,
+			&change.ChangedAt,
+			&change.Reason); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// ListGradeHistoryOfAGrade возвращает историю одной оценки: grade_kind = lesson или total и grade_id.
+// Для grade_kind = attendance возвращается история отметок посещаемости занятия grade_id.
+// Студенты видят историю только своих оценок.
+func ListGradeHistoryOfAGrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	gradeKind := r.URL.Query().Get("grade_kind")
+	if gradeKind != gradeKindLesson && gradeKind != gradeKindTotal && gradeKind != gradeKindAttendance {
+		http.Error(w, "grade_kind must be lesson, total or attendance", http.StatusBadRequest)
+		return
+	}
+
+	paramGradeID := r.URL.Query().Get("grade_id")
+
+	gradeID, err := strconv.Atoi(paramGradeID)
+	if err != nil || gradeID == 0 {
+		http.Error(w, "grade_id must be a non-zero integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	studentID := 0
+	if isStudent {
+		studentID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	changes, err := gradeHistory(gradeKind, gradeID, studentID, 0)
+	if err != nil {
+		log.Println("gradeHistory error: ", err)
+		http.Error(w, "Error while reading grade history", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(changes)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade History Of A Grade failed: %v\n", err)
+	}
+}
+
+// ListGradeHistoryOfAStudentBySubject возвращает историю всех оценок студента по предмету.
+// Студент получает свою историю, преподаватели и администраторы передают student_id.
+func ListGradeHistoryOfAStudentBySubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	paramStudentID := r.URL.Query().Get("student_id")
+	if isStudent {
+		paramStudentID = claims.Issuer
+	}
+
+	studentID, err := strconv.Atoi(paramStudentID)
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := gradeHistory("", 0, studentID, subjectID)
+	if err != nil {
+		log.Println("gradeHistory error: ", err)
+		http.Error(w, "Error while reading grade history", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(changes)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade History Of A Student By Subject failed: %v\n", err)
+	}
+}
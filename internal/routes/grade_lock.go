@@ -0,0 +1,579 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Максимальная длительность разблокировки ведомости - неделя
+const maxUnlockMinutes = 7 * 24 * 60
+
+// gradeLocked проверяет, закрыта ли по предмету ведомость, в которую входит студент: ведомость его текущей группы
+// или группы, в которой он был на момент закрытия. Действующая разблокировка ведомость открывает.
+// Ведомости блокируются до конца транзакции, чтобы их нельзя было закрыть или разблокировать
+// одновременно с изменением оценки.
+func gradeLocked(ctx context.Context, tx *sql.Tx, studentID, subjectID int) (bool, error) {
+	gradeLockedQuery := `
+		SELECT gl.unlocked_until IS NULL OR gl.unlocked_until < now()
+		FROM grade_lock gl
+		WHERE gl.subject_id = $2
+		  AND ($1 = ANY(gl.student_ids) OR gl.group_id = (SELECT group_id FROM person WHERE id = $1))
+		FOR SHARE`
+
+	rows, err := tx.QueryContext(ctx, gradeLockedQuery, studentID, subjectID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	locked := false
+
+	for rows.Next() {
+		var lockActive bool
+
+		if err := rows.Scan(&lockActive); err != nil {
+			return false, err
+		}
+
+		locked = locked || lockActive
+	}
+
+	return locked, rows.Err()
+}
+
+// FinalizeGrades закрывает ведомость группы по предмету: оценки студентов группы больше не меняются.
+// Доступно администраторам и преподавателям, которые ведут предмет у всей группы.
+// Пока действует разблокировка администратора, ведомость повторно не закрывается.
+func FinalizeGrades(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var gradeLock model.GradeLock
+
+	err = json.NewDecoder(r.Body).Decode(&gradeLock)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	canManage, err := canManageSubject(claims.Issuer, gradeLock.SubjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only finalize grades for subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		teachesGroup, err := professorTeachesGroup(claims.Issuer, gradeLock.GroupID, 0)
+		if err != nil {
+			log.Println("professorTeachesGroup error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if !teachesGroup {
+			http.Error(w, "You can only finalize grades of groups that you teach", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Запоминаются студенты группы на момент закрытия.
+	// Ведомость с действующей разблокировкой не обновляется, тогда запрос не возвращает строк
+	finalizeGradesQuery := `
+		INSERT INTO grade_lock (group_id, subject_id, locked_by, student_ids)
+		VALUES ($1, $2, $3, ARRAY(
+		    SELECT id FROM person WHERE group_id = $1 AND is_professor = false AND is_admin = false))
+		ON CONFLICT (group_id, subject_id)
+		DO UPDATE SET locked_by = EXCLUDED.locked_by, locked_at = now(), unlocked_until = NULL,
+		    student_ids = ARRAY(SELECT DISTINCT unnest(grade_lock.student_ids || EXCLUDED.student_ids))
+		WHERE grade_lock.unlocked_until IS NULL OR grade_lock.unlocked_until < now()
+		RETURNING id;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var lockID int
+
+	err = db.QueryRowContext(ctx, finalizeGradesQuery, gradeLock.GroupID, gradeLock.SubjectID, claims.Issuer).Scan(&lockID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Grades are unlocked by an administrator, finalize them after the unlock ends", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("FinalizeGrades QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Group or subject does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Finalize Grades Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("FinalizeGrades failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UnlockGrades временно открывает закрытую ведомость на duration_minutes минут.
+// Только для администраторов, каждая разблокировка с причиной записывается в grade_unlock.
+func UnlockGrades(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to unlock grades", http.StatusUnauthorized)
+		return
+	}
+
+	var gradeUnlock model.GradeUnlock
+
+	err = json.NewDecoder(r.Body).Decode(&gradeUnlock)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	gradeUnlock.Reason = strings.TrimSpace(gradeUnlock.Reason)
+	if gradeUnlock.Reason == "" {
+		http.Error(w, "Unlock reason cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if gradeUnlock.DurationMinutes <= 0 || gradeUnlock.DurationMinutes > maxUnlockMinutes {
+		http.Error(w, "duration_minutes must be between 1 and "+strconv.Itoa(maxUnlockMinutes), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("UnlockGrades BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	unlockGradesQuery := `
+		UPDATE grade_lock SET unlocked_until = now() + make_interval(mins => $3)
+		WHERE group_id = $1 AND subject_id = $2
+		RETURNING unlocked_until`
+
+	err = tx.QueryRowContext(ctx, unlockGradesQuery, gradeUnlock.GroupID, gradeUnlock.SubjectID, gradeUnlock.DurationMinutes).
+		Scan(&gradeUnlock.UnlockedUntil)
+
+	if err == nil {
+		recordUnlockQuery := `
+			INSERT INTO grade_unlock (group_id, subject_id, admin_id, reason, unlocked_until)
+			VALUES ($1, $2, $3, $4, $5);`
+
+		_, err = tx.ExecContext(
+			ctx,
+			recordUnlockQuery,
+			gradeUnlock.GroupID,
+			gradeUnlock.SubjectID,
+			claims.Issuer,
+			gradeUnlock.Reason,
+			gradeUnlock.UnlockedUntil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Grades of this group and subject are not finalized", http.StatusNotFound)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UnlockGrades QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradeUnlock.UnlockedUntil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("UnlockGrades failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListGradeLocks возвращает закрытые ведомости. group_id и subject_id необязательны.
+func ListGradeLocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID := 0
+	paramGroupID := r.URL.Query().Get("group_id")
+	if paramGroupID != "" {
+		var err error
+		groupID, err = strconv.Atoi(paramGroupID)
+		if err != nil {
+			http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	subjectID := 0
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	if paramSubjectID != "" {
+		var err error
+		subjectID, err = strconv.Atoi(paramSubjectID)
+		if err != nil {
+			http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	listGradeLocksQuery := `
+		SELECT gl.id, gl.group_id, g.group_name, gl.subject_id, s.subject_name, COALESCE(gl.locked_by, 0),
+		       gl.locked_at, gl.unlocked_until, (gl.unlocked_until IS NULL OR gl.unlocked_until < now())
+		FROM grade_lock gl
+		JOIN group_uni g ON gl.group_id = g.id
+		JOIN subject s ON gl.subject_id = s.id
+		WHERE ($1 = 0 OR gl.group_id = $1) AND ($2 = 0 OR gl.subject_id = $2)
+		ORDER BY g.group_name, s.subject_name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradeLocksQuery, groupID, subjectID)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradeLocks QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	gradeLocks := []model.GradeLock{}
+
+	for rows.Next() {
+		var gradeLock model.GradeLock
+
+		if err := rows.Scan(
+			&gradeLock.ID,
+			&gradeLock.GroupID,
+			&gradeLock.GroupName,
+			&gradeLock.SubjectID,
+			&gradeLock.SubjectName,
+			&gradeLock.LockedBy,
+			&gradeLock.LockedAt,
+			&gradeLock.UnlockedUntil,
+			&gradeLock.IsLocked); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		gradeLocks = append(gradeLocks, gradeLock)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradeLocks)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade Locks failed: %v\n", err)
+	}
+}
+
+// ListGradeUnlocks возвращает журнал разблокировок ведомости группы по предмету. Только для администраторов.
+func ListGradeUnlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to view grade unlocks", http.StatusUnauthorized)
+		return
+	}
+
+	listGradeUnlocksQuery := `
+		SELECT gu.id, gu.group_id, gu.subject_id, gu.admin_id, COALESCE(p.firstname, ''), COALESCE(p.lastname, ''),
+		       gu.reason, gu.unlocked_at, gu.unlocked_until
+		FROM grade_unlock gu
+		LEFT JOIN person p ON gu.admin_id = p.id
+		WHERE gu.group_id = $1 AND gu.subject_id = $2
+		ORDER BY gu.unlocked_at, gu.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradeUnlocksQuery, groupID, subjectID)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradeUnlocks QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	gradeUnlocks := []model.GradeUnlock{}
+
+	for rows.Next() {
+		var gradeUnlock model.GradeUnlock
+
+		if err := rows.Scan(
+			&gradeUnlock.ID,
+			&gradeUnlock.GroupID,
+			&gradeUnlock.SubjectID,
+			&gradeUnlock.AdminID,
+			&gradeUnlock.AdminFirstname,
+			&gradeUnlock.AdminLastname,
+			&gradeUnlock.Reason,
+			&gradeUnlock.UnlockedAt,
+			&gradeUnlock.UnlockedUntil); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		gradeUnlocks = append(gradeUnlocks, gradeUnlock)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradeUnlocks)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grade Unlocks failed: %v\n", err)
+	}
+}
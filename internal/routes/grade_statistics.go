@@ -0,0 +1,545 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	statisticsByGroup     = "group"
+	statisticsBySubject   = "subject"
+	statisticsByProfessor = "professor"
+	statisticsByTerm      = "term"
+)
+
+func isValidStatisticsGrouping(groupBy string) bool {
+	switch groupBy {
+	case "", statisticsByGroup, statisticsBySubject, statisticsByProfessor, statisticsByTerm:
+		return true
+	}
+	return false
+}
+
+// gradeStatisticsFilter - необязательные фильтры выборки, 0 означает отсутствие фильтра
+type gradeStatisticsFilter struct {
+	groupID     int
+	subjectID   int
+	professorID int
+	termID      int
+}
+
+// Ключ группировки ($5) и преподаватели, которые ведут предмет у группы студента.
+// Преподаватели подключаются только при группировке или фильтре по преподавателю ($3),
+// иначе строки оценок не размножаются.
+const (
+	gradeStatisticsKeyColumns = `
+		CASE $5 WHEN 'group' THEN COALESCE(g.id, 0) WHEN 'subject' THEN s.id
+		        WHEN 'professor' THEN COALESCE(pr.id, 0) WHEN 'term' THEN COALESCE(t.id, 0) ELSE 0 END,
+		CASE $5 WHEN 'group' THEN COALESCE(g.group_name, '') WHEN 'subject' THEN s.subject_name
+		        WHEN 'professor' THEN COALESCE(pr.professor_name, '') WHEN 'term' THEN COALESCE(t.term_name, '') ELSE '' END`
+
+	gradeStatisticsProfessorJoin = `
+		LEFT JOIN LATERAL (
+		    SELECT DISTINCT pp.id, pp.firstname || ' ' || pp.lastname AS professor_name
+		    FROM professor_subject ps
+		    JOIN professor_group pg ON pg.professor_id = ps.professor_id
+		    JOIN person pp ON pp.id = ps.professor_id
+		    WHERE ($5 = 'professor' OR $3 <> 0) AND ($3 = 0 OR ps.professor_id = $3)
+		      AND ps.subject_id = s.id AND pg.group_id = p.group_id
+		      AND (pg.subgroup_id IS NULL OR pg.subgroup_id = p.subgroup_id)
+		) pr ON true`
+
+	gradeStatisticsFilters = `
+		($1 = 0 OR p.group_id = $1) AND ($2 = 0 OR s.id = $2) AND ($3 = 0 OR pr.id = $3) AND ($4 = 0 OR t.id = $4)`
+)
+
+type gradeStatisticsAccumulator struct {
+	stats       model.GradeStatistics
+	grades      []int
+	histogram   map[string]int
+	totalGrades map[string]int
+}
+
+// gradeStatistics считает статистику оценок с фильтрами filter, сгруппированную по groupBy.
+// Пустой groupBy даёт одну сводку по всей выборке. Оценка за занятие относится к семестру,
+// в который попадает её дата, итоговая оценка - к своему семестру, так что пересдачи не дублируют оценки.
+func gradeStatistics(filter gradeStatisticsFilter, groupBy string) ([]model.GradeStatistics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	accumulators := map[int]*gradeStatisticsAccumulator{}
+	var order []int
+
+	accumulator := func(key int, name string) *gradeStatisticsAccumulator {
+		acc, ok := accumulators[key]
+		if ok {
+			return acc
+		}
+
+		acc = &gradeStatisticsAccumulator{histogram: map[string]int{}, totalGrades: map[string]int{}}
+		switch groupBy {
+		case statisticsByGroup:
+			acc.stats.GroupID, acc.stats.GroupName = key, name
+		case statisticsBySubject:
+			acc.stats.SubjectID, acc.stats.SubjectName = key, name
+		case statisticsByProfessor:
+			acc.stats.ProfessorID, acc.stats.ProfessorName = key, name
+		case statisticsByTerm:
+			acc.stats.TermID, acc.stats.TermName = key, name
+		}
+
+		accumulators[key] = acc
+		order = append(order, key)
+		return acc
+	}
+
+	lessonGradesQuery := `
+		SELECT ` + gradeStatisticsKeyColumns + `, sg.grade
+		FROM student_grades sg
+		JOIN person p ON sg.student_id = p.id
+		LEFT JOIN group_uni g ON p.group_id = g.id
+		JOIN subject s ON sg.subject_id = s.id
+		LEFT JOIN LATERAL (
+		    SELECT id, term_name FROM term
+		    WHERE COALESCE(sg.lesson_date, sg.graded_at::date) BETWEEN start_date AND end_date
+		    ORDER BY start_date DESC
+		    LIMIT 1
+		) t ON true` + gradeStatisticsProfessorJoin + `
+		WHERE ` + gradeStatisticsFilters
+
+	rows, err := db.QueryContext(ctx, lessonGradesQuery, filter.groupID, filter.subjectID, filter.professorID, filter.termID, groupBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key int
+		var grade sql.NullInt64
+		var name string
+
+		if err := rows.Scan(&key, &name, &grade); err != nil {
+			return nil, err
+		}
+
+		acc := accumulator(key, name)
+
+		// NULL означает, что оценки за занятие нет
+		if grade.Valid {
+			acc.grades = append(acc.grades, int(grade.Int64))
+			acc.histogram[strconv.FormatInt(grade.Int64, 10)]++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totalGradesQuery := `
+		SELECT ` + gradeStatisticsKeyColumns + `, stg.grade,
+		       COALESCE(gsv.numeric_value >= gsc.passing_threshold, false)
+		FROM student_total_grades stg
+		JOIN person p ON stg.student_id = p.id
+		LEFT JOIN group_uni g ON p.group_id = g.id
+		JOIN subject s ON stg.subject_id = s.id
+		JOIN grading_scale gsc ON s.grading_scale_id = gsc.id
+		LEFT JOIN grading_scale_value gsv ON gsv.scale_id = gsc.id AND gsv.value = stg.grade
+		LEFT JOIN term t ON stg.term_id = t.id` + gradeStatisticsProfessorJoin + `
+		WHERE stg.grade IS NOT NULL AND ` + gradeStatisticsFilters
+
+	totalRows, err := db.QueryContext(ctx, totalGradesQuery, filter.groupID, filter.subjectID, filter.professorID, filter.termID, groupBy)
+	if err != nil {
+		return nil, err
+	}
+	defer totalRows.Close()
+
+	for totalRows.Next() {
+		var key int
+		var name, grade string
+		var passed bool
+
+		if err := totalRows.Scan(&key, &name, &grade, &passed); err != nil {
+			return nil, err
+		}
+
+		acc := accumulator(key, name)
+		acc.stats.TotalGradeCount++
+		if passed {
+			acc.stats.PassedCount++
+		}
+		acc.totalGrades[grade]++
+	}
+
+	if err := totalRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Посещаемость считается по отметкам занятий с учётом правил предметов. При группировке
+	// по преподавателю учитываются его занятия, по семестру - занятия в датах семестра.
+	for _, key := range order {
+		attendance := attendanceFilter{
+			groupID:     filter.groupID,
+			subjectID:   filter.subjectID,
+			professorID: filter.professorID,
+			termID:      filter.termID,
+		}
+
+		switch groupBy {
+		case statisticsByGroup:
+			attendance.groupID = key
+		case statisticsBySubject:
+			attendance.subjectID = key
+		case statisticsByProfessor:
+			attendance.professorID = key
+		case statisticsByTerm:
+			attendance.termID = key
+		}
+
+		// Нулевой ключ означает отсутствие группы, преподавателя или семестра, по нему отметки не отбираются
+		if groupBy != "" && key == 0 {
+			continue
+		}
+
+		summaries, err := attendanceSummaries(attendance)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, summary := range summaries {
+			accumulators[key].stats.LessonCount += summary.CountedLessons
+			accumulators[key].stats.AttendedCount += summary.AttendedLessons
+		}
+	}
+
+	statistics := []model.GradeStatistics{}
+
+	for _, key := range order {
+		acc := accumulators[key]
+		stats := acc.stats
+
+		stats.GradeCount = len(acc.grades)
+		stats.Mean, stats.Median, stats.StdDev = describeGrades(acc.grades)
+		stats.Histogram = gradeBins(acc.histogram)
+		stats.TotalGradeHistogram = gradeBins(acc.totalGrades)
+
+		if stats.LessonCount > 0 {
+			stats.AttendanceRate = roundHundredths(float64(stats.AttendedCount) / float64(stats.LessonCount))
+		}
+		if stats.TotalGradeCount > 0 {
+			stats.PassRate = roundHundredths(float64(stats.PassedCount) / float64(stats.TotalGradeCount))
+		}
+
+		statistics = append(statistics, stats)
+	}
+
+	return statistics, nil
+}
+
+// describeGrades возвращает среднее, медиану и выборочное стандартное отклонение
+func describeGrades(grades []int) (mean, median, stdDev float64) {
+	if len(grades) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := append([]int(nil), grades...)
+	sort.Ints(sorted)
+
+	var sum float64
+	for _, grade := range sorted {
+		sum += float64(grade)
+	}
+	mean = sum / float64(len(sorted))
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		median = float64(sorted[middle-1]+sorted[middle]) / 2
+	} else {
+		median = float64(sorted[middle])
+	}
+
+	if len(sorted) > 1 {
+		var squares float64
+		for _, grade := range sorted {
+			squares += (float64(grade) - mean) * (float64(grade) - mean)
+		}
+		stdDev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	return roundHundredths(mean), roundHundredths(median), roundHundredths(stdDev)
+}
+
+// gradeBins упорядочивает гистограмму: числовые оценки по значению, остальные по алфавиту после них
+func gradeBins(counts map[string]int) []model.GradeBin {
+	bins := []model.GradeBin{}
+	for grade, count := range counts {
+		bins = append(bins, model.GradeBin{Grade: grade, Count: count})
+	}
+
+	sort.Slice(bins, func(i, j int) bool {
+		left, leftErr := strconv.ParseFloat(bins[i].Grade, 64)
+		right, rightErr := strconv.ParseFloat(bins[j].Grade, 64)
+		switch {
+		case leftErr == nil && rightErr == nil:
+			return left < right
+		case leftErr == nil:
+			return true
+		case rightErr == nil:
+			return false
+		}
+		return bins[i].Grade < bins[j].Grade
+	})
+
+	return bins
+}
+
+// optionalIntParam разбирает необязательный целочисленный параметр запроса, пустой параметр даёт 0
+func optionalIntParam(r *http.Request, name string) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(param)
+}
+
+// canViewStatistics разрешает просмотр статистики преподавателям и администраторам
+func canViewStatistics(issuer string) (bool, error) {
+	isAdmin, err := isAdmin(issuer)
+	if err != nil || isAdmin {
+		return isAdmin, err
+	}
+
+	return isProfessor(issuer)
+}
+
+// GetGradeStatistics возвращает статистику оценок. Фильтры group_id, subject_id, professor_id и term_id
+// необязательны, group_by = group, subject, professor или term разбивает выборку на группы.
+func GetGradeStatistics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter gradeStatisticsFilter
+	var err error
+
+	if filter.groupID, err = optionalIntParam(r, "group_id"); err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.subjectID, err = optionalIntParam(r, "subject_id"); err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.professorID, err = optionalIntParam(r, "professor_id"); err != nil {
+		http.Error(w, "professor_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	if filter.termID, err = optionalIntParam(r, "term_id"); err != nil {
+		http.Error(w, "term_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if !isValidStatisticsGrouping(groupBy) {
+		http.Error(w, "group_by must be group, subject, professor or term", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canView, err := canViewStatistics(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canView {
+		http.Error(w, "Only professors and administrators can view grade statistics", http.StatusUnauthorized)
+		return
+	}
+
+	statistics, err := gradeStatistics(filter, groupBy)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("gradeStatistics deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("gradeStatistics error: ", err)
+		http.Error(w, "Error while calculating grade statistics", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(statistics)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Grade Statistics failed: %v\n", err)
+	}
+}
+
+// CompareGroupsBySubject сравнивает статистику групп, изучающих предмет subject_id.
+// term_id необязателен. Для каждой группы указано отклонение среднего и процента сдачи от общих.
+func CompareGroupsBySubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	termID, err := optionalIntParam(r, "term_id")
+	if err != nil {
+		http.Error(w, "term_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canView, err := canViewStatistics(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canView {
+		http.Error(w, "Only professors and administrators can view grade statistics", http.StatusUnauthorized)
+		return
+	}
+
+	comparison := model.GroupComparison{SubjectID: subjectID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, `SELECT subject_name FROM subject WHERE id = $1`, subjectID).Scan(&comparison.SubjectName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Subject not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filter := gradeStatisticsFilter{subjectID: subjectID, termID: termID}
+
+	overall, err := gradeStatistics(filter, "")
+	if err == nil {
+		comparison.Groups, err = gradeStatistics(filter, statisticsByGroup)
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("gradeStatistics deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("gradeStatistics error: ", err)
+		http.Error(w, "Error while calculating grade statistics", http.StatusInternalServerError)
+		return
+	}
+
+	comparison.Overall = model.GradeStatistics{Histogram: []model.GradeBin{}, TotalGradeHistogram: []model.GradeBin{}}
+	if len(overall) > 0 {
+		comparison.Overall = overall[0]
+	}
+
+	for i := range comparison.Groups {
+		meanDelta := roundHundredths(comparison.Groups[i].Mean - comparison.Overall.Mean)
+		passRateDelta := roundHundredths(comparison.Groups[i].PassRate - comparison.Overall.PassRate)
+		comparison.Groups[i].MeanDelta = &meanDelta
+		comparison.Groups[i].PassRateDelta = &passRateDelta
+	}
+
+	resp, err := json.Marshal(comparison)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Compare Groups By Subject failed: %v\n", err)
+	}
+}
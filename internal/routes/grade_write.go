@@ -0,0 +1,485 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Все изменения student_grades, student_total_grades и lesson_attendance проходят через функции этого файла,
+// чтобы каждое изменение попадало в grade_history в той же транзакции.
+
+var (
+	errGradeNotFound  = errors.New("grade not found")
+	errReasonRequired = errors.New("reason is required for changes after the term end date")
+	errGradeLocked    = errors.New("grades are finalized")
+)
+
+// currentTermSubquery выбирает семестр, идущий на текущую дату. Итоговая оценка без term_id относится к нему.
+const currentTermSubquery = `(SELECT id FROM term WHERE CURRENT_DATE BETWEEN start_date AND end_date ORDER BY start_date DESC LIMIT 1)`
+
+// lessonGradeValue записывает оценку за занятие для истории, пустая строка - оценки нет
+func lessonGradeValue(grade *int) string {
+	if grade == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*grade)
+}
+
+// checkGradeNotLocked возвращает errGradeLocked, если ведомость группы студента по предмету закрыта
+func checkGradeNotLocked(ctx context.Context, tx *sql.Tx, studentID, subjectID int) error {
+	locked, err := gradeLocked(ctx, tx, studentID, subjectID)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		return errGradeLocked
+	}
+
+	return nil
+}
+
+func insertLessonGrade(ctx context.Context, tx *sql.Tx, studentGrade model.StudentGrade, actorID string) (int, error) {
+	if err := checkGradeNotLocked(ctx, tx, studentGrade.StudentID, studentGrade.SubjectID); err != nil {
+		return 0, err
+	}
+
+	// Семестр оценки определяется по её дате, поэтому срок проверяется после вставки в той же транзакции
+	insertGradeQuery := `
+		INSERT INTO student_grades
+		    (student_id, subject_id, grade, category_id,
+		     graded_at, lesson_date, description, professor_comment, lesson_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0), COALESCE($5, now()),
+		        COALESCE($6, (SELECT starts_at::date FROM lesson WHERE id = $9)),
+		        NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, 0))
+		RETURNING id, COALESCE(lesson_date, graded_at::date);`
+
+	var id int
+	var gradeDate time.Time
+
+	err := tx.QueryRowContext(
+		ctx,
+		insertGradeQuery,
+		studentGrade.StudentID,
+		studentGrade.SubjectID,
+		studentGrade.Grade,
+		studentGrade.CategoryID,
+		studentGrade.GradedAt,
+		studentGrade.LessonDate,
+		studentGrade.Description,
+		studentGrade.ProfessorComment,
+		studentGrade.LessonID).Scan(&id, &gradeDate)
+	if err != nil {
+		return 0, err
+	}
+
+	deadlinePassed, err := lessonGradeDeadlinePassed(ctx, tx, gradeDate)
+	if err != nil {
+		return 0, err
+	}
+
+	if deadlinePassed && studentGrade.Reason == "" {
+		return 0, errReasonRequired
+	}
+
+	return id, recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindLesson,
+		GradeID:   id,
+		StudentID: studentGrade.StudentID,
+		SubjectID: studentGrade.SubjectID,
+		NewValue:  lessonGradeValue(studentGrade.Grade),
+		Reason:    studentGrade.Reason,
+	}, actorID)
+}
+
+func updateLessonGrade(ctx context.Context, tx *sql.Tx, studentGrade model.StudentGrade, actorID string) error {
+	var oldGrade *int
+	var oldGradeDate, newGradeDate time.Time
+
+	oldGradeQuery := `
+		SELECT grade, COALESCE(lesson_date, graded_at::date) FROM student_grades
+		WHERE id = $1 AND student_id = $2 AND subject_id = $3
+		FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, oldGradeQuery, studentGrade.ID, studentGrade.StudentID, studentGrade.SubjectID).
+		Scan(&oldGrade, &oldGradeDate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errGradeNotFound
+		}
+		return err
+	}
+
+	if err := checkGradeNotLocked(ctx, tx, studentGrade.StudentID, studentGrade.SubjectID); err != nil {
+		return err
+	}
+
+	// Без graded_at дата оценки не меняется
+	updateGradeQuery := `
+		UPDATE student_grades
+		SET grade = $1, category_id = NULLIF($2, 0), graded_at = COALESCE($3, graded_at),
+		    lesson_date = COALESCE($4, (SELECT starts_at::date FROM lesson WHERE id = $8)),
+		    description = NULLIF($5, ''), professor_comment = NULLIF($6, ''), lesson_id = NULLIF($8, 0)
+		WHERE id = $7
+		RETURNING COALESCE(lesson_date, graded_at::date);`
+
+	err = tx.QueryRowContext(
+		ctx,
+		updateGradeQuery,
+		studentGrade.Grade,
+		studentGrade.CategoryID,
+		studentGrade.GradedAt,
+		studentGrade.LessonDate,
+		studentGrade.Description,
+		studentGrade.ProfessorComment,
+		studentGrade.ID,
+		studentGrade.LessonID).Scan(&newGradeDate)
+	if err != nil {
+		return err
+	}
+
+	// Изменение затрагивает и семестр прежней даты оценки, и семестр новой
+	deadlinePassed, err := lessonGradeDeadlinePassed(ctx, tx, oldGradeDate)
+	if err == nil && !deadlinePassed {
+		deadlinePassed, err = lessonGradeDeadlinePassed(ctx, tx, newGradeDate)
+	}
+	if err != nil {
+		return err
+	}
+
+	if deadlinePassed && studentGrade.Reason == "" {
+		return errReasonRequired
+	}
+
+	return recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindLesson,
+		GradeID:   studentGrade.ID,
+		StudentID: studentGrade.StudentID,
+		SubjectID: studentGrade.SubjectID,
+		OldValue:  lessonGradeValue(oldGrade),
+		NewValue:  lessonGradeValue(studentGrade.Grade),
+		Reason:    studentGrade.Reason,
+	}, actorID)
+}
+
+func deleteLessonGrade(ctx context.Context, tx *sql.Tx, id, studentID, subjectID int, reason, actorID string) error {
+	if err := checkGradeNotLocked(ctx, tx, studentID, subjectID); err != nil {
+		return err
+	}
+
+	var oldGrade *int
+	var gradeDate time.Time
+
+	deleteGradeQuery := `
+		DELETE FROM student_grades
+		WHERE id = $1 AND student_id = $2 AND subject_id = $3
+		RETURNING grade, COALESCE(lesson_date, graded_at::date)`
+
+	err := tx.QueryRowContext(ctx, deleteGradeQuery, id, studentID, subjectID).Scan(&oldGrade, &gradeDate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errGradeNotFound
+		}
+		return err
+	}
+
+	deadlinePassed, err := lessonGradeDeadlinePassed(ctx, tx, gradeDate)
+	if err != nil {
+		return err
+	}
+
+	if deadlinePassed && reason == "" {
+		return errReasonRequired
+	}
+
+	return recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindLesson,
+		GradeID:   id,
+		StudentID: studentID,
+		SubjectID: subjectID,
+		OldValue:  lessonGradeValue(oldGrade),
+		Reason:    reason,
+	}, actorID)
+}
+
+func insertTotalGrade(ctx context.Context, tx *sql.Tx, studentTotalGrade model.StudentTotalGrade, actorID string) (int, error) {
+	if err := checkGradeNotLocked(ctx, tx, studentTotalGrade.StudentID, studentTotalGrade.SubjectID); err != nil {
+		return 0, err
+	}
+
+	deadlinePassed, err := gradeDeadlinePassed(ctx, tx, studentTotalGrade.TermID)
+	if err != nil {
+		return 0, err
+	}
+
+	if deadlinePassed && studentTotalGrade.Reason == "" {
+		return 0, errReasonRequired
+	}
+
+	// Без term_id оценка относится к семестру, идущему на текущую дату
+	insertTotalGradeQuery := `
+		INSERT INTO student_total_grades
+		    (student_id, subject_id, grade, term_id)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, 0), ` + currentTermSubquery + `))
+		RETURNING id;`
+
+	var id int
+
+	err = tx.QueryRowContext(
+		ctx,
+		insertTotalGradeQuery,
+		studentTotalGrade.StudentID,
+		studentTotalGrade.SubjectID,
+		studentTotalGrade.Grade,
+		studentTotalGrade.TermID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindTotal,
+		GradeID:   id,
+		StudentID: studentTotalGrade.StudentID,
+		SubjectID: studentTotalGrade.SubjectID,
+		NewValue:  studentTotalGrade.Grade,
+		Reason:    studentTotalGrade.Reason,
+	}, actorID)
+}
+
+func updateTotalGrade(ctx context.Context, tx *sql.Tx, studentTotalGrade model.StudentTotalGrade, actorID string) error {
+	var oldGrade string
+	var oldTermID int
+
+	oldGradeQuery := `
+		SELECT COALESCE(grade, ''), COALESCE(term_id, 0) FROM student_total_grades
+		WHERE id = $1 AND student_id = $2 AND subject_id = $3
+		FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, oldGradeQuery, studentTotalGrade.ID, studentTotalGrade.StudentID, studentTotalGrade.SubjectID).
+		Scan(&oldGrade, &oldTermID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errGradeNotFound
+		}
+		return err
+	}
+
+	if err := checkGradeNotLocked(ctx, tx, studentTotalGrade.StudentID, studentTotalGrade.SubjectID); err != nil {
+		return err
+	}
+
+	// Проверяются и старый, и новый семестр: перенос оценки в открытый семестр не обходит срок
+	deadlinePassed, err := gradeDeadlinePassed(ctx, tx, oldTermID)
+	if err == nil && !deadlinePassed && studentTotalGrade.TermID != 0 {
+		deadlinePassed, err = gradeDeadlinePassed(ctx, tx, studentTotalGrade.TermID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if deadlinePassed && studentTotalGrade.Reason == "" {
+		return errReasonRequired
+	}
+
+	// Без term_id семестр оценки не меняется
+	updateTotalGradeQuery := `
+		UPDATE student_total_grades
+		SET grade = $1, term_id = COALESCE(NULLIF($2, 0), term_id)
+		WHERE id = $3;`
+
+	_, err = tx.ExecContext(ctx, updateTotalGradeQuery, studentTotalGrade.Grade, studentTotalGrade.TermID, studentTotalGrade.ID)
+	if err != nil {
+		return err
+	}
+
+	return recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindTotal,
+		GradeID:   studentTotalGrade.ID,
+		StudentID: studentTotalGrade.StudentID,
+		SubjectID: studentTotalGrade.SubjectID,
+		OldValue:  oldGrade,
+		NewValue:  studentTotalGrade.Grade,
+		Reason:    studentTotalGrade.Reason,
+	}, actorID)
+}
+
+func deleteTotalGrade(ctx context.Context, tx *sql.Tx, id, studentID, subjectID int, reason, actorID string) error {
+	if err := checkGradeNotLocked(ctx, tx, studentID, subjectID); err != nil {
+		return err
+	}
+
+	var oldGrade string
+	var oldTermID int
+
+	deleteTotalGradeQuery := `
+		DELETE FROM student_total_grades
+		WHERE id = $1 AND student_id = $2 AND subject_id = $3
+		RETURNING COALESCE(grade, ''), COALESCE(term_id, 0)`
+
+	err := tx.QueryRowContext(ctx, deleteTotalGradeQuery, id, studentID, subjectID).Scan(&oldGrade, &oldTermID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errGradeNotFound
+		}
+		return err
+	}
+
+	deadlinePassed, err := gradeDeadlinePassed(ctx, tx, oldTermID)
+	if err != nil {
+		return err
+	}
+
+	if deadlinePassed && reason == "" {
+		return errReasonRequired
+	}
+
+	return recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindTotal,
+		GradeID:   id,
+		StudentID: studentID,
+		SubjectID: subjectID,
+		OldValue:  oldGrade,
+		Reason:    reason,
+	}, actorID)
+}
+
+// upsertTotalGrade выставляет итоговую оценку за семестр studentTotalGrade.TermID (0 - текущий семестр),
+// создавая запись при необходимости. Если задан studentTotalGrade.ID, меняется именно эта запись.
+// При override = true оценка помечается как ручная, причина сохраняется в override_reason.
+// При override = false ручные оценки не трогаются, и функция возвращает false.
+func upsertTotalGrade(ctx context.Context, tx *sql.Tx, studentTotalGrade model.StudentTotalGrade, override bool, actorID string) (bool, error) {
+	var id int
+	var oldIsOverride bool
+
+	oldGradeQuery := `
+		SELECT id, is_override FROM student_total_grades
+		WHERE student_id = $1 AND subject_id = $2
+		  AND (id = $4 OR ($4 = 0 AND term_id IS NOT DISTINCT FROM COALESCE(NULLIF($3, 0), ` + currentTermSubquery + `)))
+		FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, oldGradeQuery, studentTotalGrade.StudentID, studentTotalGrade.SubjectID,
+		studentTotalGrade.TermID, studentTotalGrade.ID).Scan(&id, &oldIsOverride)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if studentTotalGrade.ID != 0 {
+			return false, errGradeNotFound
+		}
+
+		id, err = insertTotalGrade(ctx, tx, studentTotalGrade, actorID)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		if oldIsOverride && !override {
+			return false, nil
+		}
+
+		studentTotalGrade.ID = id
+		if err := updateTotalGrade(ctx, tx, studentTotalGrade, actorID); err != nil {
+			return false, err
+		}
+	}
+
+	if override {
+		overrideQuery := `
+			UPDATE student_total_grades SET is_override = true, override_reason = $1
+			WHERE id = $2;`
+
+		_, err = tx.ExecContext(ctx, overrideQuery, studentTotalGrade.OverrideReason, id)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// attendanceChange - новая отметка посещаемости студента на занятии. minutesLate и note = nil оставляют
+// прежние значения, но минуты опоздания сохраняются, только если студент по-прежнему опоздал.
+// replaceable - отметки, которые можно заменить (nil - любые); студенту без отметки она ставится всегда.
+type attendanceChange struct {
+	lessonID    int
+	studentID   int
+	status      string
+	minutesLate *int
+	note        *string
+	replaceable []string
+}
+
+// recordLessonAttendance записывает отметку посещаемости и изменение статуса в grade_history (grade_id - занятие).
+// Возвращает false, если отметка не изменилась или её нельзя заменить.
+func recordLessonAttendance(ctx context.Context, tx *sql.Tx, change attendanceChange, actorID string) (bool, error) {
+	var subjectID, oldMinutesLate int
+	var oldStatus, oldNote string
+
+	oldAttendanceQuery := `
+		SELECT l.subject_id, COALESCE(la.status, ''), COALESCE(la.minutes_late, 0), COALESCE(la.note, '')
+		FROM lesson l
+		LEFT JOIN lesson_attendance la ON la.lesson_id = l.id AND la.student_id = $2
+		WHERE l.id = $1
+		FOR UPDATE OF l`
+
+	err := tx.QueryRowContext(ctx, oldAttendanceQuery, change.lessonID, change.studentID).
+		Scan(&subjectID, &oldStatus, &oldMinutesLate, &oldNote)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, errLessonNotFound
+		}
+		return false, err
+	}
+
+	if oldStatus != "" && change.replaceable != nil && !slices.Contains(change.replaceable, oldStatus) {
+		return false, nil
+	}
+
+	minutesLate := 0
+	if change.minutesLate != nil {
+		minutesLate = *change.minutesLate
+	} else if change.status == attendanceStatusLate && oldStatus == attendanceStatusLate {
+		minutesLate = oldMinutesLate
+	}
+
+	note := oldNote
+	if change.note != nil {
+		note = *change.note
+	}
+
+	if oldStatus == change.status && oldMinutesLate == minutesLate && oldNote == note {
+		return false, nil
+	}
+
+	if err := checkGradeNotLocked(ctx, tx, change.studentID, subjectID); err != nil {
+		return false, err
+	}
+
+	recordAttendanceQuery := `
+		INSERT INTO lesson_attendance (lesson_id, student_id, status, minutes_late, note, recorded_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		ON CONFLICT (lesson_id, student_id)
+		DO UPDATE SET status = EXCLUDED.status, minutes_late = EXCLUDED.minutes_late, note = EXCLUDED.note,
+		    recorded_by = EXCLUDED.recorded_by, recorded_at = now();`
+
+	_, err = tx.ExecContext(ctx, recordAttendanceQuery, change.lessonID, change.studentID, change.status,
+		minutesLate, note, actorID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, recordGradeChange(ctx, tx, model.GradeChange{
+		GradeKind: gradeKindAttendance,
+		GradeID:   change.lessonID,
+		StudentID: change.studentID,
+		SubjectID: subjectID,
+		OldValue:  oldStatus,
+		NewValue:  change.status,
+	}, actorID)
+}
@@ -0,0 +1,375 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+	"time"
+)
+
+// beginFakeTx открывает транзакцию в тестовой базе с ответами results
+func beginFakeTx(t *testing.T, results ...fakeResult) (*fakeDB, *sql.Tx) {
+	t.Helper()
+
+	fake := useFakeDB(t, results...)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	return fake, tx
+}
+
+// Ведомость не закрыта
+var notLockedResult = fakeResult{match: "FROM grade_lock gl", columns: []string{"locked"}}
+
+var gradeHistoryResult = fakeResult{match: "INSERT INTO grade_history", affected: 1}
+
+func deadlineResult(match string, passed bool) fakeResult {
+	return fakeResult{match: match, columns: []string{"deadline_passed"}, rows: [][]driver.Value{{passed}}, once: true}
+}
+
+func TestLessonGradeAfterTermEndRequiresReason(t *testing.T) {
+	tests := []struct {
+		name           string
+		deadlinePassed bool
+		reason         string
+		wantErr        error
+	}{
+		{"open term", false, "", nil},
+		{"closed term without reason", true, "", errReasonRequired},
+		{"closed term with reason", true, "retake", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, tx := beginFakeTx(t,
+				notLockedResult,
+				fakeResult{match: "INSERT INTO student_grades", columns: []string{"id", "grade_date"},
+					rows: [][]driver.Value{{int64(9), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}}},
+				deadlineResult("BETWEEN start_date AND end_date", test.deadlinePassed),
+				gradeHistoryResult,
+			)
+
+			grade := 5
+			id, err := insertLessonGrade(context.Background(), tx,
+				model.StudentGrade{StudentID: 5, SubjectID: 3, Grade: &grade, Reason: test.reason}, "1")
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("insertLessonGrade error = %v, want %v", err, test.wantErr)
+			}
+
+			history := fake.executed("INSERT INTO grade_history")
+			if test.wantErr != nil {
+				if len(history) != 0 {
+					t.Error("grade history is recorded for a refused grade")
+				}
+				return
+			}
+
+			if id != 9 {
+				t.Errorf("id = %d, want 9", id)
+			}
+
+			// grade_kind, grade_id, student_id, subject_id, old_value, new_value, actor_id, reason
+			want := []driver.Value{gradeKindLesson, int64(9), int64(5), int64(3), "", "5", "1", test.reason}
+			if len(history) != 1 || !equalValues(history[0].args, want) {
+				t.Errorf("grade history = %v, want %v", history, want)
+			}
+		})
+	}
+}
+
+// Перенос итоговой оценки между семестрами требует причины, если закрыт любой из них
+func TestUpdateTotalGradeChecksOldAndNewTerm(t *testing.T) {
+	tests := []struct {
+		name          string
+		newTermID     int
+		oldTermClosed bool
+		newTermClosed bool
+		wantErr       error
+		wantChecks    int
+	}{
+		{"same term, open", 0, false, false, nil, 1},
+		{"same term, closed", 0, true, false, errReasonRequired, 1},
+		{"from closed to open term", 2, true, false, errReasonRequired, 1},
+		{"from open to closed term", 2, false, true, errReasonRequired, 2},
+		{"between open terms", 2, false, false, nil, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, tx := beginFakeTx(t,
+				fakeResult{match: "SELECT COALESCE(grade, ''), COALESCE(term_id, 0) FROM student_total_grades",
+					columns: []string{"grade", "term_id"}, rows: [][]driver.Value{{"4", int64(1)}}},
+				notLockedResult,
+				deadlineResult("FROM term WHERE id = $1", test.oldTermClosed),
+				deadlineResult("FROM term WHERE id = $1", test.newTermClosed),
+				fakeResult{match: "UPDATE student_total_grades", affected: 1},
+				gradeHistoryResult,
+			)
+
+			err := updateTotalGrade(context.Background(), tx,
+				model.StudentTotalGrade{ID: 7, StudentID: 5, SubjectID: 3, Grade: "5", TermID: test.newTermID}, "1")
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("updateTotalGrade error = %v, want %v", err, test.wantErr)
+			}
+
+			checks := fake.executed("FROM term WHERE id = $1")
+			if len(checks) != test.wantChecks {
+				t.Errorf("term deadline checked %d times, want %d", len(checks), test.wantChecks)
+			}
+			if len(checks) > 0 && checks[0].args[0] != int64(1) {
+				t.Errorf("first deadline check of term %v, want the old term 1", checks[0].args[0])
+			}
+
+			if updated := len(fake.executed("UPDATE student_total_grades")) == 1; updated != (test.wantErr == nil) {
+				t.Errorf("total grade updated = %v, want %v", updated, test.wantErr == nil)
+			}
+		})
+	}
+}
+
+// Итоговая оценка без семестра не ограничена сроком
+func TestGradeDeadlineWithoutTerm(t *testing.T) {
+	fake, tx := beginFakeTx(t)
+
+	passed, err := gradeDeadlinePassed(context.Background(), tx, 0)
+	if err != nil || passed {
+		t.Errorf("gradeDeadlinePassed(0) = %v, %v, want false, nil", passed, err)
+	}
+
+	if len(fake.executed("FROM term")) != 0 {
+		t.Error("term is queried for a grade without a term")
+	}
+}
+
+func equalValues(got, want []driver.Value) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestGradeLockedByAnyActiveLock(t *testing.T) {
+	tests := []struct {
+		name  string
+		locks [][]driver.Value
+		want  bool
+	}{
+		{"no lock", nil, false},
+		{"active lock", [][]driver.Value{{true}}, true},
+		{"unlocked for now", [][]driver.Value{{false}}, false},
+		// Например, ведомость группы временно открыта, а ведомость студента - нет
+		{"one of several locks is active", [][]driver.Value{{false}, {true}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, tx := beginFakeTx(t, fakeResult{match: "FROM grade_lock gl", columns: []string{"locked"}, rows: test.locks})
+
+			locked, err := gradeLocked(context.Background(), tx, 5, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if locked != test.want {
+				t.Errorf("gradeLocked = %v, want %v", locked, test.want)
+			}
+		})
+	}
+}
+
+// Закрытая ведомость запрещает любые изменения оценок, и в базу ничего не пишется
+func TestGradeWritesRefuseFinalizedGrades(t *testing.T) {
+	grade := 5
+
+	tests := []struct {
+		name  string
+		write func(tx *sql.Tx) error
+	}{
+		{"insert lesson grade", func(tx *sql.Tx) error {
+			_, err := insertLessonGrade(context.Background(), tx, model.StudentGrade{StudentID: 5, SubjectID: 3, Grade: &grade}, "1")
+			return err
+		}},
+		{"update lesson grade", func(tx *sql.Tx) error {
+			return updateLessonGrade(context.Background(), tx, model.StudentGrade{ID: 9, StudentID: 5, SubjectID: 3, Grade: &grade}, "1")
+		}},
+		{"delete lesson grade", func(tx *sql.Tx) error {
+			return deleteLessonGrade(context.Background(), tx, 9, 5, 3, "", "1")
+		}},
+		{"insert total grade", func(tx *sql.Tx) error {
+			_, err := insertTotalGrade(context.Background(), tx, model.StudentTotalGrade{StudentID: 5, SubjectID: 3, Grade: "5"}, "1")
+			return err
+		}},
+		{"update total grade", func(tx *sql.Tx) error {
+			return updateTotalGrade(context.Background(), tx, model.StudentTotalGrade{ID: 7, StudentID: 5, SubjectID: 3, Grade: "5"}, "1")
+		}},
+		{"delete total grade", func(tx *sql.Tx) error {
+			return deleteTotalGrade(context.Background(), tx, 7, 5, 3, "", "1")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, tx := beginFakeTx(t,
+				fakeResult{match: "FROM grade_lock gl", columns: []string{"locked"}, rows: [][]driver.Value{{true}}},
+				// Блокировки строк перед проверкой
+				fakeResult{match: "SELECT grade, COALESCE(lesson_date, graded_at::date) FROM student_grades",
+					columns: []string{"grade", "grade_date"}, rows: [][]driver.Value{{int64(4), time.Now()}}},
+				fakeResult{match: "SELECT COALESCE(grade, ''), COALESCE(term_id, 0) FROM student_total_grades",
+					columns: []string{"grade", "term_id"}, rows: [][]driver.Value{{"4", int64(1)}}},
+			)
+
+			if err := test.write(tx); !errors.Is(err, errGradeLocked) {
+				t.Fatalf("error = %v, want errGradeLocked", err)
+			}
+
+			for _, write := range []string{"INSERT INTO", "UPDATE student", "DELETE FROM"} {
+				if statements := fake.executed(write); len(statements) != 0 {
+					t.Errorf("finalized grade is written: %v", statements)
+				}
+			}
+		})
+	}
+}
+
+func oldAttendanceResult(status string, minutesLate int, note string) fakeResult {
+	return fakeResult{match: "LEFT JOIN lesson_attendance la ON la.lesson_id = l.id",
+		columns: []string{"subject_id", "status", "minutes_late", "note"},
+		rows:    [][]driver.Value{{int64(3), status, int64(minutesLate), note}}}
+}
+
+func TestRecordLessonAttendance(t *testing.T) {
+	ten := 10
+
+	tests := []struct {
+		name            string
+		oldStatus       string
+		oldMinutesLate  int
+		change          attendanceChange
+		wantChanged     bool
+		wantMinutesLate int64
+	}{
+		{"first mark", "", 0,
+			attendanceChange{status: attendanceStatusPresent}, true, 0},
+		{"replaceable mark", attendanceStatusAbsent, 0,
+			attendanceChange{status: attendanceStatusPresent, replaceable: []string{attendanceStatusAbsent}}, true, 0},
+		{"mark that cannot be replaced", attendanceStatusExcused, 0,
+			attendanceChange{status: attendanceStatusPresent, replaceable: []string{attendanceStatusAbsent}}, false, 0},
+		{"same mark", attendanceStatusPresent, 0,
+			attendanceChange{status: attendanceStatusPresent}, false, 0},
+		{"still late keeps the minutes", attendanceStatusLate, 10,
+			attendanceChange{status: attendanceStatusLate}, false, 0},
+		{"new minutes of lateness", attendanceStatusLate, 5,
+			attendanceChange{status: attendanceStatusLate, minutesLate: &ten}, true, 10},
+		{"no longer late drops the minutes", attendanceStatusLate, 10,
+			attendanceChange{status: attendanceStatusPresent}, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, tx := beginFakeTx(t,
+				oldAttendanceResult(test.oldStatus, test.oldMinutesLate, ""),
+				notLockedResult,
+				fakeResult{match: "INSERT INTO lesson_attendance", affected: 1},
+				gradeHistoryResult,
+			)
+
+			test.change.lessonID, test.change.studentID = 2, 5
+
+			changed, err := recordLessonAttendance(context.Background(), tx, test.change, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changed != test.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, test.wantChanged)
+			}
+
+			writes := fake.executed("INSERT INTO lesson_attendance")
+			history := fake.executed("INSERT INTO grade_history")
+
+			if !test.wantChanged {
+				if len(writes) != 0 || len(history) != 0 {
+					t.Errorf("unchanged attendance is written: %v %v", writes, history)
+				}
+				return
+			}
+
+			// lesson_id, student_id, status, minutes_late, note, recorded_by
+			if len(writes) != 1 || writes[0].args[2] != test.change.status || writes[0].args[3] != test.wantMinutesLate {
+				t.Errorf("attendance write = %v, want status %q and %d minutes late", writes, test.change.status, test.wantMinutesLate)
+			}
+
+			want := []driver.Value{gradeKindAttendance, int64(2), int64(5), int64(3), test.oldStatus, test.change.status, "1", ""}
+			if len(history) != 1 || !equalValues(history[0].args, want) {
+				t.Errorf("grade history = %v, want %v", history, want)
+			}
+		})
+	}
+}
+
+func TestRecordLessonAttendanceRefusesFinalizedGrades(t *testing.T) {
+	fake, tx := beginFakeTx(t,
+		oldAttendanceResult(attendanceStatusAbsent, 0, ""),
+		fakeResult{match: "FROM grade_lock gl", columns: []string{"locked"}, rows: [][]driver.Value{{true}}},
+	)
+
+	_, err := recordLessonAttendance(context.Background(), tx,
+		attendanceChange{lessonID: 2, studentID: 5, status: attendanceStatusPresent}, "1")
+	if !errors.Is(err, errGradeLocked) {
+		t.Fatalf("error = %v, want errGradeLocked", err)
+	}
+
+	if writes := fake.executed("INSERT INTO"); len(writes) != 0 {
+		t.Errorf("finalized attendance is written: %v", writes)
+	}
+}
+
+func TestRecordLessonAttendanceLessonNotFound(t *testing.T) {
+	_, tx := beginFakeTx(t, fakeResult{match: "LEFT JOIN lesson_attendance la ON la.lesson_id = l.id",
+		columns: []string{"subject_id", "status", "minutes_late", "note"}})
+
+	_, err := recordLessonAttendance(context.Background(), tx,
+		attendanceChange{lessonID: 2, studentID: 5, status: attendanceStatusPresent}, "1")
+	if !errors.Is(err, errLessonNotFound) {
+		t.Errorf("error = %v, want errLessonNotFound", err)
+	}
+}
+
+// Отметки занятия сохраняются в одной транзакции: закрытая ведомость одного студента отменяет все
+func TestSaveLessonAttendanceRollsBackOnFinalizedGrades(t *testing.T) {
+	fake := useFakeDB(t,
+		oldAttendanceResult("", 0, ""),
+		lockResult(false),
+		lockResult(true),
+		fakeResult{match: "INSERT INTO lesson_attendance", affected: 1},
+		gradeHistoryResult,
+	)
+
+	err := saveLessonAttendance(context.Background(), 2, []model.LessonAttendance{
+		{StudentID: 5, Status: attendanceStatusPresent},
+		{StudentID: 6, Status: attendanceStatusAbsent},
+	}, nil, "1")
+	if !errors.Is(err, errGradeLocked) {
+		t.Fatalf("error = %v, want errGradeLocked", err)
+	}
+
+	if len(fake.executed("COMMIT")) != 0 || len(fake.executed("ROLLBACK")) != 1 {
+		t.Error("attendance is committed after a finalized grade")
+	}
+}
@@ -0,0 +1,428 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var errGradebookNotFound = errors.New("group or subject not found")
+
+// gradebookColumnKey - ключ столбца журнала: занятие или, для оценок без занятия, дата и номер оценки за него
+type gradebookColumnKey struct {
+	lessonID int
+	date     string
+	ordinal  int
+}
+
+// buildGradebook собирает журнал группы по предмету: строка на студента, столбец на занятие.
+// Столбцы общие для всей группы: занятия расписания по lesson_id, старые оценки без занятия - по дате занятия
+// или выставления оценки. Посещаемость берётся из отметок lesson_attendance с учётом правил предмета.
+// subgroupID = 0 не ограничивает подгруппу.
+func buildGradebook(groupID, subjectID, subgroupID int) (model.Gradebook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	gradebook := model.Gradebook{
+		GroupID:   groupID,
+		SubjectID: subjectID,
+		Lessons:   []model.GradebookLesson{},
+		Rows:      []model.GradebookRow{},
+	}
+
+	namesQuery := `SELECT g.group_name, s.subject_name FROM group_uni g, subject s WHERE g.id = $1 AND s.id = $2`
+
+	err := db.QueryRowContext(ctx, namesQuery, groupID, subjectID).Scan(&gradebook.GroupName, &gradebook.SubjectName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return gradebook, errGradebookNotFound
+		}
+		return gradebook, err
+	}
+
+	studentsQuery := `
+	SELECT p.id, p.firstname, p.lastname, COALESCE(p.subgroup_id, 0), COALESCE(sub.subgroup_name, ''),
+	       COALESCE(stg.grade, '')
+	FROM person p
+	LEFT JOIN subgroup sub ON p.subgroup_id = sub.id` + latestTotalGradeJoin("p.id", "$2") + `
+	WHERE p.group_id = $1 AND p.is_professor = false AND p.is_admin = false AND ($3 = 0 OR p.subgroup_id = $3)
+	ORDER BY p.lastname, p.firstname, p.id`
+
+	rows, err := db.QueryContext(ctx, studentsQuery, groupID, subjectID, subgroupID)
+	if err != nil {
+		return gradebook, err
+	}
+	defer rows.Close()
+
+	rowIndexes := map[int]int{}
+
+	for rows.Next() {
+		var row model.GradebookRow
+
+		if err := rows.Scan(
+			&row.StudentID,
+			&row.StudentFirstname,
+			&row.StudentLastname,
+			&row.StudentSubgroupID,
+			&row.StudentSubgroupName,
+			&row.TotalGrade); err != nil {
+			return gradebook, err
+		}
+
+		if _, ok := rowIndexes[row.StudentID]; ok {
+			continue
+		}
+
+		rowIndexes[row.StudentID] = len(gradebook.Rows)
+		gradebook.Rows = append(gradebook.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return gradebook, err
+	}
+
+	columns := map[gradebookColumnKey]model.GradebookLesson{}
+
+	// Занятия группы попадают в журнал, даже если за них ещё нет оценок
+	lessonsQuery := `
+	SELECT id, starts_at::date, starts_at
+	FROM lesson
+	WHERE group_id = $1 AND subject_id = $2 AND cancelled_at IS NULL
+	  AND ($3 = 0 OR subgroup_id IS NULL OR subgroup_id = $3)`
+
+	lessonRows, err := db.QueryContext(ctx, lessonsQuery, groupID, subjectID, subgroupID)
+	if err != nil {
+		return gradebook, err
+	}
+	defer lessonRows.Close()
+
+	for lessonRows.Next() {
+		var lesson model.GradebookLesson
+		var startsAt time.Time
+
+		if err := lessonRows.Scan(&lesson.LessonID, &lesson.Date, &startsAt); err != nil {
+			return gradebook, err
+		}
+
+		lesson.StartsAt = &startsAt
+		lesson.Ordinal = 1
+		columns[gradebookColumnKey{lessonID: lesson.LessonID, ordinal: 1}] = lesson
+	}
+
+	if err := lessonRows.Err(); err != nil {
+		return gradebook, err
+	}
+
+	type gradebookGrade struct {
+		key       gradebookColumnKey
+		studentID int
+		cell      model.GradebookCell
+	}
+
+	// Оценки нумеруются внутри занятия (или даты для оценок без занятия) в порядке выставления
+	gradesQuery := `
+	SELECT sg.id, sg.student_id, COALESCE(sg.lesson_id, 0),
+	       COALESCE(l.starts_at::date, sg.lesson_date, sg.graded_at::date), l.starts_at,
+	       sg.grade, sg.lesson_date, COALESCE(sg.description, '')
+	FROM student_grades sg
+	JOIN person p ON sg.student_id = p.id
+	LEFT JOIN lesson l ON sg.lesson_id = l.id
+	WHERE sg.subject_id = $2 AND p.group_id = $1 AND p.is_professor = false AND p.is_admin = false
+	  AND ($3 = 0 OR p.subgroup_id = $3)
+	ORDER BY sg.student_id, sg.graded_at, sg.id`
+
+	gradeRows, err := db.QueryContext(ctx, gradesQuery, groupID, subjectID, subgroupID)
+	if err != nil {
+		return gradebook, err
+	}
+	defer gradeRows.Close()
+
+	var grades []gradebookGrade
+	ordinals := map[int]map[gradebookColumnKey]int{}
+
+	for gradeRows.Next() {
+		var grade gradebookGrade
+		var lesson model.GradebookLesson
+
+		if err := gradeRows.Scan(
+			&grade.cell.ID,
+			&grade.studentID,
+			&lesson.LessonID,
+			&lesson.Date,
+			&lesson.StartsAt,
+			&grade.cell.Grade,
+			&grade.cell.LessonDate,
+			&grade.cell.Description); err != nil {
+			return gradebook, err
+		}
+
+		grade.key.lessonID = lesson.LessonID
+		if lesson.LessonID == 0 {
+			grade.key.date = lesson.Date.Format(time.DateOnly)
+		}
+
+		if ordinals[grade.studentID] == nil {
+			ordinals[grade.studentID] = map[gradebookColumnKey]int{}
+		}
+		ordinals[grade.studentID][grade.key]++
+
+		lesson.Ordinal = ordinals[grade.studentID][grade.key]
+		grade.key.ordinal = lesson.Ordinal
+
+		columns[grade.key] = lesson
+		grades = append(grades, grade)
+	}
+
+	if err := gradeRows.Err(); err != nil {
+		return gradebook, err
+	}
+
+	for _, lesson := range columns {
+		gradebook.Lessons = append(gradebook.Lessons, lesson)
+	}
+
+	sort.Slice(gradebook.Lessons, func(i, j int) bool {
+		a, b := gradebook.Lessons[i], gradebook.Lessons[j]
+
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		// Оценки без занятия идут перед занятиями того же дня
+		if (a.StartsAt == nil) != (b.StartsAt == nil) {
+			return a.StartsAt == nil
+		}
+		if a.StartsAt != nil && !a.StartsAt.Equal(*b.StartsAt) {
+			return a.StartsAt.Before(*b.StartsAt)
+		}
+		if a.LessonID != b.LessonID {
+			return a.LessonID < b.LessonID
+		}
+		return a.Ordinal < b.Ordinal
+	})
+
+	gradebook.LessonCount = len(gradebook.Lessons)
+
+	columnIndexes := map[gradebookColumnKey]int{}
+	for i, lesson := range gradebook.Lessons {
+		key := gradebookColumnKey{lessonID: lesson.LessonID, ordinal: lesson.Ordinal}
+		if lesson.LessonID == 0 {
+			key.date = lesson.Date.Format(time.DateOnly)
+		}
+		columnIndexes[key] = i
+	}
+
+	for i := range gradebook.Rows {
+		gradebook.Rows[i].Cells = make([]*model.GradebookCell, gradebook.LessonCount)
+	}
+
+	for i := range grades {
+		rowIndex, ok := rowIndexes[grades[i].studentID]
+		if !ok {
+			continue
+		}
+		gradebook.Rows[rowIndex].Cells[columnIndexes[grades[i].key]] = &grades[i].cell
+	}
+
+	attendanceQuery := `
+	SELECT la.lesson_id, la.student_id, la.status
+	FROM lesson_attendance la
+	JOIN lesson l ON la.lesson_id = l.id
+	WHERE l.group_id = $1 AND l.subject_id = $2`
+
+	attendanceRows, err := db.QueryContext(ctx, attendanceQuery, groupID, subjectID)
+	if err != nil {
+		return gradebook, err
+	}
+	defer attendanceRows.Close()
+
+	for attendanceRows.Next() {
+		var lessonID, studentID int
+		var status string
+
+		if err := attendanceRows.Scan(&lessonID, &studentID, &status); err != nil {
+			return gradebook, err
+		}
+
+		rowIndex, ok := rowIndexes[studentID]
+		if !ok {
+			continue
+		}
+
+		cells := gradebook.Rows[rowIndex].Cells
+
+		// Отметка относится ко всем столбцам занятия; без оценки она попадает в первый столбец
+		for ordinal := 1; ; ordinal++ {
+			column, ok := columnIndexes[gradebookColumnKey{lessonID: lessonID, ordinal: ordinal}]
+			if !ok {
+				break
+			}
+
+			if cells[column] == nil {
+				if ordinal > 1 {
+					break
+				}
+				cells[column] = &model.GradebookCell{}
+			}
+
+			cells[column].AttendanceStatus = status
+		}
+	}
+
+	if err := attendanceRows.Err(); err != nil {
+		return gradebook, err
+	}
+
+	summaries, err := attendanceSummaries(attendanceFilter{subjectID: subjectID, groupID: groupID})
+	if err != nil {
+		return gradebook, err
+	}
+
+	for _, summary := range summaries {
+		if rowIndex, ok := rowIndexes[summary.StudentID]; ok {
+			gradebook.Rows[rowIndex].AttendanceRate = summary.AttendanceRate
+		}
+	}
+
+	for i := range gradebook.Rows {
+		var gradeSum, gradeCount int
+
+		for _, cell := range gradebook.Rows[i].Cells {
+			if cell == nil {
+				continue
+			}
+
+			if cell.Grade != nil {
+				gradeSum += *cell.Grade
+				gradeCount++
+			}
+			if gradeCount > 0 {
+				cell.RunningAverage = math.Round(float64(gradeSum)/float64(gradeCount)*100) / 100
+			}
+
+			gradebook.Rows[i].AverageGrade = cell.RunningAverage
+		}
+	}
+
+	return gradebook, nil
+}
+
+// GetGradebook возвращает журнал группы по предмету (group_id, subject_id, необязательный subgroup_id)
+// Доступно администраторам и преподавателям предмета.
+func GetGradebook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	// subgroup_id необязателен и сужает журнал до одной подгруппы
+	subgroupID := 0
+	paramSubgroupID := r.URL.Query().Get("subgroup_id")
+	if paramSubgroupID != "" {
+		subgroupID, err = strconv.Atoi(paramSubgroupID)
+		if err != nil {
+			http.Error(w, "subgroup_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "Only administrators and professors of this subject can view the gradebook", http.StatusUnauthorized)
+		return
+	}
+
+	gradebook, err := buildGradebook(groupID, subjectID, subgroupID)
+	if err != nil {
+		if errors.Is(err, errGradebookNotFound) {
+			http.Error(w, "Group or subject not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("buildGradebook deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); ok {
+			log.Println("Database error: ", pgErr)
+		} else {
+			log.Println("buildGradebook error: ", err)
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradebook)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Gradebook failed: %v\n", err)
+	}
+}
@@ -0,0 +1,923 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Формат файла журнала: строка заголовков, затем строка на студента.
+// Столбцы: student_id, lastname, firstname, subgroup, столбцы занятий, total_grade, version.
+// Столбец занятия расписания называется lesson_<id>, столбец старых оценок без занятия - date_<YYYY-MM-DD>.
+// Вторая и следующие оценки студента за то же занятие попадают в столбцы с суффиксом _2, _3, ...
+// Ячейка занятия - оценка, отметка посещаемости (present, late, excused, left_early, remote, absent),
+// оценка и отметка через "/" (например, 5/late) или пусто. Отметка бывает только в первом столбце
+// занятия расписания; ячейка без отметки посещаемость не меняет.
+// version - отпечаток оценок и отметок студента на момент выгрузки, по нему импорт находит конфликты.
+
+const maxGradebookImportSize = 5 << 20
+
+var errTotalGradeOverridden = errors.New("total grade is overridden")
+
+const gradebookTotalColumn = "total_grade"
+
+func gradebookLessonColumn(lesson model.GradebookLesson) string {
+	name := "date_" + lesson.Date.Format(time.DateOnly)
+	if lesson.LessonID != 0 {
+		name = "lesson_" + strconv.Itoa(lesson.LessonID)
+	}
+
+	if lesson.Ordinal > 1 {
+		name += "_" + strconv.Itoa(lesson.Ordinal)
+	}
+
+	return name
+}
+
+// gradebookLessonHasAttendance сообщает, выгружается ли в столбце lesson отметка посещаемости
+func gradebookLessonHasAttendance(lesson model.GradebookLesson) bool {
+	return lesson.LessonID != 0 && lesson.Ordinal == 1
+}
+
+func encodeGradebookCell(cell *model.GradebookCell, withAttendance bool) string {
+	if cell == nil {
+		return ""
+	}
+
+	var parts []string
+
+	if cell.Grade != nil {
+		parts = append(parts, strconv.Itoa(*cell.Grade))
+	}
+	if withAttendance && cell.AttendanceStatus != "" {
+		parts = append(parts, cell.AttendanceStatus)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// decodeGradebookCell разбирает ячейку занятия. grade = nil - оценки нет, пустой status - отметки нет.
+func decodeGradebookCell(value string) (grade *int, status string, err error) {
+	value = strings.ToLower(value)
+	if value == "" {
+		return nil, "", nil
+	}
+
+	gradeValue, status, found := strings.Cut(value, "/")
+	if !found && isValidAttendanceStatus(value) {
+		gradeValue, status = "", value
+	}
+
+	if status != "" && !isValidAttendanceStatus(status) {
+		return nil, "", errors.New("attendance must be present, late, excused, left_early, remote or absent")
+	}
+
+	if gradeValue != "" {
+		value, err := strconv.Atoi(gradeValue)
+		if err != nil {
+			return nil, "", errors.New("cell value must be a grade, an attendance status, a grade and a status separated by / or empty")
+		}
+		grade = &value
+	}
+
+	return grade, status, nil
+}
+
+func gradebookRowVersion(row model.GradebookRow) string {
+	hash := fnv.New64a()
+
+	fmt.Fprintf(hash, "%d|%s", row.StudentID, row.TotalGrade)
+	for _, cell := range row.Cells {
+		if cell == nil {
+			continue
+		}
+		fmt.Fprintf(hash, "|%d:%s:%s", cell.ID, lessonGradeValue(cell.Grade), cell.AttendanceStatus)
+	}
+
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+func gradebookRecords(gradebook model.Gradebook) [][]string {
+	header := []string{"student_id", "lastname", "firstname", "subgroup"}
+	for _, lesson := range gradebook.Lessons {
+		header = append(header, gradebookLessonColumn(lesson))
+	}
+	header = append(header, gradebookTotalColumn, "version")
+
+	records := [][]string{header}
+
+	for _, row := range gradebook.Rows {
+		record := []string{
+			strconv.Itoa(row.StudentID),
+			row.StudentLastname,
+			row.StudentFirstname,
+			row.StudentSubgroupName,
+		}
+		for i, cell := range row.Cells {
+			record = append(record, encodeGradebookCell(cell, gradebookLessonHasAttendance(gradebook.Lessons[i])))
+		}
+		record = append(record, row.TotalGrade, gradebookRowVersion(row))
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// ExportGradebook выгружает журнал группы по предмету в CSV или XLSX (format=csv|xlsx)
+func ExportGradebook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := strconv.Atoi(r.URL.Query().Get("group_id"))
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	subjectID, err := strconv.Atoi(r.URL.Query().Get("subject_id"))
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only export gradebooks of subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	gradebook, err := buildGradebook(groupID, subjectID, 0)
+	if err != nil {
+		if errors.Is(err, errGradebookNotFound) {
+			http.Error(w, "Group or subject not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("buildGradebook deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("buildGradebook error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	records := gradebookRecords(gradebook)
+
+	var buf bytes.Buffer
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = writeXLSX(&buf, records)
+	} else {
+		csvWriter := csv.NewWriter(&buf)
+		err = csvWriter.WriteAll(records)
+	}
+
+	if err != nil {
+		log.Println("ExportGradebook write error: ", err)
+		http.Error(w, "Error while rendering gradebook", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := "gradebook_" + strconv.Itoa(groupID) + "_" + strconv.Itoa(subjectID) + "." + format
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		log.Printf("Export Gradebook failed: %v\n", err)
+	}
+}
+
+// gradebookImportOperation - запланированное изменение ячейки вместе с данными для записи.
+// lesson - столбец журнала, к которому относится изменение оценки или отметки.
+type gradebookImportOperation struct {
+	change  model.GradebookChange
+	lesson  model.GradebookLesson
+	gradeID int
+	grade   *int
+	status  string
+}
+
+func writeGradebookImportResult(w http.ResponseWriter, status int, result model.GradebookImportResult) {
+	resp, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("ImportGradebook failed: %v\n", err)
+	}
+}
+
+// applyGradebookImportOperation записывает одно изменение через функции grade_write.go
+func applyGradebookImportOperation(ctx context.Context, tx *sql.Tx, operation gradebookImportOperation, subjectID int, reason, actorID string) error {
+	change := operation.change
+
+	if change.Column == gradebookTotalColumn {
+		// Журнал показывает оценку последнего семестра, поэтому меняется она. Без оценок
+		// создаётся оценка текущего семестра.
+		var id int
+
+		totalGradeIDQuery := `SELECT COALESCE(stg.id, 0) FROM person p` + latestTotalGradeJoin("p.id", "$2") + `
+			WHERE p.id = $1`
+
+		err := tx.QueryRowContext(ctx, totalGradeIDQuery, change.StudentID, subjectID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if change.Action == "delete" {
+			if id == 0 {
+				return errGradeNotFound
+			}
+
+			return deleteTotalGrade(ctx, tx, id, change.StudentID, subjectID, reason, actorID)
+		}
+
+		applied, err := upsertTotalGrade(ctx, tx, model.StudentTotalGrade{
+			ID:        id,
+			StudentID: change.StudentID,
+			SubjectID: subjectID,
+			Grade:     change.NewValue,
+			Reason:    reason,
+		}, false, actorID)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return errTotalGradeOverridden
+		}
+
+		return nil
+	}
+
+	switch change.Action {
+	case "attendance":
+		// Минуты опоздания и пояснение остаются прежними
+		_, err := recordLessonAttendance(ctx, tx, attendanceChange{
+			lessonID:  operation.lesson.LessonID,
+			studentID: change.StudentID,
+			status:    operation.status,
+		}, actorID)
+		return err
+	case "insert":
+		studentGrade := model.StudentGrade{
+			StudentID: change.StudentID,
+			SubjectID: subjectID,
+			LessonID:  operation.lesson.LessonID,
+			Grade:     operation.grade,
+			Reason:    reason,
+		}
+		// Дата занятия расписания берётся из занятия, у столбца без занятия это дата столбца
+		if operation.lesson.LessonID == 0 {
+			lessonDate := operation.lesson.Date
+			studentGrade.LessonDate = &lessonDate
+		}
+
+		_, err := insertLessonGrade(ctx, tx, studentGrade, actorID)
+		return err
+	case "delete":
+		return deleteLessonGrade(ctx, tx, operation.gradeID, change.StudentID, subjectID, reason, actorID)
+	}
+
+	studentGrade := model.StudentGrade{
+		ID:        operation.gradeID,
+		StudentID: change.StudentID,
+		SubjectID: subjectID,
+		Grade:     operation.grade,
+		Reason:    reason,
+	}
+
+	// Остальные поля оценки сохраняются как есть
+	currentGradeQuery := `
+		SELECT COALESCE(category_id, 0), lesson_date, COALESCE(description, ''), COALESCE(professor_comment, ''),
+		       COALESCE(lesson_id, 0)
+		FROM student_grades WHERE id = $1`
+
+	err := tx.QueryRowContext(ctx, currentGradeQuery, operation.gradeID).Scan(
+		&studentGrade.CategoryID,
+		&studentGrade.LessonDate,
+		&studentGrade.Description,
+		&studentGrade.ProfessorComment,
+		&studentGrade.LessonID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errGradeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return updateLessonGrade(ctx, tx, studentGrade, actorID)
+}
+
+// ImportGradebook загружает журнал в формате выгрузки (multipart: file, group_id, subject_id,
+// необязательные format, dry_run и reason). Строки, изменённые после выгрузки, считаются конфликтами.
+// С dry_run=true возвращается только список изменений. Иначе изменения применяются в одной транзакции,
+// если нет ни конфликтов, ни ошибок.
+func ImportGradebook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isProfessor, err := isProfessor(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isProfessor {
+		http.Error(w, "You do not have professor privileges", http.StatusUnauthorized)
+		return
+	}
+
+	// Запас в 1 МБ на остальные поля формы
+	r.Body = http.MaxBytesReader(w, r.Body, maxGradebookImportSize+1<<20)
+
+	err = r.ParseMultipartForm(maxGradebookImportSize)
+	if err != nil {
+		http.Error(w, "Request must be multipart/form-data no bigger than 5 MB", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	groupID, err := strconv.Atoi(r.FormValue("group_id"))
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	subjectID, err := strconv.Atoi(r.FormValue("subject_id"))
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if paramDryRun := r.FormValue("dry_run"); paramDryRun != "" {
+		dryRun, err = strconv.ParseBool(paramDryRun)
+		if err != nil {
+			http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Без format формат определяется по расширению файла
+	format := r.FormValue("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	content, err := io.ReadAll(io.LimitReader(file, maxGradebookImportSize+1))
+	if err != nil {
+		http.Error(w, "Error reading uploaded file", http.StatusInternalServerError)
+		return
+	}
+
+	if len(content) > maxGradebookImportSize {
+		http.Error(w, "Maximum file size is 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var records [][]string
+	if format == "xlsx" {
+		records, err = readXLSX(content)
+	} else {
+		csvReader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+		csvReader.FieldsPerRecord = -1
+		records, err = csvReader.ReadAll()
+	}
+
+	if err != nil {
+		http.Error(w, "File is not a valid "+format+" file", http.StatusBadRequest)
+		return
+	}
+
+	if len(records) == 0 {
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return
+	}
+
+	studentIDColumn, versionColumn, totalColumn := -1, -1, -1
+	fileLessonColumns := map[string]int{} // имя столбца занятия -> номер столбца файла
+
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch {
+		case name == "student_id":
+			studentIDColumn = i
+		case name == "version":
+			versionColumn = i
+		case name == gradebookTotalColumn:
+			totalColumn = i
+		case strings.HasPrefix(name, "lesson_") || strings.HasPrefix(name, "date_"):
+			fileLessonColumns[name] = i
+		}
+	}
+
+	if studentIDColumn == -1 || versionColumn == -1 {
+		http.Error(w, "File must have student_id and version columns", http.StatusBadRequest)
+		return
+	}
+
+	hasSubject, err := professorHasSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("professorHasSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject {
+		http.Error(w, "You can only set grades for subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	gradebook, err := buildGradebook(groupID, subjectID, 0)
+	if err != nil {
+		if errors.Is(err, errGradebookNotFound) {
+			http.Error(w, "Group or subject not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("buildGradebook deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("buildGradebook error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	gradebookRows := map[int]model.GradebookRow{}
+	for _, row := range gradebook.Rows {
+		gradebookRows[row.StudentID] = row
+	}
+
+	// Столбцы файла сопоставляются со столбцами журнала по занятию, а не по порядку
+	lessonColumns := map[int]int{} // номер столбца журнала -> номер столбца файла
+	for i, lesson := range gradebook.Lessons {
+		column := gradebookLessonColumn(lesson)
+		if fileColumn, ok := fileLessonColumns[column]; ok {
+			lessonColumns[i] = fileColumn
+			delete(fileLessonColumns, column)
+		}
+	}
+
+	if len(fileLessonColumns) > 0 {
+		unknownColumns := make([]string, 0, len(fileLessonColumns))
+		for column := range fileLessonColumns {
+			unknownColumns = append(unknownColumns, column)
+		}
+		sort.Strings(unknownColumns)
+
+		http.Error(w, "Unknown lesson columns "+strings.Join(unknownColumns, ", ")+", export the gradebook again", http.StatusBadRequest)
+		return
+	}
+
+	// Отметки посещаемости ставятся по правам на занятие, как при записи посещаемости занятия
+	canManageLessons := map[int]bool{}
+	for i := range lessonColumns {
+		lesson := gradebook.Lessons[i]
+		if !gradebookLessonHasAttendance(lesson) {
+			continue
+		}
+
+		scheduledLesson, err := getLesson(lesson.LessonID)
+		if err == nil {
+			canManageLessons[lesson.LessonID], err = canManageLesson(claims.Issuer, scheduledLesson)
+		}
+		if err != nil {
+			log.Println("canManageLesson error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result := model.GradebookImportResult{
+		DryRun:    dryRun,
+		Changes:   []model.GradebookChange{},
+		Conflicts: []model.GradebookImportProblem{},
+		Errors:    []model.GradebookImportProblem{},
+	}
+
+	var operations []gradebookImportOperation
+
+	// Проверки шкалы кэшируются: в журнале повторяются одни и те же оценки
+	lessonGradeProblems := map[string]string{}
+	totalGradeProblems := map[string]string{}
+	seenStudents := map[int]bool{}
+
+	cellValue := func(record []string, column int) string {
+		if column < 0 || column >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[column])
+	}
+
+	for i, record := range records[1:] {
+		rowNumber := i + 2
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		studentID, err := strconv.Atoi(cellValue(record, studentIDColumn))
+		if err != nil {
+			result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, Column: "student_id", Error: "student_id must be an integer"})
+			continue
+		}
+
+		if seenStudents[studentID] {
+			result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Error: "Student appears in the file more than once"})
+			continue
+		}
+		seenStudents[studentID] = true
+
+		row, ok := gradebookRows[studentID]
+		if !ok {
+			result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Error: "Student is not in this group"})
+			continue
+		}
+
+		hasGroup, err := professorHasGroup(claims.Issuer, studentID)
+		if err != nil {
+			log.Println("professorHasGroup error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasGroup {
+			result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Error: "You can only set grades for students from groups that you teach"})
+			continue
+		}
+
+		if cellValue(record, versionColumn) != gradebookRowVersion(row) {
+			result.Conflicts = append(result.Conflicts, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Error: "Grades of this student were changed after the export"})
+			continue
+		}
+
+		for i, lesson := range gradebook.Lessons {
+			fileColumn, ok := lessonColumns[i]
+			if !ok {
+				continue
+			}
+
+			column := gradebookLessonColumn(lesson)
+
+			grade, status, err := decodeGradebookCell(cellValue(record, fileColumn))
+			if err != nil {
+				result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Column: column, Error: err.Error()})
+				continue
+			}
+
+			current := row.Cells[i]
+
+			var currentGradeID int
+			var currentGrade *int
+			var currentStatus string
+			if current != nil {
+				currentGradeID, currentGrade, currentStatus = current.ID, current.Grade, current.AttendanceStatus
+			}
+
+			if status != "" && status != currentStatus {
+				switch {
+				case !gradebookLessonHasAttendance(lesson):
+					result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Column: column, Error: "Attendance can only be set in the first column of a scheduled lesson"})
+				case !canManageLessons[lesson.LessonID]:
+					result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Column: column, Error: "You can only record attendance of lessons that you teach"})
+				default:
+					operations = append(operations, gradebookImportOperation{
+						change: model.GradebookChange{
+							Row:       rowNumber,
+							StudentID: studentID,
+							Column:    column,
+							Action:    "attendance",
+							OldValue:  currentStatus,
+							NewValue:  status,
+						},
+						lesson: lesson,
+						status: status,
+					})
+				}
+			}
+
+			oldValue, newValue := lessonGradeValue(currentGrade), lessonGradeValue(grade)
+			if newValue == oldValue {
+				continue
+			}
+
+			gradeProblem, ok := lessonGradeProblems[newValue]
+			if !ok {
+				gradeProblem, err = lessonGradeProblem(subjectID, grade)
+				if err != nil {
+					log.Println("lessonGradeProblem error: ", err)
+					http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+					return
+				}
+				lessonGradeProblems[newValue] = gradeProblem
+			}
+
+			if gradeProblem != "" {
+				result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Column: column, Error: gradeProblem})
+				continue
+			}
+
+			operation := gradebookImportOperation{
+				change: model.GradebookChange{
+					Row:       rowNumber,
+					StudentID: studentID,
+					Column:    column,
+					OldValue:  oldValue,
+					NewValue:  newValue,
+				},
+				lesson:  lesson,
+				gradeID: currentGradeID,
+				grade:   grade,
+			}
+
+			switch {
+			case currentGradeID == 0:
+				operation.change.Action = "insert"
+			case grade == nil:
+				operation.change.Action = "delete"
+			default:
+				operation.change.Action = "update"
+			}
+
+			operations = append(operations, operation)
+		}
+
+		if totalColumn == -1 {
+			continue
+		}
+
+		totalGrade := cellValue(record, totalColumn)
+		if totalGrade == row.TotalGrade {
+			continue
+		}
+
+		if totalGrade != "" {
+			gradeProblem, ok := totalGradeProblems[totalGrade]
+			if !ok {
+				gradeProblem, err = totalGradeProblem(subjectID, totalGrade)
+				if err != nil {
+					log.Println("totalGradeProblem error: ", err)
+					http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+					return
+				}
+				totalGradeProblems[totalGrade] = gradeProblem
+			}
+
+			if gradeProblem != "" {
+				result.Errors = append(result.Errors, model.GradebookImportProblem{Row: rowNumber, StudentID: studentID, Column: gradebookTotalColumn, Error: gradeProblem})
+				continue
+			}
+		}
+
+		operation := gradebookImportOperation{
+			change: model.GradebookChange{
+				Row:       rowNumber,
+				StudentID: studentID,
+				Column:    gradebookTotalColumn,
+				Action:    "update",
+				OldValue:  row.TotalGrade,
+				NewValue:  totalGrade,
+			},
+		}
+
+		if row.TotalGrade == "" {
+			operation.change.Action = "insert"
+		} else if totalGrade == "" {
+			operation.change.Action = "delete"
+		}
+
+		operations = append(operations, operation)
+	}
+
+	for _, operation := range operations {
+		result.Changes = append(result.Changes, operation.change)
+	}
+
+	if dryRun {
+		writeGradebookImportResult(w, http.StatusOK, result)
+		return
+	}
+
+	if len(result.Conflicts) > 0 {
+		writeGradebookImportResult(w, http.StatusConflict, result)
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		writeGradebookImportResult(w, http.StatusBadRequest, result)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("ImportGradebook BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	hasLocked := false
+
+	for _, operation := range operations {
+		applyErr := applyGradebookImportOperation(ctx, tx, operation, subjectID, reason, claims.Issuer)
+
+		problem := model.GradebookImportProblem{
+			Row:       operation.change.Row,
+			StudentID: operation.change.StudentID,
+			Column:    operation.change.Column,
+		}
+
+		// Ошибки отдельных ячеек не прерывают транзакцию, чтобы сообщить обо всех сразу
+		switch {
+		case errors.Is(applyErr, errGradeLocked):
+			problem.Error = "Grades of this group and subject are finalized"
+			result.Errors = append(result.Errors, problem)
+			hasLocked = true
+		case errors.Is(applyErr, errReasonRequired):
+			problem.Error = "Reason is required for changes after the term end date"
+			result.Errors = append(result.Errors, problem)
+		case errors.Is(applyErr, errTotalGradeOverridden):
+			problem.Error = "Total grade was set manually and cannot be changed by import"
+			result.Conflicts = append(result.Conflicts, problem)
+		case errors.Is(applyErr, errGradeNotFound):
+			problem.Error = "Grade was deleted after the export"
+			result.Conflicts = append(result.Conflicts, problem)
+		case applyErr != nil:
+			err = applyErr
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil && (len(result.Conflicts) > 0 || len(result.Errors) > 0) {
+		if hasLocked || len(result.Conflicts) > 0 {
+			writeGradebookImportResult(w, http.StatusConflict, result)
+			return
+		}
+		writeGradebookImportResult(w, http.StatusBadRequest, result)
+		return
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ImportGradebook QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, operation := range operations {
+		if operation.change.Action != "attendance" {
+			continue
+		}
+
+		if err := checkAttendanceAlerts(attendanceFilter{subjectID: subjectID, groupID: groupID}); err != nil {
+			log.Println("checkAttendanceAlerts error: ", err)
+		}
+		break
+	}
+
+	result.Applied = true
+	writeGradebookImportResult(w, http.StatusCreated, result)
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+	"time"
+)
+
+func TestGradebookLessonColumn(t *testing.T) {
+	date := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		lesson model.GradebookLesson
+		want   string
+	}{
+		{model.GradebookLesson{LessonID: 12, Date: date, Ordinal: 1}, "lesson_12"},
+		{model.GradebookLesson{LessonID: 12, Date: date, Ordinal: 2}, "lesson_12_2"},
+		{model.GradebookLesson{Date: date, Ordinal: 1}, "date_2026-03-02"},
+		{model.GradebookLesson{Date: date, Ordinal: 3}, "date_2026-03-02_3"},
+	}
+
+	for _, test := range tests {
+		if got := gradebookLessonColumn(test.lesson); got != test.want {
+			t.Errorf("gradebookLessonColumn(%+v) = %q, want %q", test.lesson, got, test.want)
+		}
+	}
+}
+
+func TestDecodeGradebookCell(t *testing.T) {
+	tests := []struct {
+		value   string
+		grade   string
+		status  string
+		wantErr bool
+	}{
+		{"", "", "", false},
+		{"5", "5", "", false},
+		{"late", "", "late", false},
+		{"Absent", "", "absent", false},
+		{"4/excused", "4", "excused", false},
+		// Оценка не означает присутствие
+		{"3", "3", "", false},
+		// Ноль - оценка шкалы (например, F или незачёт), а не её отсутствие
+		{"0", "0", "", false},
+		{"0/late", "0", "late", false},
+		{"5/sick", "", "", true},
+		{"five", "", "", true},
+		{"/present", "", "present", false},
+	}
+
+	for _, test := range tests {
+		grade, status, err := decodeGradebookCell(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("decodeGradebookCell(%q) error = %v, wantErr %v", test.value, err, test.wantErr)
+			continue
+		}
+		if lessonGradeValue(grade) != test.grade || status != test.status {
+			t.Errorf("decodeGradebookCell(%q) = %q, %q, want %q, %q", test.value, lessonGradeValue(grade), status, test.grade, test.status)
+		}
+	}
+}
+
+func TestEncodeGradebookCellRoundTrip(t *testing.T) {
+	five, four, zero := 5, 4, 0
+	cells := []*model.GradebookCell{
+		{ID: 1, Grade: &five},
+		{ID: 1, Grade: &four, AttendanceStatus: "late"},
+		{ID: 1, Grade: &zero},
+		{ID: 1, AttendanceStatus: "excused"},
+		{AttendanceStatus: "absent"},
+	}
+
+	for _, cell := range cells {
+		grade, status, err := decodeGradebookCell(encodeGradebookCell(cell, true))
+		if err != nil || lessonGradeValue(grade) != lessonGradeValue(cell.Grade) || status != cell.AttendanceStatus {
+			t.Errorf("round trip of %+v = %q, %q, %v", cell, lessonGradeValue(grade), status, err)
+		}
+	}
+
+	if got := encodeGradebookCell(&model.GradebookCell{Grade: &four, AttendanceStatus: "late"}, false); got != "4" {
+		t.Errorf("encodeGradebookCell without attendance = %q, want 4", got)
+	}
+}
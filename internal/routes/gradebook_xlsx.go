@@ -0,0 +1,335 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Минимальная поддержка XLSX для журнала: один лист, значения без форматирования.
+// При чтении берётся первый лист книги, поддерживаются общие и встроенные строки.
+
+// maxXLSXPartSize ограничивает размер распакованной части архива,
+// maxXLSXRows и maxXLSXColumns - пределы листа Excel
+const (
+	maxXLSXPartSize = 50 << 20
+	maxXLSXRows     = 1048576
+	maxXLSXColumns  = 16384
+)
+
+var errInvalidXLSX = errors.New("invalid xlsx file")
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Gradebook" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+// xlsxColumnName переводит номер столбца с нуля в буквенное обозначение: 0 - A, 26 - AA
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xlsxColumnIndex извлекает номер столбца с нуля из ссылки на ячейку вида AB12
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		index = index*26 + int(c-'A') + 1
+	}
+	return index - 1
+}
+
+// writeXLSX записывает строки в лист книги. Целые числа записываются числовыми ячейками.
+func writeXLSX(out io.Writer, records [][]string) error {
+	archive := zip.NewWriter(out)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+
+	for _, part := range parts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return err
+		}
+	}
+
+	var sheet bytes.Buffer
+
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for i, record := range records {
+		rowNumber := strconv.Itoa(i + 1)
+		sheet.WriteString(`<row r="` + rowNumber + `">`)
+
+		for j, value := range record {
+			if value == "" {
+				continue
+			}
+
+			ref := xlsxColumnName(j) + rowNumber
+
+			if _, err := strconv.Atoi(value); err == nil {
+				sheet.WriteString(`<c r="` + ref + `"><v>` + value + `</v></c>`)
+				continue
+			}
+
+			sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&sheet, []byte(value)); err != nil {
+				return err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+
+		sheet.WriteString(`</row>`)
+	}
+
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	sheetWriter, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := sheetWriter.Write(sheet.Bytes()); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+
+	var sb strings.Builder
+	for _, run := range t.Runs {
+		sb.WriteString(run.T)
+	}
+	return sb.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSXPart(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+
+		partReader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer partReader.Close()
+
+		content, err := io.ReadAll(io.LimitReader(partReader, maxXLSXPartSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > maxXLSXPartSize {
+			return nil, errInvalidXLSX
+		}
+		return content, nil
+	}
+
+	return nil, nil
+}
+
+// firstXLSXSheetPath находит путь к первому листу книги по workbook.xml и его связям
+func firstXLSXSheetPath(archive *zip.Reader) (string, error) {
+	workbookContent, err := readXLSXPart(archive, "xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	if workbookContent == nil {
+		return "", errInvalidXLSX
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(workbookContent, &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return "", errInvalidXLSX
+	}
+
+	relsContent, err := readXLSXPart(archive, "xl/_rels/workbook.xml.rels")
+	if err != nil {
+		return "", err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if relsContent != nil {
+		if err := xml.Unmarshal(relsContent, &rels); err != nil {
+			return "", errInvalidXLSX
+		}
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+// readXLSX читает первый лист книги в строки. Пропущенные ячейки и строки становятся пустыми.
+func readXLSX(content []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errInvalidXLSX
+	}
+
+	var sharedStrings []string
+
+	sharedStringsContent, err := readXLSXPart(archive, "xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	if sharedStringsContent != nil {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := xml.Unmarshal(sharedStringsContent, &sst); err != nil {
+			return nil, errInvalidXLSX
+		}
+		for _, item := range sst.Items {
+			sharedStrings = append(sharedStrings, item.String())
+		}
+	}
+
+	sheetPath, err := firstXLSXSheetPath(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	sheetContent, err := readXLSXPart(archive, sheetPath)
+	if err != nil {
+		return nil, err
+	}
+	if sheetContent == nil {
+		return nil, errInvalidXLSX
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(sheetContent, &sheet); err != nil {
+		return nil, errInvalidXLSX
+	}
+
+	var records [][]string
+
+	for _, row := range sheet.Rows {
+		rowIndex := len(records)
+		if row.R > 0 {
+			rowIndex = row.R - 1
+		}
+		if rowIndex < len(records) || rowIndex >= maxXLSXRows {
+			return nil, errInvalidXLSX
+		}
+		for len(records) <= rowIndex {
+			records = append(records, []string{})
+		}
+
+		record := []string{}
+
+		for _, cell := range row.Cells {
+			columnIndex := len(record)
+			if cell.R != "" {
+				columnIndex = xlsxColumnIndex(cell.R)
+			}
+			if columnIndex < len(record) || columnIndex >= maxXLSXColumns {
+				return nil, errInvalidXLSX
+			}
+			for len(record) < columnIndex {
+				record = append(record, "")
+			}
+
+			value := cell.V
+			switch cell.T {
+			case "s":
+				index, err := strconv.Atoi(cell.V)
+				if err != nil || index < 0 || index >= len(sharedStrings) {
+					return nil, errInvalidXLSX
+				}
+				value = sharedStrings[index]
+			case "inlineStr":
+				value = cell.Inline.String()
+			}
+
+			record = append(record, value)
+		}
+
+		records[rowIndex] = record
+	}
+
+	return records, nil
+}
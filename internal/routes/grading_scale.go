@@ -0,0 +1,597 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gradeNotInScaleMessage описывает для клиента оценку, которой нет среди значений values шкалы scaleName
+func gradeNotInScaleMessage(grade, scaleName string, values []string) string {
+	// Длинные шкалы (100-point) описываются диапазоном, а не перечислением
+	if len(values) > 20 {
+		return fmt.Sprintf("Grade %q is not valid for grading scale %s, valid values are from %s to %s",
+			grade, scaleName, values[0], values[len(values)-1])
+	}
+
+	return fmt.Sprintf("Grade %q is not valid for grading scale %s, valid values are: %s",
+		grade, scaleName, strings.Join(values, ", "))
+}
+
+// totalGradeProblem проверяет итоговую оценку по шкале предмета.
+// Возвращает пустую строку, если оценка допустима, иначе описание ошибки для клиента.
+func totalGradeProblem(subjectID int, grade string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	scaleValuesQuery := `
+		SELECT gs.scale_name, gsv.value
+		FROM subject s
+		JOIN grading_scale gs ON s.grading_scale_id = gs.id
+		JOIN grading_scale_value gsv ON gsv.scale_id = gs.id
+		WHERE s.id = $1
+		ORDER BY gsv.numeric_value, gsv.value`
+
+	rows, err := db.QueryContext(ctx, scaleValuesQuery, subjectID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var scaleName string
+	var values []string
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&scaleName, &value); err != nil {
+			return "", err
+		}
+		if value == grade {
+			return "", nil
+		}
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if len(values) == 0 {
+		return "Subject not found or its grading scale has no values", nil
+	}
+
+	return gradeNotInScaleMessage(grade, scaleName, values), nil
+}
+
+// lessonGradeProblem проверяет оценку за занятие: nil означает отсутствие оценки,
+// остальные значения должны совпадать с числовым эквивалентом одного из значений шкалы предмета.
+func lessonGradeProblem(subjectID int, grade *int) (string, error) {
+	if grade == nil {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	scaleValuesQuery := `
+		SELECT DISTINCT gs.scale_name, gsv.numeric_value::float8
+		FROM subject s
+		JOIN grading_scale gs ON s.grading_scale_id = gs.id
+		JOIN grading_scale_value gsv ON gsv.scale_id = gs.id
+		WHERE s.id = $1
+		ORDER BY 2`
+
+	rows, err := db.QueryContext(ctx, scaleValuesQuery, subjectID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var scaleName string
+	var values []string
+
+	for rows.Next() {
+		var value float64
+		if err := rows.Scan(&scaleName, &value); err != nil {
+			return "", err
+		}
+		if value == float64(*grade) {
+			return "", nil
+		}
+		values = append(values, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if len(values) == 0 {
+		return "Subject not found or its grading scale has no values", nil
+	}
+
+	return gradeNotInScaleMessage(strconv.Itoa(*grade), scaleName, values), nil
+}
+
+func gradingScaleProblem(gradingScale model.GradingScale) string {
+	if len([]rune(gradingScale.ScaleName)) == 0 {
+		return "Grading scale name cannot be empty"
+	}
+
+	if len([]rune(gradingScale.ScaleName)) > 255 {
+		return "Maximum grading scale name length is 255 characters"
+	}
+
+	if len(gradingScale.Values) == 0 {
+		return "Grading scale must have at least one value"
+	}
+
+	for _, value := range gradingScale.Values {
+		if len([]rune(value.Value)) == 0 || len([]rune(value.Value)) > 50 {
+			return "Grading scale values must be from 1 to 50 characters long"
+		}
+	}
+
+	return ""
+}
+
+func insertGradingScaleValues(ctx context.Context, tx *sql.Tx, scaleID int, values []model.GradingScaleValue) error {
+	insertValueQuery := `INSERT INTO grading_scale_value (scale_id, value, numeric_value) VALUES ($1, $2::text, $3)`
+
+	for _, value := range values {
+		if _, err := tx.ExecContext(ctx, insertValueQuery, scaleID, value.Value, value.NumericValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ListGradingScales(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	listGradingScalesQuery := `
+		SELECT gs.id, gs.scale_name, gs.passing_threshold, gs.is_pass_fail,
+		       COALESCE(gsv.value, ''), COALESCE(gsv.numeric_value, 0)
+		FROM grading_scale gs
+		LEFT JOIN grading_scale_value gsv ON gsv.scale_id = gs.id
+		ORDER BY gs.id, gsv.numeric_value, gsv.value;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradingScalesQuery)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradingScales QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	gradingScales := []model.GradingScale{}
+
+	for rows.Next() {
+		var gradingScale model.GradingScale
+		var value model.GradingScaleValue
+
+		if err := rows.Scan(
+			&gradingScale.ID,
+			&gradingScale.ScaleName,
+			&gradingScale.PassingThreshold,
+			&gradingScale.IsPassFail,
+			&value.Value,
+			&value.NumericValue); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(gradingScales) == 0 || gradingScales[len(gradingScales)-1].ID != gradingScale.ID {
+			gradingScale.Values = []model.GradingScaleValue{}
+			gradingScales = append(gradingScales, gradingScale)
+		}
+
+		if value.Value != "" {
+			last := &gradingScales[len(gradingScales)-1]
+			last.Values = append(last.Values, value)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(gradingScales)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grading Scales failed: %v\n", err)
+	}
+}
+
+func AddGradingScale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to add grading scales", http.StatusUnauthorized)
+		return
+	}
+
+	var gradingScale model.GradingScale
+
+	err = json.NewDecoder(r.Body).Decode(&gradingScale)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if problem := gradingScaleProblem(gradingScale); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("AddGradingScale BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var scaleID int
+
+	insertGradingScaleQuery := `
+		INSERT INTO grading_scale (scale_name, passing_threshold, is_pass_fail) 
+		VALUES ($1::text, $2, $3) 
+		RETURNING id;`
+
+	err = tx.QueryRowContext(ctx, insertGradingScaleQuery, gradingScale.ScaleName, gradingScale.PassingThreshold,
+		gradingScale.IsPassFail).Scan(&scaleID)
+	if err == nil {
+		err = insertGradingScaleValues(ctx, tx, scaleID, gradingScale.Values)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddGradingScale QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, grading scale or value already exists: ", err)
+			http.Error(w, "Grading scale or value already exists", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Inserting grading scale successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("add-grading-scale failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateGradingScale заменяет название, порог сдачи, признак зачётной шкалы и весь набор значений шкалы.
+// Уже выставленные оценки не пересчитываются.
+func UpdateGradingScale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to update grading scales", http.StatusUnauthorized)
+		return
+	}
+
+	var gradingScale model.GradingScale
+
+	err = json.NewDecoder(r.Body).Decode(&gradingScale)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if problem := gradingScaleProblem(gradingScale); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("UpdateGradingScale BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	updateGradingScaleQuery := `
+		UPDATE grading_scale SET scale_name = $1::text, passing_threshold = $2, is_pass_fail = $3 WHERE id = $4;`
+	deleteValuesQuery := `DELETE FROM grading_scale_value WHERE scale_id = $1;`
+
+	_, err = tx.ExecContext(ctx, updateGradingScaleQuery, gradingScale.ScaleName, gradingScale.PassingThreshold,
+		gradingScale.IsPassFail, gradingScale.ID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, deleteValuesQuery, gradingScale.ID)
+	}
+	if err == nil {
+		err = insertGradingScaleValues(ctx, tx, gradingScale.ID, gradingScale.Values)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UpdateGradingScale QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, grading scale or value already exists: ", err)
+			http.Error(w, "Grading scale or value already exists", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Update grading scale successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Update grading scale failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteGradingScale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to delete grading scales", http.StatusUnauthorized)
+		return
+	}
+
+	idParam := r.URL.Query().Get("id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		http.Error(w, "id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	deleteGradingScaleQuery := `DELETE FROM grading_scale WHERE id = $1;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, deleteGradingScaleQuery, id)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DeleteGradingScale QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation, grading scale is in use: ", err)
+			http.Error(w, "Grading scale is assigned to subjects", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Delete grading scale successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Delete grading scale failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
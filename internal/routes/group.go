@@ -13,18 +13,38 @@ import (
 	"time"
 )
 
+// Виды групп. Студенческие группы - единственные, в которых учатся студенты,
+// staff и system зарезервированы для преподавателей и администраторов.
+const (
+	groupKindStudent = "student"
+	groupKindStaff   = "staff"
+	groupKindSystem  = "system"
+)
+
+func isValidGroupKind(groupKind string) bool {
+	switch groupKind {
+	case groupKindStudent, groupKindStaff, groupKindSystem:
+		return true
+	}
+	return false
+}
+
 func ListGroups(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
 		return
 	}
 
-	listGroupsQuery := `SELECT id, group_name FROM group_uni WHERE id <> 1 AND id <> 2;`
+	listGroupsQuery := `
+		SELECT g.id, g.group_name, g.group_kind, COALESCE(gc.curator_id, 0)
+		FROM group_uni g
+		LEFT JOIN group_curator gc ON gc.group_id = g.id
+		WHERE g.group_kind = $1;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listGroupsQuery)
+	rows, err := db.QueryContext(ctx, listGroupsQuery, groupKindStudent)
 	defer rows.Close()
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -54,7 +74,7 @@ func ListGroups(w http.ResponseWriter, r *http.Request) {
 	var groups []model.Group
 
 	for rows.Next() {
-		if err := rows.Scan(&group.ID, &group.GroupName); err != nil {
+		if err := rows.Scan(&group.ID, &group.GroupName, &group.GroupKind, &group.CuratorID); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -96,15 +116,27 @@ func ListStudentsOfAGroup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
 	}
 
+	// subgroup_id необязателен и сужает список до студентов одной подгруппы
+	subgroupID := 0
+	paramSubgroupID := r.URL.Query().Get("subgroup_id")
+	if paramSubgroupID != "" {
+		subgroupID, err = strconv.Atoi(paramSubgroupID)
+		if err != nil {
+			http.Error(w, "subgroup_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
 	listGroupsQuery := `
-		SELECT id, username, firstname, lastname, group_id, sex, birthdate
+		SELECT id, username, firstname, lastname, group_id, COALESCE(subgroup_id, 0), sex, birthdate
 		FROM person
-		WHERE group_id = $1 AND is_professor = false AND is_admin = false;`
+		WHERE group_id = $1 AND is_professor = false AND is_admin = false
+		  AND ($2 = 0 OR subgroup_id = $2);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listGroupsQuery, groupID)
+	rows, err := db.QueryContext(ctx, listGroupsQuery, groupID, subgroupID)
 	defer rows.Close()
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -135,6 +167,7 @@ func ListStudentsOfAGroup(w http.ResponseWriter, r *http.Request) {
 			&student.FirstName,
 			&student.LastName,
 			&student.GroupID,
+			&student.SubgroupID,
 			&student.Sex,
 			&student.BirthDate); err != nil {
 			log.Println(err)
@@ -225,12 +258,21 @@ func AddGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	insertGroupQuery := `INSERT INTO group_uni (group_name) VALUES ($1::text);`
+	if group.GroupKind == "" {
+		group.GroupKind = groupKindStudent
+	}
+
+	if !isValidGroupKind(group.GroupKind) {
+		http.Error(w, "group_kind must be one of: student, staff, system", http.StatusBadRequest)
+		return
+	}
+
+	insertGroupQuery := `INSERT INTO group_uni (group_name, group_kind) VALUES ($1::text, $2::text);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, insertGroupQuery, group.GroupName)
+	_, err = db.ExecContext(ctx, insertGroupQuery, group.GroupName, group.GroupKind)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -331,12 +373,21 @@ func UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateGroupQuery := `UPDATE group_uni SET group_name = $1::text WHERE id = $2;`
+	if group.GroupKind != "" && !isValidGroupKind(group.GroupKind) {
+		http.Error(w, "group_kind must be one of: student, staff, system", http.StatusBadRequest)
+		return
+	}
+
+	// Пустой group_kind оставляет вид группы без изменений
+	updateGroupQuery := `
+		UPDATE group_uni 
+		SET group_name = $1::text, group_kind = COALESCE(NULLIF($2::text, ''), group_kind) 
+		WHERE id = $3;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, updateGroupQuery, group.GroupName, group.ID)
+	_, err = db.ExecContext(ctx, updateGroupQuery, group.GroupName, group.GroupKind, group.ID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -0,0 +1,187 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// isCuratorOfStudent проверяет, что issuer - куратор группы студента studentID
+func isCuratorOfStudent(issuer string, studentID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var isCurator bool
+
+	isCuratorQuery := `
+		SELECT true FROM group_curator gc
+		JOIN person p ON p.group_id = gc.group_id
+		WHERE gc.curator_id = $1 AND p.id = $2`
+
+	err := db.QueryRowContext(ctx, isCuratorQuery, issuer, studentID).Scan(&isCurator)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return isCurator, nil
+}
+
+// isCuratorOfGroup проверяет, что issuer - куратор группы groupID
+func isCuratorOfGroup(issuer string, groupID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var isCurator bool
+
+	isCuratorQuery := `SELECT true FROM group_curator WHERE curator_id = $1 AND group_id = $2`
+
+	err := db.QueryRowContext(ctx, isCuratorQuery, issuer, groupID).Scan(&isCurator)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return isCurator, nil
+}
+
+// SetGroupCurator назначает куратора группы. Куратором может быть только преподаватель,
+// curator_id = 0 снимает куратора.
+func SetGroupCurator(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to set group curators", http.StatusUnauthorized)
+		return
+	}
+
+	var curator model.GroupCurator
+
+	err = json.NewDecoder(r.Body).Decode(&curator)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if curator.CuratorID != 0 {
+		isProfessor, err := isProfessor(strconv.Itoa(curator.CuratorID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println("isProfessor error: ", err)
+			http.Error(w, "Error while checking curator", http.StatusInternalServerError)
+			return
+		}
+
+		if !isProfessor {
+			http.Error(w, "Curator must be an existing professor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	setGroupCuratorQuery := `
+		INSERT INTO group_curator (group_id, curator_id)
+		VALUES ($1, $2)
+		ON CONFLICT (group_id) DO UPDATE SET curator_id = EXCLUDED.curator_id;`
+
+	args := []interface{}{curator.GroupID, curator.CuratorID}
+
+	if curator.CuratorID == 0 {
+		setGroupCuratorQuery = `DELETE FROM group_curator WHERE group_id = $1;`
+		args = args[:1]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, setGroupCuratorQuery, args...)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("SetGroupCurator ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation, group does not exist: ", err)
+			http.Error(w, "Group does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Set group curator successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Set group curator failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -289,6 +289,32 @@ func AddGroupSubject(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	// Предмет не назначается группе, пока все её студенты не выполнили пререквизиты, если не передан force=true
+	if r.URL.Query().Get("force") != "true" {
+		reports, err := prerequisiteReports(groupSubject.GroupID, 0, groupSubject.SubjectID)
+		if err != nil {
+			log.Println("prerequisiteReports error: ", err)
+			http.Error(w, "Error while checking subject prerequisites", http.StatusInternalServerError)
+			return
+		}
+
+		if len(reports) > 0 {
+			resp, err := json.Marshal(reports)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, err = w.Write(resp)
+			if err != nil {
+				log.Printf("AddGroupSubject failed: %v\n", err)
+			}
+			return
+		}
+	}
+
 	insertGroupSubjectQuery := `INSERT INTO group_subject (group_id, subject_id) VALUES ($1, $2);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
@@ -389,6 +415,32 @@ func UpdateGroupSubject(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	// Та же проверка пререквизитов, что и в AddGroupSubject, для новой пары группа-предмет
+	if r.URL.Query().Get("force") != "true" {
+		reports, err := prerequisiteReports(updateGroupSubject.NewGroupID, 0, updateGroupSubject.NewSubjectID)
+		if err != nil {
+			log.Println("prerequisiteReports error: ", err)
+			http.Error(w, "Error while checking subject prerequisites", http.StatusInternalServerError)
+			return
+		}
+
+		if len(reports) > 0 {
+			resp, err := json.Marshal(reports)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, err = w.Write(resp)
+			if err != nil {
+				log.Printf("UpdateGroupSubject failed: %v\n", err)
+			}
+			return
+		}
+	}
+
 	insertGroupSubjectQuery := `UPDATE group_subject SET group_id = $1, subject_id = $2
 	WHERE group_id = $3 AND subject_id = $4;`
 
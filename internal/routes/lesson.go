@@ -0,0 +1,813 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+var errLessonNotFound = errors.New("lesson not found")
+
+const (
+	lessonKindLesson = "lesson"
+	lessonKindExam   = "exam"
+)
+
+// Студенты занятия - студенты его группы, а для занятия подгруппы - только её студенты
+const lessonStudentCondition = `
+	p.group_id = l.group_id AND (l.subgroup_id IS NULL OR p.subgroup_id = l.subgroup_id)
+	AND p.is_professor = false AND p.is_admin = false`
+
+const lessonColumns = `
+	l.id, l.subject_id, s.subject_name, l.group_id, g.group_name, COALESCE(l.subgroup_id, 0),
+	COALESCE(l.professor_id, 0), COALESCE(pr.firstname, ''), COALESCE(pr.lastname, ''),
+	COALESCE(l.room_id, 0), COALESCE(r.room_name, ''), l.starts_at, l.ends_at, COALESCE(l.topic, ''), l.kind,
+	COALESCE(l.timetable_rule_id, 0), l.cancelled_at IS NOT NULL, l.sequence, l.updated_at
+	FROM lesson l
+	JOIN subject s ON l.subject_id = s.id
+	JOIN group_uni g ON l.group_id = g.id
+	LEFT JOIN person pr ON l.professor_id = pr.id
+	LEFT JOIN room r ON l.room_id = r.id`
+
+func scanLesson(row interface{ Scan(...any) error }) (model.Lesson, error) {
+	var lesson model.Lesson
+
+	err := row.Scan(
+		&lesson.ID,
+		&lesson.SubjectID,
+		&lesson.SubjectName,
+		&lesson.GroupID,
+		&lesson.GroupName,
+		&lesson.SubgroupID,
+		&lesson.ProfessorID,
+		&lesson.ProfessorFirstname,
+		&lesson.ProfessorLastname,
+		&lesson.RoomID,
+		&lesson.RoomName,
+		&lesson.StartsAt,
+		&lesson.EndsAt,
+		&lesson.Topic,
+		&lesson.Kind,
+		&lesson.TimetableRuleID,
+		&lesson.Cancelled,
+		&lesson.Sequence,
+		&lesson.UpdatedAt)
+
+	return lesson, err
+}
+
+func getLesson(lessonID int) (model.Lesson, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	lesson, err := scanLesson(db.QueryRowContext(ctx, `SELECT `+lessonColumns+` WHERE l.id = $1`, lessonID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return lesson, errLessonNotFound
+	}
+
+	return lesson, err
+}
+
+// lessonHasStudent проверяет, что занятие lessonID проходит по предмету subjectID у группы студента
+func lessonHasStudent(lessonID, studentID, subjectID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var hasStudent bool
+
+	lessonHasStudentQuery := `
+		SELECT true FROM lesson l, person p
+		WHERE l.id = $1 AND p.id = $2 AND l.subject_id = $3 AND ` + lessonStudentCondition
+
+	err := db.QueryRowContext(ctx, lessonHasStudentQuery, lessonID, studentID, subjectID).Scan(&hasStudent)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return hasStudent, nil
+}
+
+// canManageLesson разрешает работу с занятием администраторам и преподавателям,
+// которые ведут предмет и группу (или подгруппу) занятия
+func canManageLesson(issuer string, lesson model.Lesson) (bool, error) {
+	isAdmin, err := isAdmin(issuer)
+	if err != nil || isAdmin {
+		return isAdmin, err
+	}
+
+	hasSubject, err := professorHasSubject(issuer, lesson.SubjectID)
+	if err != nil || !hasSubject {
+		return false, err
+	}
+
+	return professorTeachesGroup(issuer, lesson.GroupID, lesson.SubgroupID)
+}
+
+// lessonProblem проверяет занятие перед сохранением.
+// Возвращает пустую строку, если занятие корректно, иначе описание ошибки для клиента.
+func lessonProblem(lesson model.Lesson) (string, error) {
+	if lesson.StartsAt.IsZero() || lesson.EndsAt.IsZero() {
+		return "starts_at and ends_at are required", nil
+	}
+
+	if !lesson.EndsAt.After(lesson.StartsAt) {
+		return "ends_at must be after starts_at", nil
+	}
+
+	if utf8.RuneCountInString(lesson.Topic) > 255 {
+		return "Maximum lesson topic length is 255 characters", nil
+	}
+
+	if lesson.Kind != lessonKindLesson && lesson.Kind != lessonKindExam {
+		return "kind must be lesson or exam", nil
+	}
+
+	hasSubject, err := groupHasSubject(lesson.GroupID, lesson.SubjectID)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasSubject {
+		return "Group does not study this subject", nil
+	}
+
+	if lesson.SubgroupID != 0 {
+		belongs, err := subgroupBelongsToGroup(lesson.SubgroupID, lesson.GroupID)
+		if err != nil {
+			return "", err
+		}
+
+		if !belongs {
+			return "Subgroup does not belong to this group", nil
+		}
+	}
+
+	if lesson.ProfessorID != 0 {
+		teaches, err := professorHasSubject(strconv.Itoa(lesson.ProfessorID), lesson.SubjectID)
+		if err != nil {
+			return "", err
+		}
+
+		if !teaches {
+			return "Professor does not teach this subject", nil
+		}
+	}
+
+	if lesson.RoomID != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+		defer cancel()
+
+		var roomFits bool
+
+		roomQuery := `SELECT true FROM room WHERE id = $1 AND (subject_id IS NULL OR subject_id = $2)`
+
+		err := db.QueryRowContext(ctx, roomQuery, lesson.RoomID, lesson.SubjectID).Scan(&roomFits)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "Room does not exist or belongs to another subject", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// AddLesson добавляет занятие. Преподаватель добавляет свои занятия,
+// администратор может указать преподавателя в professor_id.
+// Если занятие пересекается с занятиями того же преподавателя, группы или аудитории, возвращается 409 со списком пересечений.
+func AddLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var lesson model.Lesson
+
+	err = json.NewDecoder(r.Body).Decode(&lesson)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if lesson.Kind == "" {
+		lesson.Kind = lessonKindLesson
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		lesson.ProfessorID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only add lessons of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	problem, err := lessonProblem(lesson)
+	if err != nil {
+		log.Println("lessonProblem error: ", err)
+		http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+		return
+	}
+
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	conflicts, err := lessonConflicts(lesson, 0)
+	if err != nil {
+		log.Println("lessonConflicts error: ", err)
+		http.Error(w, "Error while checking timetable conflicts", http.StatusInternalServerError)
+		return
+	}
+
+	if len(conflicts) > 0 {
+		writeTimetableConflicts(w, conflicts)
+		return
+	}
+
+	addLessonQuery := `
+		INSERT INTO lesson (subject_id, group_id, subgroup_id, professor_id, room_id, starts_at, ends_at, topic, kind)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), $6, $7, NULLIF($8, ''), $9)
+		RETURNING id;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, addLessonQuery, lesson.SubjectID, lesson.GroupID, lesson.SubgroupID, lesson.ProfessorID,
+		lesson.RoomID, lesson.StartsAt, lesson.EndsAt, lesson.Topic, lesson.Kind).Scan(&lesson.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddLesson QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Subject, group, professor or room does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(lesson.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Add lesson failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateLesson изменяет занятие id. Проверяются права и на старое, и на новое занятие.
+func UpdateLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var lesson model.Lesson
+
+	err = json.NewDecoder(r.Body).Decode(&lesson)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	currentLesson, err := getLesson(lesson.ID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Без kind вид занятия не меняется
+	if lesson.Kind == "" {
+		lesson.Kind = currentLesson.Kind
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		lesson.ProfessorID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, currentLesson)
+	if err == nil && canManage {
+		canManage, err = canManageLesson(claims.Issuer, lesson)
+	}
+
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only update lessons of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	problem, err := lessonProblem(lesson)
+	if err != nil {
+		log.Println("lessonProblem error: ", err)
+		http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+		return
+	}
+
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	conflicts, err := lessonConflicts(lesson, lesson.ID)
+	if err != nil {
+		log.Println("lessonConflicts error: ", err)
+		http.Error(w, "Error while checking timetable conflicts", http.StatusInternalServerError)
+		return
+	}
+
+	if len(conflicts) > 0 {
+		writeTimetableConflicts(w, conflicts)
+		return
+	}
+
+	updateLessonQuery := `
+		UPDATE lesson SET subject_id = $1, group_id = $2, subgroup_id = NULLIF($3, 0), professor_id = NULLIF($4, 0),
+		    room_id = NULLIF($5, 0), starts_at = $6, ends_at = $7, topic = NULLIF($8, ''), kind = $10,
+		    sequence = sequence + 1, updated_at = now()
+		WHERE id = $9;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, updateLessonQuery, lesson.SubjectID, lesson.GroupID, lesson.SubgroupID, lesson.ProfessorID,
+		lesson.RoomID, lesson.StartsAt, lesson.EndsAt, lesson.Topic, lesson.ID, lesson.Kind)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UpdateLesson QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Subject, group, professor or room does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Update lesson successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Update lesson failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteLesson удаляет занятие id вместе с посещаемостью. Оценки занятия остаются без привязки к нему.
+func DeleteLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var lesson model.Lesson
+
+	err = json.NewDecoder(r.Body).Decode(&lesson)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	currentLesson, err := getLesson(lesson.ID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, currentLesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only delete lessons of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	deleteLessonQuery := `DELETE FROM lesson WHERE id = $1;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, deleteLessonQuery, lesson.ID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DeleteLesson QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Delete lesson successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Delete lesson failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelLesson отменяет занятие id. Отменённое занятие остаётся в расписании и календарях с пометкой отмены
+// и не занимает преподавателя, группу и аудиторию.
+func CancelLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var lesson model.Lesson
+
+	err = json.NewDecoder(r.Body).Decode(&lesson)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	currentLesson, err := getLesson(lesson.ID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, currentLesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only cancel lessons of subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	cancelLessonQuery := `
+		UPDATE lesson SET cancelled_at = now(), sequence = sequence + 1, updated_at = now()
+		WHERE id = $1 AND cancelled_at IS NULL;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, cancelLessonQuery, lesson.ID)
+
+	var cancelled int64
+	if err == nil {
+		cancelled, err = result.RowsAffected()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("CancelLesson ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if cancelled == 0 {
+		http.Error(w, "Lesson is already cancelled", http.StatusConflict)
+		return
+	}
+
+	resp, err := json.Marshal("Cancel lesson successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Cancel lesson failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListLessons возвращает занятия. Фильтры group_id, subject_id, professor_id, from и to необязательны.
+func ListLessons(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID, err := optionalIntParam(r, "group_id")
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	subjectID, err := optionalIntParam(r, "subject_id")
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	professorID, err := optionalIntParam(r, "professor_id")
+	if err != nil {
+		http.Error(w, "professor_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
+	listLessonsQuery := `SELECT ` + lessonColumns + `
+		WHERE ($1 = 0 OR l.group_id = $1) AND ($2 = 0 OR l.subject_id = $2) AND ($3 = 0 OR l.professor_id = $3)
+		  AND ($4::date IS NULL OR l.starts_at::date >= $4) AND ($5::date IS NULL OR l.starts_at::date <= $5)
+		ORDER BY l.starts_at ` + dateFilter.order + `, l.id ` + dateFilter.order
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listLessonsQuery, groupID, subjectID, professorID, dateFilter.from, dateFilter.to)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListLessons QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	lessons := []model.Lesson{}
+
+	for rows.Next() {
+		lesson, err := scanLesson(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		lessons = append(lessons, lesson)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(lessons)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Lessons failed: %v\n", err)
+	}
+}
@@ -0,0 +1,557 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	attendanceStatusPresent   = "present"
+	attendanceStatusLate      = "late"
+	attendanceStatusExcused   = "excused"
+	attendanceStatusLeftEarly = "left_early"
+	attendanceStatusRemote    = "remote"
+	attendanceStatusAbsent    = "absent"
+)
+
+func isValidAttendanceStatus(status string) bool {
+	switch status {
+	case attendanceStatusPresent, attendanceStatusLate, attendanceStatusExcused,
+		attendanceStatusLeftEarly, attendanceStatusRemote, attendanceStatusAbsent:
+		return true
+	}
+	return false
+}
+
+// attendanceProblem проверяет отметку посещаемости. Опоздание на сколько-то минут бывает только у статуса late.
+func attendanceProblem(attendance model.LessonAttendance) string {
+	if !isValidAttendanceStatus(attendance.Status) {
+		return "status must be present, late, excused, left_early, remote or absent"
+	}
+
+	if attendance.MinutesLate < 0 {
+		return "minutes_late cannot be negative"
+	}
+
+	if attendance.MinutesLate > 0 && attendance.Status != attendanceStatusLate {
+		return "minutes_late can only be set for late status"
+	}
+
+	if utf8.RuneCountInString(attendance.Note) > 1000 {
+		return "Maximum attendance note length is 1000 characters"
+	}
+
+	return ""
+}
+
+// lessonAttendance возвращает посещаемость занятия студентами studentID или всеми студентами, если studentID = 0.
+// Студенты без отметки возвращаются с пустым status.
+func lessonAttendance(ctx context.Context, lessonID, studentID int) ([]model.LessonAttendance, error) {
+	lessonAttendanceQuery := `
+		SELECT p.id, p.firstname, p.lastname, COALESCE(la.status, ''), COALESCE(la.minutes_late, 0),
+		       COALESCE(la.note, ''), COALESCE(la.recorded_by, 0), la.recorded_at
+		FROM lesson l
+		JOIN person p ON ` + lessonStudentCondition + `
+		LEFT JOIN lesson_attendance la ON la.lesson_id = l.id AND la.student_id = p.id
+		WHERE l.id = $1 AND ($2 = 0 OR p.id = $2)
+		ORDER BY p.lastname, p.firstname, p.id`
+
+	rows, err := db.QueryContext(ctx, lessonAttendanceQuery, lessonID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendance := []model.LessonAttendance{}
+
+	for rows.Next() {
+		entry := model.LessonAttendance{LessonID: lessonID}
+
+		if err := rows.Scan(&entry.StudentID, &entry.StudentFirstname, &entry.StudentLastname, &entry.Status,
+			&entry.MinutesLate, &entry.Note, &entry.RecordedBy, &entry.RecordedAt); err != nil {
+			return nil, err
+		}
+
+		attendance = append(attendance, entry)
+	}
+
+	return attendance, rows.Err()
+}
+
+// lessonGrades возвращает оценки за занятие студента studentID или всех студентов, если studentID = 0
+func lessonGrades(ctx context.Context, lessonID, studentID int) ([]model.StudentGrade, error) {
+	lessonGradesQuery := `
+		SELECT sg.id, sg.student_id, p.firstname, p.lastname, sg.subject_id, COALESCE(sg.category_id, 0),
+		       COALESCE(gc.category_name, ''), sg.grade, sg.graded_at, sg.lesson_date,
+		       COALESCE(sg.description, ''), COALESCE(sg.professor_comment, ''), COALESCE(la.status, '')
+		FROM student_grades sg
+		JOIN person p ON sg.student_id = p.id
+		LEFT JOIN grade_category gc ON sg.category_id = gc.id
+		LEFT JOIN lesson_attendance la ON la.lesson_id = sg.lesson_id AND la.student_id = sg.student_id
+		WHERE sg.lesson_id = $1 AND ($2 = 0 OR sg.student_id = $2)
+		ORDER BY p.lastname, p.firstname, sg.graded_at, sg.id`
+
+	rows, err := db.QueryContext(ctx, lessonGradesQuery, lessonID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grades := []model.StudentGrade{}
+
+	for rows.Next() {
+		studentGrade := model.StudentGrade{LessonID: lessonID}
+
+		if err := rows.Scan(
+			&studentGrade.ID,
+			&studentGrade.StudentID,
+			&studentGrade.StudentFirstname,
+			&studentGrade.StudentLastname,
+			&studentGrade.SubjectID,
+			&studentGrade.CategoryID,
+			&studentGrade.CategoryName,
+			&studentGrade.Grade,
+			&studentGrade.GradedAt,
+			&studentGrade.LessonDate,
+			&studentGrade.Description,
+			&studentGrade.ProfessorComment,
+			&studentGrade.AttendanceStatus); err != nil {
+			return nil, err
+		}
+
+		grades = append(grades, studentGrade)
+	}
+
+	return grades, rows.Err()
+}
+
+// lessonAttendanceProblem проверяет отметки attendance против студентов занятия students.
+// Возвращает пустую строку, если отметки корректны, иначе описание ошибки для клиента.
+func lessonAttendanceProblem(students, attendance []model.LessonAttendance) string {
+	lessonStudents := map[int]bool{}
+	for _, student := range students {
+		lessonStudents[student.StudentID] = true
+	}
+
+	for _, entry := range attendance {
+		if !lessonStudents[entry.StudentID] {
+			return "Student " + strconv.Itoa(entry.StudentID) + " does not attend this lesson"
+		}
+
+		if problem := attendanceProblem(entry); problem != "" {
+			return "Student " + strconv.Itoa(entry.StudentID) + ": " + problem
+		}
+	}
+
+	return ""
+}
+
+// saveLessonAttendance записывает отметки занятия в одной транзакции. Отметки attendance заменяют предыдущие.
+// Предложенные отметки proposed записываются только студентам без отметки или с пропуском, поэтому не затирают
+// уважительные причины, отметки по коду и пояснения преподавателя.
+func saveLessonAttendance(ctx context.Context, lessonID int, attendance, proposed []model.LessonAttendance,
+	actorID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range attendance {
+		_, err = recordLessonAttendance(ctx, tx, attendanceChange{
+			lessonID:    lessonID,
+			studentID:   entry.StudentID,
+			status:      entry.Status,
+			minutesLate: &entry.MinutesLate,
+			note:        &entry.Note,
+		}, actorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range proposed {
+		_, err = recordLessonAttendance(ctx, tx, attendanceChange{
+			lessonID:    lessonID,
+			studentID:   entry.StudentID,
+			status:      entry.Status,
+			minutesLate: &entry.MinutesLate,
+			replaceable: []string{attendanceStatusAbsent},
+		}, actorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLesson возвращает занятие id с посещаемостью и оценками.
+// Преподаватели занятия и администраторы видят всех студентов, студент - только себя.
+func GetLesson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramLessonID := r.URL.Query().Get("id")
+
+	lessonID, err := strconv.Atoi(paramLessonID)
+	if err != nil {
+		http.Error(w, "id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	lesson, err := getLesson(lessonID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	studentID := 0
+	if !canManage {
+		studentID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		hasStudent, err := lessonHasStudent(lessonID, studentID, lesson.SubjectID)
+		if err != nil {
+			log.Println("lessonHasStudent error: ", err)
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasStudent {
+			http.Error(w, "You can only view lessons that you teach or attend", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	lesson.Attendance, err = lessonAttendance(ctx, lessonID, studentID)
+	if err == nil {
+		lesson.Grades, err = lessonGrades(ctx, lessonID, studentID)
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetLesson QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(lesson)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Lesson failed: %v\n", err)
+	}
+}
+
+// RecordLessonAttendance отмечает посещаемость занятия lesson_id для перечисленных студентов
+func RecordLessonAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var entry model.LessonAttendanceEntry
+
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(entry.Attendance) == 0 {
+		http.Error(w, "Attendance cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	lesson, err := getLesson(entry.LessonID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only record attendance of lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	students, err := lessonAttendance(ctx, lesson.ID, 0)
+	if err != nil {
+		log.Println("lessonAttendance error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if problem := lessonAttendanceProblem(students, entry.Attendance); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	err = saveLessonAttendance(ctx, lesson.ID, entry.Attendance, nil, claims.Issuer)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("RecordLessonAttendance QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Посещаемость уже сохранена, ошибка уведомлений о посещаемости не отменяет запрос
+	if err := checkAttendanceAlerts(attendanceFilter{subjectID: lesson.SubjectID, groupID: lesson.GroupID}); err != nil {
+		log.Println("checkAttendanceAlerts error: ", err)
+	}
+
+	resp, err := json.Marshal("Record Lesson Attendance Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("RecordLessonAttendance failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListCurrentUserLessonAttendance возвращает занятия текущего студента и его посещаемость.
+// subject_id, from и to необязательны.
+func ListCurrentUserLessonAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	subjectID, err := optionalIntParam(r, "subject_id")
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	listCurrentUserLessonAttendanceQuery := `
+		SELECT l.id, l.subject_id, s.subject_name, l.starts_at, l.ends_at, COALESCE(l.topic, ''),
+		       COALESCE(la.status, ''), COALESCE(la.minutes_late, 0), COALESCE(la.note, '')
+		FROM person p
+		JOIN lesson l ON ` + lessonStudentCondition + `
+		JOIN subject s ON l.subject_id = s.id
+		LEFT JOIN lesson_attendance la ON la.lesson_id = l.id AND la.student_id = p.id
+		WHERE p.id = $1 AND ($2 = 0 OR l.subject_id = $2)
+		  AND ($3::date IS NULL OR l.starts_at::date >= $3) AND ($4::date IS NULL OR l.starts_at::date <= $4)
+		ORDER BY l.starts_at ` + dateFilter.order + `, l.id ` + dateFilter.order
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listCurrentUserLessonAttendanceQuery, claims.Issuer, subjectID, dateFilter.from, dateFilter.to)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListCurrentUserLessonAttendance QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	attendance := []model.StudentLessonAttendance{}
+
+	for rows.Next() {
+		var entry model.StudentLessonAttendance
+
+		if err := rows.Scan(&entry.LessonID, &entry.SubjectID, &entry.SubjectName, &entry.StartsAt, &entry.EndsAt,
+			&entry.Topic, &entry.Status, &entry.MinutesLate, &entry.Note); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		attendance = append(attendance, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(attendance)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Current User Lesson Attendance failed: %v\n", err)
+	}
+}
@@ -0,0 +1,550 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// livekitEarlyJoin - насколько раньше начала занятия подключение к комнате уже относится к занятию
+const livekitEarlyJoin = 15 * time.Minute
+
+// participantSession - подключение студента к комнате занятия. Для незавершённого подключения leftAt = nil.
+type participantSession struct {
+	studentID int
+	joinedAt  time.Time
+	leftAt    *time.Time
+}
+
+// webhookEventTime возвращает время события LiveKit, а если LiveKit его не передал - текущее время
+func webhookEventTime(unixSeconds int64) time.Time {
+	if unixSeconds == 0 {
+		return time.Now()
+	}
+	return time.Unix(unixSeconds, 0)
+}
+
+// participantStudentID возвращает id пользователя участника комнаты. getJoinToken записывает его в identity.
+func participantStudentID(participant *livekit.ParticipantInfo) (int, bool) {
+	studentID, err := strconv.Atoi(participant.GetIdentity())
+	if err != nil || studentID <= 0 {
+		return 0, false
+	}
+	return studentID, true
+}
+
+// recordParticipantJoined привязывает подключение участника к занятию, которое идёт в комнате в момент подключения.
+// Подключения преподавателей и людей, не занимающихся на этом занятии, не записываются.
+func recordParticipantJoined(ctx context.Context, roomID, studentID int, participantSID string, joinedAt time.Time) error {
+	participantJoinedQuery := `
+		INSERT INTO lesson_participant_session (lesson_id, student_id, participant_sid, joined_at)
+		SELECT l.id, p.id, $3, $4
+		FROM lesson l
+		JOIN person p ON ` + lessonStudentCondition + `
+		WHERE l.room_id = $1 AND p.id = $2
+		  AND $4 >= l.starts_at - make_interval(mins => $5) AND $4 < l.ends_at
+		ORDER BY l.starts_at
+		LIMIT 1
+		ON CONFLICT (lesson_id, participant_sid) DO NOTHING;`
+
+	_, err := db.ExecContext(ctx, participantJoinedQuery, roomID, studentID, participantSID, joinedAt,
+		int(livekitEarlyJoin.Minutes()))
+
+	return err
+}
+
+// recordParticipantLeft завершает подключение участника participantSID
+func recordParticipantLeft(ctx context.Context, participantSID string, leftAt time.Time) error {
+	participantLeftQuery := `
+		UPDATE lesson_participant_session SET left_at = GREATEST($2, joined_at)
+		WHERE participant_sid = $1 AND left_at IS NULL;`
+
+	_, err := db.ExecContext(ctx, participantLeftQuery, participantSID, leftAt)
+
+	return err
+}
+
+// recordRoomFinished завершает все незавершённые подключения к комнате roomID
+func recordRoomFinished(ctx context.Context, roomID int, finishedAt time.Time) error {
+	roomFinishedQuery := `
+		UPDATE lesson_participant_session lps SET left_at = GREATEST($2, lps.joined_at)
+		FROM lesson l
+		WHERE lps.lesson_id = l.id AND l.room_id = $1 AND lps.left_at IS NULL;`
+
+	_, err := db.ExecContext(ctx, roomFinishedQuery, roomID, finishedAt)
+
+	return err
+}
+
+// LiveKitWebhook принимает события LiveKit и записывает подключения студентов к комнатам занятий.
+// Подпись запроса проверяется ключами LiveKit, cookie не нужен. Без заданных LIVEKIT_API_KEY
+// и LIVEKIT_API_SECRET вебхуки отклоняются.
+func LiveKitWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !livekitKeysConfigured {
+		http.Error(w, "LiveKit webhooks are disabled: LIVEKIT_API_KEY and LIVEKIT_API_SECRET are not set", http.StatusServiceUnavailable)
+		return
+	}
+
+	event, err := webhook.ReceiveWebhookEvent(r, auth.NewSimpleKeyProvider(livekitAPIKey, livekitAPISecret))
+	if err != nil {
+		log.Println("ReceiveWebhookEvent error: ", err)
+		http.Error(w, "Invalid webhook signature or payload", http.StatusUnauthorized)
+		return
+	}
+
+	// Комната LiveKit называется по id комнаты, события чужих комнат пропускаются
+	roomID, err := strconv.Atoi(event.GetRoom().GetName())
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	switch event.GetEvent() {
+	case webhook.EventParticipantJoined:
+		joinedAt := event.GetParticipant().GetJoinedAt()
+		if joinedAt == 0 {
+			joinedAt = event.GetCreatedAt()
+		}
+		if studentID, ok := participantStudentID(event.GetParticipant()); ok {
+			err = recordParticipantJoined(ctx, roomID, studentID, event.GetParticipant().GetSid(), webhookEventTime(joinedAt))
+		}
+	case webhook.EventParticipantLeft:
+		err = recordParticipantLeft(ctx, event.GetParticipant().GetSid(), webhookEventTime(event.GetCreatedAt()))
+	case webhook.EventRoomFinished:
+		err = recordRoomFinished(ctx, roomID, webhookEventTime(event.GetCreatedAt()))
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("LiveKitWebhook ExecContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("LiveKitWebhook database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// lessonParticipantSessions возвращает подключения студентов к комнате занятия по студентам
+func lessonParticipantSessions(ctx context.Context, lessonID int) (map[int][]participantSession, error) {
+	sessionsQuery := `
+		SELECT student_id, joined_at, left_at FROM lesson_participant_session
+		WHERE lesson_id = $1
+		ORDER BY joined_at`
+
+	rows, err := db.QueryContext(ctx, sessionsQuery, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := map[int][]participantSession{}
+
+	for rows.Next() {
+		var session participantSession
+		var leftAt sql.NullTime
+
+		if err := rows.Scan(&session.studentID, &session.joinedAt, &leftAt); err != nil {
+			return nil, err
+		}
+
+		if leftAt.Valid {
+			session.leftAt = &leftAt.Time
+		}
+
+		sessions[session.studentID] = append(sessions[session.studentID], session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// proposeAttendance предлагает отметку студента по его подключениям и порогам правил предмета.
+// Время в комнате считается только в пределах занятия, пересекающиеся подключения (несколько устройств)
+// учитываются один раз. Незавершённое подключение длится до now.
+func proposeAttendance(entry *model.LessonAutoAttendance, lesson model.Lesson, sessions []participantSession,
+	rule model.AttendanceRule, now time.Time) {
+	entry.Status = attendanceStatusAbsent
+
+	if len(sessions) == 0 {
+		return
+	}
+
+	type interval struct{ from, to time.Time }
+	var intervals []interval
+
+	// Подключения упорядочены по времени подключения, поэтому и отрезки упорядочены по началу
+	firstJoinedAt := sessions[0].joinedAt
+	entry.FirstJoinedAt = &firstJoinedAt
+
+	for _, session := range sessions {
+		to := now
+		if session.leftAt == nil {
+			entry.InRoom = true
+		} else {
+			to = *session.leftAt
+			if entry.LastLeftAt == nil || to.After(*entry.LastLeftAt) {
+				leftAt := to
+				entry.LastLeftAt = &leftAt
+			}
+		}
+
+		from := session.joinedAt
+		if from.Before(lesson.StartsAt) {
+			from = lesson.StartsAt
+		}
+		if to.After(lesson.EndsAt) {
+			to = lesson.EndsAt
+		}
+		if to.After(from) {
+			intervals = append(intervals, interval{from, to})
+		}
+	}
+
+	if entry.InRoom {
+		entry.LastLeftAt = nil
+	}
+
+	var inRoom time.Duration
+	var coveredUntil time.Time
+
+	for _, current := range intervals {
+		if current.from.Before(coveredUntil) {
+			current.from = coveredUntil
+		}
+		if current.to.After(current.from) {
+			inRoom += current.to.Sub(current.from)
+			coveredUntil = current.to
+		}
+	}
+
+	entry.MinutesInRoom = int(inRoom.Minutes())
+	entry.PresencePercent = roundHundredths(float64(inRoom) / float64(lesson.EndsAt.Sub(lesson.StartsAt)) * 100)
+
+	if entry.PresencePercent < float64(rule.MinPresencePercent) {
+		return
+	}
+
+	entry.Status = attendanceStatusPresent
+
+	minutesLate := int(entry.FirstJoinedAt.Sub(lesson.StartsAt).Minutes())
+	if minutesLate > rule.LateAfterMinutes {
+		entry.Status = attendanceStatusLate
+		entry.MinutesLate = minutesLate
+	}
+}
+
+// lessonAutoAttendance предлагает отметки всех студентов занятия по подключениям к комнате LiveKit
+func lessonAutoAttendance(ctx context.Context, lesson model.Lesson) ([]model.LessonAutoAttendance, error) {
+	rule, err := getAttendanceRule(lesson.SubjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	students, err := lessonAttendance(ctx, lesson.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := lessonParticipantSessions(ctx, lesson.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	proposals := []model.LessonAutoAttendance{}
+
+	for _, student := range students {
+		entry := model.LessonAutoAttendance{
+			StudentID:        student.StudentID,
+			StudentFirstname: student.StudentFirstname,
+			StudentLastname:  student.StudentLastname,
+			RecordedStatus:   student.Status,
+		}
+
+		proposeAttendance(&entry, lesson, sessions[student.StudentID], rule, now)
+
+		proposals = append(proposals, entry)
+	}
+
+	return proposals, nil
+}
+
+// GetLessonAutoAttendance возвращает отметки студентов занятия lesson_id, предложенные по подключениям к LiveKit,
+// чтобы преподаватель проверил их перед записью
+func GetLessonAutoAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramLessonID := r.URL.Query().Get("lesson_id")
+
+	lessonID, err := strconv.Atoi(paramLessonID)
+	if err != nil {
+		http.Error(w, "lesson_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	lesson, err := getLesson(lessonID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only review attendance of lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	proposals, err := lessonAutoAttendance(ctx, lesson)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetLessonAutoAttendance QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("lessonAutoAttendance error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(proposals)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Lesson Auto Attendance failed: %v\n", err)
+	}
+}
+
+// ConfirmLessonAutoAttendance записывает проверенные преподавателем отметки занятия lesson_id,
+// предложенные по подключениям к LiveKit. Отметки из attendance заменяют предложенные для своих студентов.
+// Предложения записываются только студентам без отметки или с пропуском.
+func ConfirmLessonAutoAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var entry model.LessonAttendanceEntry
+
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	lesson, err := getLesson(entry.LessonID)
+	if err != nil {
+		if errors.Is(err, errLessonNotFound) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Println("getLesson error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, lesson)
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only record attendance of lessons that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	if time.Now().Before(lesson.EndsAt) {
+		http.Error(w, "Lesson has not ended yet", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	students, err := lessonAttendance(ctx, lesson.ID, 0)
+	if err != nil {
+		log.Println("lessonAttendance error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if problem := lessonAttendanceProblem(students, entry.Attendance); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	proposals, err := lessonAutoAttendance(ctx, lesson)
+	if err != nil {
+		log.Println("lessonAutoAttendance error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	overridden := map[int]bool{}
+	for _, attendance := range entry.Attendance {
+		overridden[attendance.StudentID] = true
+	}
+
+	// Предложения не заменяют уважительные причины и отметки по коду, только пропуски
+	var proposed []model.LessonAttendance
+	for _, proposal := range proposals {
+		if !overridden[proposal.StudentID] &&
+			(proposal.RecordedStatus == "" || proposal.RecordedStatus == attendanceStatusAbsent) {
+			proposed = append(proposed, model.LessonAttendance{
+				StudentID:   proposal.StudentID,
+				Status:      proposal.Status,
+				MinutesLate: proposal.MinutesLate,
+			})
+		}
+	}
+
+	err = saveLessonAttendance(ctx, lesson.ID, entry.Attendance, proposed, claims.Issuer)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ConfirmLessonAutoAttendance QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Посещаемость уже сохранена, ошибка уведомлений о посещаемости не отменяет запрос
+	if err := checkAttendanceAlerts(attendanceFilter{subjectID: lesson.SubjectID, groupID: lesson.GroupID}); err != nil {
+		log.Println("checkAttendanceAlerts error: ", err)
+	}
+
+	resp, err := json.Marshal("Confirm Lesson Auto Attendance Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("ConfirmLessonAutoAttendance failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"google.golang.org/protobuf/encoding/protojson"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Identity из токена комнаты должна доходить до вебхука и указывать на студента
+func TestJoinTokenIdentityReachesWebhook(t *testing.T) {
+	joinToken, err := getJoinToken(7, "42", "Ivanov Ivan (ivanov ID: 42)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := auth.ParseAPIToken(joinToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := verifier.Verify(livekitAPISecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Name != "Ivanov Ivan (ivanov ID: 42)" {
+		t.Errorf("token name = %q", claims.Name)
+	}
+
+	// LiveKit подписывает событие так же, как URLNotifier
+	event := &livekit.WebhookEvent{
+		Event:       webhook.EventParticipantJoined,
+		Room:        &livekit.Room{Name: "7"},
+		Participant: &livekit.ParticipantInfo{Sid: "PA_1", Identity: verifier.Identity(), JoinedAt: time.Now().Unix()},
+	}
+
+	body, err := protojson.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(body)
+	webhookToken, err := auth.NewAccessToken(livekitAPIKey, livekitAPISecret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/api/livekit-webhook", bytes.NewReader(body))
+	r.Header.Set("Authorization", webhookToken)
+
+	received, err := webhook.ReceiveWebhookEvent(r, auth.NewSimpleKeyProvider(livekitAPIKey, livekitAPISecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	studentID, ok := participantStudentID(received.GetParticipant())
+	if !ok || studentID != 42 {
+		t.Errorf("participantStudentID = %d, %v, want 42, true", studentID, ok)
+	}
+}
+
+func TestParticipantStudentIDRejectsNonNumericIdentity(t *testing.T) {
+	for _, identity := range []string{"", "ivanov", "Ivanov Ivan (ivanov ID: 42)", "0", "-3"} {
+		if _, ok := participantStudentID(&livekit.ParticipantInfo{Identity: identity}); ok {
+			t.Errorf("participantStudentID(%q) accepted", identity)
+		}
+	}
+}
+
+func TestProposeAttendance(t *testing.T) {
+	startsAt := time.Date(2024, 10, 7, 10, 0, 0, 0, time.UTC)
+	lesson := model.Lesson{StartsAt: startsAt, EndsAt: startsAt.Add(90 * time.Minute)}
+	rule := model.AttendanceRule{LateAfterMinutes: 10, MinPresencePercent: 50}
+
+	at := func(minutes int) time.Time {
+		return startsAt.Add(time.Duration(minutes) * time.Minute)
+	}
+	left := func(minutes int) *time.Time {
+		leftAt := at(minutes)
+		return &leftAt
+	}
+
+	tests := []struct {
+		name          string
+		sessions      []participantSession
+		now           time.Time
+		status        string
+		minutesLate   int
+		minutesInRoom int
+		percent       float64
+		inRoom        bool
+	}{
+		{"no sessions", nil, at(120), attendanceStatusAbsent, 0, 0, 0, false},
+		{"whole lesson", []participantSession{{joinedAt: at(0), leftAt: left(90)}}, at(120),
+			attendanceStatusPresent, 0, 90, 100, false},
+		{"joined before start and left after end", []participantSession{{joinedAt: at(-10), leftAt: left(120)}}, at(120),
+			attendanceStatusPresent, 0, 90, 100, false},
+		{"late", []participantSession{{joinedAt: at(15), leftAt: left(90)}}, at(120),
+			attendanceStatusLate, 15, 75, 83.33, false},
+		{"within late threshold", []participantSession{{joinedAt: at(10), leftAt: left(90)}}, at(120),
+			attendanceStatusPresent, 0, 80, 88.89, false},
+		{"too short", []participantSession{{joinedAt: at(0), leftAt: left(30)}}, at(120),
+			attendanceStatusAbsent, 0, 30, 33.33, false},
+		{"overlapping devices counted once", []participantSession{
+			{joinedAt: at(0), leftAt: left(60)},
+			{joinedAt: at(30), leftAt: left(90)},
+		}, at(120), attendanceStatusPresent, 0, 90, 100, false},
+		{"reconnect after a break", []participantSession{
+			{joinedAt: at(0), leftAt: left(20)},
+			{joinedAt: at(50), leftAt: left(80)},
+		}, at(120), attendanceStatusPresent, 0, 50, 55.56, false},
+		{"still in room", []participantSession{{joinedAt: at(0)}}, at(45),
+			attendanceStatusPresent, 0, 45, 50, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var entry model.LessonAutoAttendance
+			proposeAttendance(&entry, lesson, test.sessions, rule, test.now)
+
+			if entry.Status != test.status || entry.MinutesLate != test.minutesLate {
+				t.Errorf("status = %q, minutes late %d, want %q, %d", entry.Status, entry.MinutesLate, test.status, test.minutesLate)
+			}
+			if entry.MinutesInRoom != test.minutesInRoom || entry.PresencePercent != test.percent {
+				t.Errorf("in room %d min, %v%%, want %d min, %v%%",
+					entry.MinutesInRoom, entry.PresencePercent, test.minutesInRoom, test.percent)
+			}
+			if entry.InRoom != test.inRoom {
+				t.Errorf("InRoom = %v, want %v", entry.InRoom, test.inRoom)
+			}
+			if test.inRoom && entry.LastLeftAt != nil {
+				t.Errorf("LastLeftAt = %v for a student still in the room", entry.LastLeftAt)
+			}
+			if len(test.sessions) > 0 && (entry.FirstJoinedAt == nil || !entry.FirstJoinedAt.Equal(test.sessions[0].joinedAt)) {
+				t.Errorf("FirstJoinedAt = %v, want %v", entry.FirstJoinedAt, test.sessions[0].joinedAt)
+			}
+		})
+	}
+}
+
+// Без явно заданных ключей вебхук отклоняется, даже если подписан ключами dev-сервера
+func TestLiveKitWebhookRequiresConfiguredKeys(t *testing.T) {
+	body := []byte(`{"event":"participant_joined","room":{"name":"7"}}`)
+	sum := sha256.Sum256(body)
+	webhookToken, err := auth.NewAccessToken(livekitAPIKey, livekitAPISecret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(configured bool) { livekitKeysConfigured = configured }(livekitKeysConfigured)
+
+	tests := []struct {
+		name       string
+		configured bool
+		token      string
+		want       int
+	}{
+		{"keys not set", false, webhookToken, http.StatusServiceUnavailable},
+		{"keys set, unsigned request", true, "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			livekitKeysConfigured = test.configured
+
+			r := httptest.NewRequest("POST", "/api/livekit-webhook", bytes.NewReader(body))
+			r.Header.Set("Authorization", test.token)
+			w := httptest.NewRecorder()
+
+			LiveKitWebhook(w, r)
+
+			if w.Code != test.want {
+				t.Errorf("status = %d, want %d", w.Code, test.want)
+			}
+		})
+	}
+}
@@ -77,6 +77,9 @@ func professorHasSubject(issuer string, subjectID int) (bool, error) {
 	err := db.QueryRowContext(ctx, isAdminQuery, issuer, subjectID).Scan(&hasSubject)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
 		return false, err
 	}
 
@@ -89,42 +92,208 @@ func professorHasGroup(issuer string, studentID int) (bool, error) {
 
 	var hasGroup bool
 	log.Println("professorHasGroup: issuer = ", issuer, " studentID = ", studentID)
+	// Связь без подгруппы даёт доступ ко всей группе, с подгруппой - только к её студентам
 	isAdminQuery := `
 		SELECT true 
 		FROM professor_group pg 
 		LEFT JOIN person p ON pg.group_id = p.group_id
-		WHERE professor_id = $1 AND p.id = $2`
+		WHERE professor_id = $1 AND p.id = $2 
+		  AND (pg.subgroup_id IS NULL OR pg.subgroup_id = p.subgroup_id)
+		LIMIT 1`
 
 	err := db.QueryRowContext(ctx, isAdminQuery, issuer, studentID).Scan(&hasGroup)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
 		return false, err
 	}
 
 	return hasGroup, nil
 }
 
+// professorHasEnrolledStudent проверяет, что студент индивидуально записан на предмет subjectID,
+// который ведёт преподаватель. Такие студенты могут быть не из групп преподавателя.
+func professorHasEnrolledStudent(issuer string, studentID, subjectID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var hasStudent bool
+
+	professorHasEnrolledStudentQuery := `
+		SELECT true
+		FROM enrollment e
+		JOIN professor_subject ps ON e.subject_id = ps.subject_id
+		WHERE ps.professor_id = $1 AND e.student_id = $2 AND e.subject_id = $3 AND e.status = 'enrolled'`
+
+	err := db.QueryRowContext(ctx, professorHasEnrolledStudentQuery, issuer, studentID, subjectID).Scan(&hasStudent)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return hasStudent, nil
+}
+
 func studentHasSubject(studentID, subjectID int) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
 	var hasSubject bool
 
+	// Предмет есть у студента либо через его группу, либо через индивидуальную запись
 	isAdminQuery := `
 		SELECT true 
 		FROM person p
 		JOIN group_subject gs ON p.group_id = gs.group_id
-		WHERE p.id = $1 AND gs.subject_id = $2`
+		WHERE p.id = $1 AND gs.subject_id = $2
+		UNION
+		SELECT true
+		FROM enrollment e
+		WHERE e.student_id = $1 AND e.subject_id = $2 AND e.status = 'enrolled'`
 
 	err := db.QueryRowContext(ctx, isAdminQuery, studentID, subjectID).Scan(&hasSubject)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return hasSubject, nil
+}
+
+// canManageSubject разрешает настройку предмета администраторам и преподавателям, которые его ведут
+func canManageSubject(issuer string, subjectID int) (bool, error) {
+	isAdmin, err := isAdmin(issuer)
+	if err != nil {
+		return false, err
+	}
+
+	if isAdmin {
+		return true, nil
+	}
+
+	return professorHasSubject(issuer, subjectID)
+}
+
+// subgroupBelongsToGroup проверяет, что подгруппа subgroupID является частью группы groupID
+func subgroupBelongsToGroup(subgroupID, groupID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var belongs bool
+
+	subgroupBelongsToGroupQuery := `SELECT true FROM subgroup WHERE id = $1 AND group_id = $2`
+
+	err := db.QueryRowContext(ctx, subgroupBelongsToGroupQuery, subgroupID, groupID).Scan(&belongs)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return belongs, nil
+}
+
+// professorTeachesGroup проверяет, что преподаватель ведёт группу groupID целиком
+// или её подгруппу subgroupID (subgroupID = 0 - вся группа)
+func professorTeachesGroup(issuer string, groupID, subgroupID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var teaches bool
+
+	professorTeachesGroupQuery := `
+		SELECT true FROM professor_group
+		WHERE professor_id = $1 AND group_id = $2 AND (subgroup_id IS NULL OR subgroup_id = $3)
+		LIMIT 1`
+
+	err := db.QueryRowContext(ctx, professorTeachesGroupQuery, issuer, groupID, subgroupID).Scan(&teaches)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return teaches, nil
+}
+
+// groupHasSubject проверяет, что предмет subjectID входит в программу группы groupID
+func groupHasSubject(groupID, subjectID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var hasSubject bool
+
+	groupHasSubjectQuery := `SELECT true FROM group_subject WHERE group_id = $1 AND subject_id = $2`
+
+	err := db.QueryRowContext(ctx, groupHasSubjectQuery, groupID, subjectID).Scan(&hasSubject)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
 		return false, err
 	}
 
 	return hasSubject, nil
 }
 
+// subgroupHasSubject проверяет, что подгруппа subgroupID входит в группу, у которой есть предмет subjectID
+func subgroupHasSubject(subgroupID, subjectID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var hasSubject bool
+
+	subgroupHasSubjectQuery := `
+		SELECT true FROM subgroup sg
+		JOIN group_subject gs ON gs.group_id = sg.group_id
+		WHERE sg.id = $1 AND gs.subject_id = $2`
+
+	err := db.QueryRowContext(ctx, subgroupHasSubjectQuery, subgroupID, subjectID).Scan(&hasSubject)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return hasSubject, nil
+}
+
+// categoryBelongsToSubject проверяет, что категория оценок categoryID задана для предмета subjectID
+func categoryBelongsToSubject(categoryID, subjectID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var belongs bool
+
+	categoryBelongsToSubjectQuery := `SELECT true FROM grade_category WHERE id = $1 AND subject_id = $2`
+
+	err := db.QueryRowContext(ctx, categoryBelongsToSubjectQuery, categoryID, subjectID).Scan(&belongs)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return belongs, nil
+}
+
 func isStudent(issuer string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
@@ -186,6 +355,27 @@ func InitConnPool() error {
 		return errors.New("environment variable JWT_NAME is empty")
 	}
 
+	pdfFontPath = os.Getenv("PDF_FONT_PATH")
+	if pdfFontPath == "" {
+		log.Println("environment variable PDF_FONT_PATH is empty, PDF transcripts are disabled")
+	}
+
+	roomLinkURL = os.Getenv("ROOM_LINK_URL")
+	if roomLinkURL == "" {
+		roomLinkURL = "http://" + os.Getenv("CORS_ORIGIN") + "/room/"
+	}
+
+	if apiKey := os.Getenv("LIVEKIT_API_KEY"); apiKey != "" {
+		livekitAPIKey = apiKey
+		livekitAPISecret = os.Getenv("LIVEKIT_API_SECRET")
+		if livekitAPISecret == "" {
+			return errors.New("environment variable LIVEKIT_API_SECRET is empty")
+		}
+		livekitKeysConfigured = true
+	} else {
+		log.Println("environment variable LIVEKIT_API_KEY is empty, LiveKit webhooks are disabled")
+	}
+
 	db, err = postgres.Dial()
 	if err != nil {
 		return err
@@ -92,10 +92,24 @@ func SignupPerson(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if person.SubgroupID != 0 {
+		belongs, err := subgroupBelongsToGroup(person.SubgroupID, person.GroupID)
+		if err != nil {
+			log.Println("subgroupBelongsToGroup error: ", err)
+			http.Error(w, "Error while checking subgroup", http.StatusInternalServerError)
+			return
+		}
+
+		if !belongs {
+			http.Error(w, "Subgroup does not belong to this group", http.StatusBadRequest)
+			return
+		}
+	}
+
 	insertPersonQuery := `INSERT INTO person (username, password, firstname, lastname, email, phone_number, group_id,
-                    is_professor, is_admin, sex, birthDate) 
+                    is_professor, is_admin, sex, birthDate, subgroup_id) 
 					VALUES ($1::text, $2::text, $3::text, $4::text, $5::text, $6::text, $7::numeric, 
-					        $8::bool, $9::bool, $10::text, $11::date);`
+					        $8::bool, $9::bool, $10::text, $11::date, NULLIF($12, 0));`
 
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(person.Password), 14)
 	if err != nil {
@@ -118,6 +132,7 @@ func SignupPerson(w http.ResponseWriter, r *http.Request) {
 		person.IsAdmin,
 		person.Sex,
 		person.BirthDate,
+		person.SubgroupID,
 	)
 
 	if err != nil {
@@ -21,10 +21,12 @@ func ListProfessorsGroups(w http.ResponseWriter, r *http.Request) {
 
 	listProfessorsGroupsQuery := `
 	SELECT pg.professor_id, p.firstname, p.lastname, p.email,
-	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name
+	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name,
+	       COALESCE(pg.subgroup_id, 0), COALESCE(sg.subgroup_name, '')
 	FROM professor_group pg
 	JOIN person p ON pg.professor_id = p.id
     JOIN group_uni g ON pg.group_id = g.id
+	LEFT JOIN subgroup sg ON pg.subgroup_id = sg.id
 	ORDER BY pg.professor_id;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
@@ -60,7 +62,8 @@ func ListProfessorsGroups(w http.ResponseWriter, r *http.Request) {
 		var group model.Group
 
 		if err := rows.Scan(&professor.ProfessorID, &professor.FirstName, &professor.LastName, &professor.Email,
-			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName); err != nil {
+			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName,
+			&group.SubgroupID, &group.SubgroupName); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -119,10 +122,12 @@ func ListGroupsOfAProfessors(w http.ResponseWriter, r *http.Request) {
 
 	listGroupsOfAProfessorsQuery := `
 	SELECT pg.professor_id, p.firstname, p.lastname, p.email,
-	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name
+	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name,
+	       COALESCE(pg.subgroup_id, 0), COALESCE(sg.subgroup_name, '')
 	FROM professor_group pg
 	JOIN person p ON pg.professor_id = p.id
     JOIN group_uni g ON pg.group_id = g.id
+	LEFT JOIN subgroup sg ON pg.subgroup_id = sg.id
 	WHERE pg.professor_id = $1
 	ORDER BY pg.professor_id;`
 
@@ -159,7 +164,8 @@ func ListGroupsOfAProfessors(w http.ResponseWriter, r *http.Request) {
 		var group model.Group
 
 		if err := rows.Scan(&professor.ProfessorID, &professor.FirstName, &professor.LastName, &professor.Email,
-			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName); err != nil {
+			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName,
+			&group.SubgroupID, &group.SubgroupName); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -218,10 +224,12 @@ func ListProfessorsThatHaveAGroup(w http.ResponseWriter, r *http.Request) {
 
 	listProfessorsThatHaveAGroupQuery := `
 	SELECT pg.professor_id, p.firstname, p.lastname, p.email,
-	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name
+	       p.phone_number, p.sex, p.birthdate, pg.group_id, g.group_name,
+	       COALESCE(pg.subgroup_id, 0), COALESCE(sg.subgroup_name, '')
 	FROM professor_group pg
 	JOIN person p ON pg.professor_id = p.id
     JOIN group_uni g ON pg.group_id = g.id
+	LEFT JOIN subgroup sg ON pg.subgroup_id = sg.id
 	WHERE pg.group_id = $1
 	ORDER BY pg.professor_id;`
 
@@ -258,7 +266,8 @@ func ListProfessorsThatHaveAGroup(w http.ResponseWriter, r *http.Request) {
 		var group model.Group
 
 		if err := rows.Scan(&professor.ProfessorID, &professor.FirstName, &professor.LastName, &professor.Email,
-			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName); err != nil {
+			&professor.PhoneNumber, &professor.Sex, &professor.BirthDate, &group.ID, &group.GroupName,
+			&group.SubgroupID, &group.SubgroupName); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -354,12 +363,29 @@ func AddProfessorGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	insertProfessorGroupQuery := `INSERT INTO professor_group (professor_id, group_id) VALUES ($1, $2);`
+	if professorGroup.SubgroupID != 0 {
+		belongs, err := subgroupBelongsToGroup(professorGroup.SubgroupID, professorGroup.GroupID)
+		if err != nil {
+			log.Println("subgroupBelongsToGroup error: ", err)
+			http.Error(w, "Error while checking subgroup", http.StatusInternalServerError)
+			return
+		}
+
+		if !belongs {
+			http.Error(w, "Subgroup does not belong to this group", http.StatusBadRequest)
+			return
+		}
+	}
+
+	insertProfessorGroupQuery := `
+		INSERT INTO professor_group (professor_id, group_id, subgroup_id) 
+		VALUES ($1, $2, NULLIF($3, 0));`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, insertProfessorGroupQuery, professorGroup.ProfessorID, professorGroup.GroupID)
+	_, err = db.ExecContext(ctx, insertProfessorGroupQuery, professorGroup.ProfessorID, professorGroup.GroupID,
+		professorGroup.SubgroupID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -454,14 +480,29 @@ func UpdateProfessorGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	updateProfessorGroupQuery := `UPDATE professor_group SET professor_id = $1, group_id = $2
-	WHERE professor_id = $3 AND group_id = $4;`
+	if updateProfessorGroup.SubgroupID != 0 {
+		belongs, err := subgroupBelongsToGroup(updateProfessorGroup.SubgroupID, updateProfessorGroup.GroupID)
+		if err != nil {
+			log.Println("subgroupBelongsToGroup error: ", err)
+			http.Error(w, "Error while checking subgroup", http.StatusInternalServerError)
+			return
+		}
+
+		if !belongs {
+			http.Error(w, "Subgroup does not belong to this group", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updateProfessorGroupQuery := `UPDATE professor_group SET professor_id = $1, group_id = $2, subgroup_id = NULLIF($3, 0)
+	WHERE professor_id = $4 AND group_id = $5 AND subgroup_id IS NOT DISTINCT FROM NULLIF($6, 0);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
 	_, err = db.ExecContext(ctx, updateProfessorGroupQuery, updateProfessorGroup.ProfessorID,
-		updateProfessorGroup.GroupID, updateProfessorGroup.OldProfessorID, updateProfessorGroup.OldGroupID)
+		updateProfessorGroup.GroupID, updateProfessorGroup.SubgroupID, updateProfessorGroup.OldProfessorID,
+		updateProfessorGroup.OldGroupID, updateProfessorGroup.OldSubgroupID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -563,12 +604,25 @@ func DeleteProfessorGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteProfessorGroupQuery := `DELETE FROM professor_group WHERE professor_id = $1 and group_id = $2;`
+	// subgroup_id необязателен, без него удаляется связь со всей группой
+	subgroupID := 0
+	ParamSubgroupID := r.URL.Query().Get("subgroup_id")
+	if ParamSubgroupID != "" {
+		subgroupID, err = strconv.Atoi(ParamSubgroupID)
+		if err != nil {
+			http.Error(w, "subgroup_id must be an integer number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	deleteProfessorGroupQuery := `
+		DELETE FROM professor_group 
+		WHERE professor_id = $1 and group_id = $2 AND subgroup_id IS NOT DISTINCT FROM NULLIF($3, 0);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, deleteProfessorGroupQuery, professorID, groupID)
+	_, err = db.ExecContext(ctx, deleteProfessorGroupQuery, professorID, groupID, subgroupID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
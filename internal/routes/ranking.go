@@ -0,0 +1,556 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	rankByWeighted   = "weighted"
+	rankByTotal      = "total"
+	rankByAttendance = "attendance"
+)
+
+func isValidRankBy(rankBy string) bool {
+	return rankBy == rankByWeighted || rankBy == rankByTotal || rankBy == rankByAttendance
+}
+
+// rankingValues возвращает студентов группы и их значения для рейтинга.
+// hasValue = false у студентов без оценок (без итоговой оценки, без записей о посещении).
+func rankingValues(groupID, subjectID int, rankBy string) (entries []model.RankingEntry, hasValue []bool, err error) {
+	if rankBy == rankByWeighted {
+		scores, err := weightedScores(groupID, 0, subjectID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, score := range scores {
+			graded := false
+			for _, category := range score.Categories {
+				if category.GradeCount > 0 {
+					graded = true
+				}
+			}
+
+			entries = append(entries, model.RankingEntry{
+				StudentID:        score.StudentID,
+				StudentFirstname: score.StudentFirstname,
+				StudentLastname:  score.StudentLastname,
+				Value:            score.Score,
+			})
+			hasValue = append(hasValue, graded)
+		}
+
+		return entries, hasValue, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	// Итоговая оценка (последняя попытка) сравнивается по числовому эквиваленту шкалы предмета,
+	// посещаемость - как доля посещённых занятий по правилам предмета
+	valuesQuery := `
+		SELECT p.id, p.firstname, p.lastname, gsv.numeric_value::float8
+		FROM person p` + latestTotalGradeJoin("p.id", "$2") + `
+		LEFT JOIN subject s ON s.id = stg.subject_id
+		LEFT JOIN grading_scale_value gsv ON gsv.scale_id = s.grading_scale_id AND gsv.value = stg.grade
+		WHERE p.group_id = $1 AND p.is_professor = false AND p.is_admin = false`
+
+	args := []interface{}{groupID, subjectID}
+
+	if rankBy == rankByAttendance {
+		valuesQuery = `
+		SELECT p.id, p.firstname, p.lastname, NULL::float8
+		FROM person p
+		WHERE p.group_id = $1 AND p.is_professor = false AND p.is_admin = false`
+		args = args[:1]
+	}
+
+	rows, err := db.QueryContext(ctx, valuesQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.RankingEntry
+		var value sql.NullFloat64
+
+		if err := rows.Scan(&entry.StudentID, &entry.StudentFirstname, &entry.StudentLastname, &value); err != nil {
+			return nil, nil, err
+		}
+
+		entry.Value = value.Float64
+		entries = append(entries, entry)
+		hasValue = append(hasValue, value.Valid)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if rankBy == rankByAttendance {
+		// Доля посещений считается по отметкам на занятиях с учётом правил предмета
+		summaries, err := attendanceSummaries(attendanceFilter{subjectID: subjectID, groupID: groupID})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rates := map[int]float64{}
+		for _, summary := range summaries {
+			if summary.CountedLessons > 0 {
+				rates[summary.StudentID] = summary.AttendanceRate
+			}
+		}
+
+		for i := range entries {
+			entries[i].Value, hasValue[i] = rates[entries[i].StudentID]
+		}
+	}
+
+	return entries, hasValue, nil
+}
+
+// groupRanking упорядочивает студентов группы по убыванию значения.
+// Значения сравниваются с точностью до сотых, равные значения делят место.
+func groupRanking(groupID, subjectID int, rankBy string) (model.GroupRanking, error) {
+	ranking := model.GroupRanking{
+		GroupID:   groupID,
+		SubjectID: subjectID,
+		RankBy:    rankBy,
+		Entries:   []model.RankingEntry{},
+	}
+
+	entries, hasValue, err := rankingValues(groupID, subjectID, rankBy)
+	if err != nil {
+		return ranking, err
+	}
+
+	rankEntries(&ranking, entries, hasValue)
+
+	return ranking, nil
+}
+
+// rankEntries расставляет места и процентили студентов со значением, остальные считаются в UnrankedCount
+func rankEntries(ranking *model.GroupRanking, entries []model.RankingEntry, hasValue []bool) {
+	for i, entry := range entries {
+		if !hasValue[i] {
+			ranking.UnrankedCount++
+			continue
+		}
+		entry.Value = roundHundredths(entry.Value)
+		ranking.Entries = append(ranking.Entries, entry)
+	}
+
+	sort.SliceStable(ranking.Entries, func(i, j int) bool {
+		a, b := ranking.Entries[i], ranking.Entries[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		if a.StudentLastname != b.StudentLastname {
+			return a.StudentLastname < b.StudentLastname
+		}
+		if a.StudentFirstname != b.StudentFirstname {
+			return a.StudentFirstname < b.StudentFirstname
+		}
+		return a.StudentID < b.StudentID
+	})
+
+	count := len(ranking.Entries)
+	ranking.RankedCount = count
+
+	for start := 0; start < count; {
+		end := start
+		for end < count && ranking.Entries[end].Value == ranking.Entries[start].Value {
+			end++
+		}
+
+		// Ниже группы равных стоят count - end студентов
+		percentile := roundHundredths((float64(count-end) + 0.5*float64(end-start)) / float64(count) * 100)
+
+		for i := start; i < end; i++ {
+			ranking.Entries[i].Rank = start + 1
+			ranking.Entries[i].Percentile = percentile
+			ranking.Entries[i].IsTied = end-start > 1
+		}
+
+		start = end
+	}
+}
+
+// GetGroupRanking возвращает рейтинг группы group_id по предмету subject_id.
+// rank_by = weighted (взвешенный балл, по умолчанию), total (итоговая оценка) или attendance (посещаемость).
+func GetGroupRanking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	rankBy := r.URL.Query().Get("rank_by")
+	if rankBy == "" {
+		rankBy = rankByWeighted
+	}
+
+	if !isValidRankBy(rankBy) {
+		http.Error(w, "rank_by must be weighted, total or attendance", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "Only administrators and professors of this subject can view group rankings", http.StatusUnauthorized)
+		return
+	}
+
+	ranking, err := groupRanking(groupID, subjectID, rankBy)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("groupRanking deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("groupRanking error: ", err)
+		http.Error(w, "Error while calculating ranking", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(ranking)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Group Ranking failed: %v\n", err)
+	}
+}
+
+// GetCurrentUserStanding возвращает место текущего студента в рейтинге своей группы по предмету.
+// Доступно, только если администратор открыл рейтинг предмета студентам.
+func GetCurrentUserStanding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	rankBy := r.URL.Query().Get("rank_by")
+	if rankBy == "" {
+		rankBy = rankByWeighted
+	}
+
+	if !isValidRankBy(rankBy) {
+		http.Error(w, "rank_by must be weighted, total or attendance", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	if !isStudent {
+		http.Error(w, "Only students can view their standing", http.StatusUnauthorized)
+		return
+	}
+
+	studentID, err := strconv.Atoi(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var groupID int
+	var rankingVisible bool
+
+	standingQuery := `
+		SELECT COALESCE(p.group_id, 0), s.ranking_visible
+		FROM person p, subject s
+		WHERE p.id = $1 AND s.id = $2`
+
+	err = db.QueryRowContext(ctx, standingQuery, studentID, subjectID).Scan(&groupID, &rankingVisible)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Subject not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetCurrentUserStanding QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !rankingVisible {
+		http.Error(w, "Ranking of this subject is not available to students", http.StatusUnauthorized)
+		return
+	}
+
+	hasSubject, err := studentHasSubject(studentID, subjectID)
+	if err != nil {
+		log.Println("studentHasSubject error: ", err)
+		http.Error(w, "Error while checking student subjects", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject || groupID == 0 {
+		http.Error(w, "You do not study this subject with your group", http.StatusBadRequest)
+		return
+	}
+
+	ranking, err := groupRanking(groupID, subjectID, rankBy)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Println("groupRanking deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		log.Println("groupRanking error: ", err)
+		http.Error(w, "Error while calculating ranking", http.StatusInternalServerError)
+		return
+	}
+
+	// Студент видит только своё место и размер рейтинга
+	standing := model.StudentStanding{
+		SubjectID:   subjectID,
+		RankBy:      rankBy,
+		RankedCount: ranking.RankedCount,
+	}
+
+	for _, entry := range ranking.Entries {
+		if entry.StudentID == studentID {
+			standing.Ranked = true
+			standing.Rank = entry.Rank
+			standing.Value = entry.Value
+			standing.Percentile = entry.Percentile
+			standing.IsTied = entry.IsTied
+			break
+		}
+	}
+
+	resp, err := json.Marshal(standing)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Current User Standing failed: %v\n", err)
+	}
+}
+
+// SetRankingVisibility открывает или закрывает студентам их место в рейтинге по предмету
+func SetRankingVisibility(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to change ranking visibility", http.StatusUnauthorized)
+		return
+	}
+
+	var visibility model.RankingVisibility
+
+	err = json.NewDecoder(r.Body).Decode(&visibility)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	setRankingVisibilityQuery := `UPDATE subject SET ranking_visible = $1 WHERE id = $2;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, setRankingVisibilityQuery, visibility.RankingVisible, visibility.SubjectID)
+
+	var rowsAffected int64
+	if err == nil {
+		rowsAffected, err = result.RowsAffected()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("SetRankingVisibility QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Subject not found", http.StatusNotFound)
+		return
+	}
+
+	resp, err := json.Marshal("Set ranking visibility successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Set ranking visibility failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
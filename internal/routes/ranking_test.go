@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+)
+
+func TestRankEntries(t *testing.T) {
+	type ranked struct {
+		studentID  int
+		rank       int
+		value      float64
+		percentile float64
+		tied       bool
+	}
+
+	tests := []struct {
+		name     string
+		entries  []model.RankingEntry
+		hasValue []bool
+		want     []ranked
+		unranked int
+	}{
+		{
+			name: "ties share the rank and skip the next one",
+			entries: []model.RankingEntry{
+				{StudentID: 1, StudentLastname: "Sidorov", Value: 80},
+				{StudentID: 2, StudentLastname: "Abramov", Value: 90},
+				{StudentID: 3, StudentLastname: "Petrov", Value: 70},
+				{StudentID: 4, StudentLastname: "Ivanov", Value: 80},
+				{StudentID: 5, StudentLastname: "Kuznetsov"},
+			},
+			hasValue: []bool{true, true, true, true, false},
+			want: []ranked{
+				{2, 1, 90, 87.5, false},
+				{4, 2, 80, 50, true},
+				{1, 2, 80, 50, true},
+				{3, 4, 70, 12.5, false},
+			},
+			unranked: 1,
+		},
+		{
+			name: "values equal to hundredths are tied",
+			entries: []model.RankingEntry{
+				{StudentID: 1, StudentLastname: "Petrov", Value: 85.004},
+				{StudentID: 2, StudentLastname: "Ivanov", Value: 84.996},
+				{StudentID: 3, StudentLastname: "Orlov", Value: 60.5},
+			},
+			hasValue: []bool{true, true, true},
+			want: []ranked{
+				{2, 1, 85, 66.67, true},
+				{1, 1, 85, 66.67, true},
+				{3, 3, 60.5, 16.67, false},
+			},
+		},
+		{
+			name:     "single student",
+			entries:  []model.RankingEntry{{StudentID: 1, Value: 4.5}},
+			hasValue: []bool{true},
+			want:     []ranked{{1, 1, 4.5, 50, false}},
+		},
+		{
+			name:     "nobody graded",
+			entries:  []model.RankingEntry{{StudentID: 1}, {StudentID: 2}},
+			hasValue: []bool{false, false},
+			unranked: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranking := model.GroupRanking{Entries: []model.RankingEntry{}}
+			rankEntries(&ranking, test.entries, test.hasValue)
+
+			if ranking.RankedCount != len(test.want) || ranking.UnrankedCount != test.unranked {
+				t.Errorf("ranked %d, unranked %d, want %d, %d",
+					ranking.RankedCount, ranking.UnrankedCount, len(test.want), test.unranked)
+			}
+			if len(ranking.Entries) != len(test.want) {
+				t.Fatalf("got %d entries, want %d", len(ranking.Entries), len(test.want))
+			}
+
+			for i, want := range test.want {
+				entry := ranking.Entries[i]
+				got := ranked{entry.StudentID, entry.Rank, entry.Value, entry.Percentile, entry.IsTied}
+				if got != want {
+					t.Errorf("entry %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
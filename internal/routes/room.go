@@ -19,7 +19,7 @@ func ListRooms(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listRoomsQuery := `SELECT id, subject_id, room_name FROM room;`
+	listRoomsQuery := `SELECT id, subject_id, COALESCE(subgroup_id, 0), room_name FROM room;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
@@ -49,7 +49,7 @@ func ListRooms(w http.ResponseWriter, r *http.Request) {
 	var rooms []model.Room
 
 	for rows.Next() {
-		if err := rows.Scan(&room.ID, &room.SubjectID, &room.RoomName); err != nil {
+		if err := rows.Scan(&room.ID, &room.SubjectID, &room.SubgroupID, &room.RoomName); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -92,7 +92,7 @@ func ListRoomsOfASubject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	listRoomsOfASubjectQuery := `
-		SELECT r.id, r.subject_id, s.subject_name, r.room_name 
+		SELECT r.id, r.subject_id, s.subject_name, COALESCE(r.subgroup_id, 0), r.room_name 
 		FROM room r
 		LEFT JOIN subject s ON r.subject_id = s.id
 		WHERE subject_id = $1
@@ -126,7 +126,7 @@ func ListRoomsOfASubject(w http.ResponseWriter, r *http.Request) {
 	var rooms []model.Room
 
 	for rows.Next() {
-		if err := rows.Scan(&room.ID, &room.SubjectID, &room.SubjectName, &room.RoomName); err != nil {
+		if err := rows.Scan(&room.ID, &room.SubjectID, &room.SubjectName, &room.SubgroupID, &room.RoomName); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -219,12 +219,27 @@ func AddRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	insertRoomQuery := `INSERT INTO room (subject_id, room_name) VALUES ($1, $2::text);`
+	if room.SubgroupID != 0 {
+		hasSubject, err := subgroupHasSubject(room.SubgroupID, room.SubjectID)
+		if err != nil {
+			log.Println("subgroupHasSubject error: ", err)
+			http.Error(w, "Error while checking subgroup", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasSubject {
+			http.Error(w, "Subgroup must belong to a group that has the subject of the room", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// subgroup_id = 0 - комната общая для всех групп предмета
+	insertRoomQuery := `INSERT INTO room (subject_id, room_name, subgroup_id) VALUES ($1, $2::text, NULLIF($3, 0));`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, insertRoomQuery, room.SubjectID, room.RoomName)
+	_, err = db.ExecContext(ctx, insertRoomQuery, room.SubjectID, room.RoomName, room.SubgroupID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -325,12 +340,28 @@ func UpdateRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateRoomQuery := `UPDATE room SET room_name = $1::text WHERE id = $2 AND subject_id = $3;`
+	if room.SubgroupID != 0 {
+		hasSubject, err := subgroupHasSubject(room.SubgroupID, room.SubjectID)
+		if err != nil {
+			log.Println("subgroupHasSubject error: ", err)
+			http.Error(w, "Error while checking subgroup", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasSubject {
+			http.Error(w, "Subgroup must belong to a group that has the subject of the room", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updateRoomQuery := `
+		UPDATE room SET room_name = $1::text, subgroup_id = NULLIF($2, 0) 
+		WHERE id = $3 AND subject_id = $4;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, updateRoomQuery, room.RoomName, room.ID, room.SubjectID)
+	_, err = db.ExecContext(ctx, updateRoomQuery, room.RoomName, room.SubgroupID, room.ID, room.SubjectID)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
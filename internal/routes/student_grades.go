@@ -11,8 +11,64 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
+// Датой оценки считается дата занятия, а если она не указана - дата выставления
+const gradeDateExpression = `COALESCE(sg.lesson_date, sg.graded_at::date)`
+
+// gradeDateFilter - необязательный диапазон дат from и to (YYYY-MM-DD) и порядок сортировки по дате
+type gradeDateFilter struct {
+	from  *time.Time
+	to    *time.Time
+	order string
+}
+
+// parseGradeDateFilter разбирает параметры from, to и sort (asc или desc, по умолчанию asc).
+// Вторым значением возвращается описание ошибки в параметрах.
+func parseGradeDateFilter(r *http.Request) (gradeDateFilter, string) {
+	filter := gradeDateFilter{order: "ASC"}
+
+	if paramFrom := r.URL.Query().Get("from"); paramFrom != "" {
+		from, err := time.Parse(time.DateOnly, paramFrom)
+		if err != nil {
+			return filter, "from must be a date in YYYY-MM-DD format"
+		}
+		filter.from = &from
+	}
+
+	if paramTo := r.URL.Query().Get("to"); paramTo != "" {
+		to, err := time.Parse(time.DateOnly, paramTo)
+		if err != nil {
+			return filter, "to must be a date in YYYY-MM-DD format"
+		}
+		filter.to = &to
+	}
+
+	switch r.URL.Query().Get("sort") {
+	case "", "asc":
+	case "desc":
+		filter.order = "DESC"
+	default:
+		return filter, "sort must be asc or desc"
+	}
+
+	return filter, ""
+}
+
+// gradeContextProblem проверяет описание и комментарий оценки
+func gradeContextProblem(studentGrade model.StudentGrade) string {
+	if utf8.RuneCountInString(studentGrade.Description) > 255 {
+		return "Maximum grade description length is 255 characters"
+	}
+
+	if utf8.RuneCountInString(studentGrade.ProfessorComment) > 5000 {
+		return "Maximum professor comment length is 5000 characters"
+	}
+
+	return ""
+}
+
 func ListCurrentUserGradesAndAttendanceBySubject(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
@@ -65,16 +121,29 @@ func ListCurrentUserGradesAndAttendanceBySubject(w http.ResponseWriter, r *http.
 		return
 	}
 
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
 	listCurrentUserGradesAndAttendanceQuery := `
-	SELECT sg.subject_id, s.subject_name, sg.grade, sg.has_attended
+	SELECT sg.id, sg.subject_id, s.subject_name, COALESCE(sg.category_id, 0), COALESCE(gc.category_name, ''), 
+	       sg.grade, sg.graded_at, sg.lesson_date,
+	       COALESCE(sg.description, ''), COALESCE(sg.professor_comment, ''), COALESCE(sg.lesson_id, 0),
+	       COALESCE(la.status, '')
 	FROM student_grades sg
 	JOIN subject s ON sg.subject_id = s.id  
-	WHERE student_id = $1 AND sg.subject_id = $2`
+	LEFT JOIN grade_category gc ON sg.category_id = gc.id
+	LEFT JOIN lesson_attendance la ON la.lesson_id = sg.lesson_id AND la.student_id = sg.student_id
+	WHERE student_id = $1 AND sg.subject_id = $2
+	  AND ($3::date IS NULL OR ` + gradeDateExpression + ` >= $3) AND ($4::date IS NULL OR ` + gradeDateExpression + ` <= $4)
+	ORDER BY ` + gradeDateExpression + ` ` + dateFilter.order + `, sg.graded_at ` + dateFilter.order + `, sg.id ` + dateFilter.order
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listCurrentUserGradesAndAttendanceQuery, claims.Issuer, subjectID)
+	rows, err := db.QueryContext(ctx, listCurrentUserGradesAndAttendanceQuery, claims.Issuer, subjectID, dateFilter.from, dateFilter.to)
 	defer rows.Close()
 
 	if err != nil {
@@ -101,10 +170,18 @@ func ListCurrentUserGradesAndAttendanceBySubject(w http.ResponseWriter, r *http.
 
 	for rows.Next() {
 		if err := rows.Scan(
+			&studentGrade.ID,
 			&studentGrade.SubjectID,
 			&studentGrade.SubjectName,
+			&studentGrade.CategoryID,
+			&studentGrade.CategoryName,
 			&studentGrade.Grade,
-			&studentGrade.HasAttended); err != nil {
+			&studentGrade.GradedAt,
+			&studentGrade.LessonDate,
+			&studentGrade.Description,
+			&studentGrade.ProfessorComment,
+			&studentGrade.LessonID,
+			&studentGrade.AttendanceStatus); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -153,20 +230,32 @@ func ListGradesAndAttendanceOfAStudentBySubject(w http.ResponseWriter, r *http.R
 		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
 	}
 
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
 	listGradesAndAttendanceOfAStudentQuery := `
-	SELECT DISTINCT (sg.id), sg.student_id, p.firstname, p.lastname, p.group_id, 
-	       g.group_name, sg.subject_id, s.subject_name, sg.grade, sg.has_attended
+	SELECT sg.id, sg.student_id, p.firstname, p.lastname, p.group_id, 
+	       g.group_name, sg.subject_id, s.subject_name, COALESCE(sg.category_id, 0), COALESCE(gc.category_name, ''), 
+	       sg.grade, sg.graded_at, sg.lesson_date,
+	       COALESCE(sg.description, ''), COALESCE(sg.professor_comment, ''), COALESCE(sg.lesson_id, 0),
+	       COALESCE(la.status, '')
 	FROM student_grades sg
 	JOIN subject s ON sg.subject_id = s.id
 	JOIN person p ON sg.student_id = p.id
-	JOIN group_subject gs ON p.group_id = gs.group_id
-	JOIN group_uni g ON gs.group_id = g.id
-	WHERE student_id = $1 AND sg.subject_id = $2`
+	JOIN group_uni g ON p.group_id = g.id
+	LEFT JOIN grade_category gc ON sg.category_id = gc.id
+	LEFT JOIN lesson_attendance la ON la.lesson_id = sg.lesson_id AND la.student_id = sg.student_id
+	WHERE student_id = $1 AND sg.subject_id = $2
+	  AND ($3::date IS NULL OR ` + gradeDateExpression + ` >= $3) AND ($4::date IS NULL OR ` + gradeDateExpression + ` <= $4)
+	ORDER BY ` + gradeDateExpression + ` ` + dateFilter.order + `, sg.graded_at ` + dateFilter.order + `, sg.id ` + dateFilter.order
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listGradesAndAttendanceOfAStudentQuery, studentID, subjectID)
+	rows, err := db.QueryContext(ctx, listGradesAndAttendanceOfAStudentQuery, studentID, subjectID, dateFilter.from, dateFilter.to)
 	defer rows.Close()
 
 	if err != nil {
@@ -201,8 +290,15 @@ func ListGradesAndAttendanceOfAStudentBySubject(w http.ResponseWriter, r *http.R
 			&studentGrade.StudentGroupName,
 			&studentGrade.SubjectID,
 			&studentGrade.SubjectName,
+			&studentGrade.CategoryID,
+			&studentGrade.CategoryName,
 			&studentGrade.Grade,
-			&studentGrade.HasAttended); err != nil {
+			&studentGrade.GradedAt,
+			&studentGrade.LessonDate,
+			&studentGrade.Description,
+			&studentGrade.ProfessorComment,
+			&studentGrade.LessonID,
+			&studentGrade.AttendanceStatus); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -244,20 +340,45 @@ func ListGradesAndAttendanceOfAGroup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
 	}
 
+	// subgroup_id необязателен и сужает выборку до одной подгруппы
+	subgroupID := 0
+	paramSubgroupID := r.URL.Query().Get("subgroup_id")
+	if paramSubgroupID != "" {
+		subgroupID, err = strconv.Atoi(paramSubgroupID)
+		if err != nil {
+			http.Error(w, "subgroup_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	dateFilter, dateFilterProblem := parseGradeDateFilter(r)
+	if dateFilterProblem != "" {
+		http.Error(w, dateFilterProblem, http.StatusBadRequest)
+		return
+	}
+
 	listGradesAndAttendanceOfAStudentQuery := `
-	SELECT sg.student_id, p.firstname, p.lastname, p.group_id, 
-	       g.group_name, sg.subject_id, s.subject_name, sg.grade, sg.has_attended
+	SELECT sg.id, sg.student_id, p.firstname, p.lastname, p.group_id, 
+	       g.group_name, COALESCE(p.subgroup_id, 0), COALESCE(sub.subgroup_name, ''),
+	       sg.subject_id, s.subject_name, COALESCE(sg.category_id, 0), COALESCE(gc.category_name, ''), 
+	       sg.grade, sg.graded_at, sg.lesson_date,
+	       COALESCE(sg.description, ''), COALESCE(sg.professor_comment, ''), COALESCE(sg.lesson_id, 0),
+	       COALESCE(la.status, '')
 	FROM student_grades sg
 	JOIN subject s ON sg.subject_id = s.id
 	JOIN person p ON sg.student_id = p.id
-	JOIN group_subject gs ON p.group_id = gs.group_id
-	JOIN group_uni g ON gs.group_id = g.id
-	WHERE p.group_id = $1`
+	JOIN group_uni g ON p.group_id = g.id
+	LEFT JOIN subgroup sub ON p.subgroup_id = sub.id
+	LEFT JOIN grade_category gc ON sg.category_id = gc.id
+	LEFT JOIN lesson_attendance la ON la.lesson_id = sg.lesson_id AND la.student_id = sg.student_id
+	WHERE p.group_id = $1 AND ($2 = 0 OR p.subgroup_id = $2)
+	  AND ($3::date IS NULL OR ` + gradeDateExpression + ` >= $3) AND ($4::date IS NULL OR ` + gradeDateExpression + ` <= $4)
+	ORDER BY ` + gradeDateExpression + ` ` + dateFilter.order + `, p.lastname, p.firstname, sg.id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listGradesAndAttendanceOfAStudentQuery, groupID)
+	rows, err := db.QueryContext(ctx, listGradesAndAttendanceOfAStudentQuery, groupID, subgroupID, dateFilter.from, dateFilter.to)
 	defer rows.Close()
 
 	if err != nil {
@@ -284,15 +405,25 @@ func ListGradesAndAttendanceOfAGroup(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		if err := rows.Scan(
+			&studentGrade.ID,
 			&studentGrade.StudentID,
 			&studentGrade.StudentFirstname,
 			&studentGrade.StudentLastname,
 			&studentGrade.StudentGroupID,
 			&studentGrade.StudentGroupName,
+			&studentGrade.StudentSubgroupID,
+			&studentGrade.StudentSubgroupName,
 			&studentGrade.SubjectID,
 			&studentGrade.SubjectName,
+			&studentGrade.CategoryID,
+			&studentGrade.CategoryName,
 			&studentGrade.Grade,
-			&studentGrade.HasAttended); err != nil {
+			&studentGrade.GradedAt,
+			&studentGrade.LessonDate,
+			&studentGrade.Description,
+			&studentGrade.ProfessorComment,
+			&studentGrade.LessonID,
+			&studentGrade.AttendanceStatus); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -393,6 +524,15 @@ func InsertGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentGrade.StudentID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only set grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -409,27 +549,76 @@ func InsertGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if studentGrade.Grade != 0 && studentGrade.HasAttended == false {
-		studentGrade.HasAttended = true
+	gradeProblem, err := lessonGradeProblem(studentGrade.SubjectID, studentGrade.Grade)
+	if err != nil {
+		log.Println("lessonGradeProblem error: ", err)
+		http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	if gradeProblem != "" {
+		http.Error(w, gradeProblem, http.StatusBadRequest)
+		return
 	}
 
-	insertGradeAndAttendanceOfAStudentQuery := `
-		INSERT INTO student_grades 
-		    (student_id, subject_id, grade, has_attended) 
-		VALUES ($1, $2, $3, $4);`
+	if contextProblem := gradeContextProblem(studentGrade); contextProblem != "" {
+		http.Error(w, contextProblem, http.StatusBadRequest)
+		return
+	}
+
+	if studentGrade.CategoryID != 0 {
+		belongs, err := categoryBelongsToSubject(studentGrade.CategoryID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("categoryBelongsToSubject error: ", err)
+			http.Error(w, "Error while checking grade category", http.StatusInternalServerError)
+			return
+		}
+
+		if !belongs {
+			http.Error(w, "Grade category does not belong to this subject", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if studentGrade.LessonID != 0 {
+		hasStudent, err := lessonHasStudent(studentGrade.LessonID, studentGrade.StudentID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("lessonHasStudent error: ", err)
+			http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasStudent {
+			http.Error(w, "Lesson does not exist or is not a lesson of this student and subject", http.StatusBadRequest)
+			return
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(
-		ctx,
-		insertGradeAndAttendanceOfAStudentQuery,
-		studentGrade.StudentID,
-		studentGrade.SubjectID,
-		studentGrade.Grade,
-		studentGrade.HasAttended)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("InsertGradeAndAttendanceOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = insertLessonGrade(ctx, tx, studentGrade, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("InsertGradeAndAttendanceOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
@@ -536,6 +725,15 @@ func UpdateGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentGrade.StudentID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only update grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -552,28 +750,80 @@ func UpdateGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if studentGrade.Grade != 0 && studentGrade.HasAttended == false {
-		studentGrade.HasAttended = true
+	gradeProblem, err := lessonGradeProblem(studentGrade.SubjectID, studentGrade.Grade)
+	if err != nil {
+		log.Println("lessonGradeProblem error: ", err)
+		http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+		return
 	}
 
-	updateGradeAndAttendanceOfAStudentQuery := `
-		UPDATE student_grades 
-		SET student_id = $1, subject_id = $2, grade = $3, has_attended = $4 
-		WHERE id = $5;`
+	if gradeProblem != "" {
+		http.Error(w, gradeProblem, http.StatusBadRequest)
+		return
+	}
+
+	if contextProblem := gradeContextProblem(studentGrade); contextProblem != "" {
+		http.Error(w, contextProblem, http.StatusBadRequest)
+		return
+	}
+
+	if studentGrade.CategoryID != 0 {
+		belongs, err := categoryBelongsToSubject(studentGrade.CategoryID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("categoryBelongsToSubject error: ", err)
+			http.Error(w, "Error while checking grade category", http.StatusInternalServerError)
+			return
+		}
+
+		if !belongs {
+			http.Error(w, "Grade category does not belong to this subject", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if studentGrade.LessonID != 0 {
+		hasStudent, err := lessonHasStudent(studentGrade.LessonID, studentGrade.StudentID, studentGrade.SubjectID)
+		if err != nil {
+			log.Println("lessonHasStudent error: ", err)
+			http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasStudent {
+			http.Error(w, "Lesson does not exist or is not a lesson of this student and subject", http.StatusBadRequest)
+			return
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(
-		ctx,
-		updateGradeAndAttendanceOfAStudentQuery,
-		studentGrade.StudentID,
-		studentGrade.SubjectID,
-		studentGrade.Grade,
-		studentGrade.HasAttended,
-		studentGrade.ID)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("UpdateGradeAndAttendanceOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = updateLessonGrade(ctx, tx, studentGrade, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeNotFound) {
+			http.Error(w, "Grade not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("UpdateGradeAndAttendanceOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
@@ -689,6 +939,15 @@ func DeleteGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentID, subjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only delete grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -706,14 +965,38 @@ func DeleteGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	deleteGradeAndAttendanceOfAStudentQuery := `DELETE FROM student_grades WHERE id = $1 AND student_id = $2 AND subject_id = $3`
+	// Причина удаления обязательна после окончания семестра
+	reason := r.URL.Query().Get("reason")
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, deleteGradeAndAttendanceOfAStudentQuery, id, studentID, subjectID)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("DeleteGradeAndAttendanceOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = deleteLessonGrade(ctx, tx, id, studentID, subjectID, reason, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeNotFound) {
+			http.Error(w, "Grade not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("DeleteGradeAndAttendanceOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
@@ -747,3 +1030,186 @@ func DeleteGradeAndAttendanceOfAStudent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// ListGradesAndAttendanceOfAGroupBySubgroup возвращает средние оценки и посещаемость группы,
+// сгруппированные по подгруппам и предметам. Записи без оценки в среднем не учитываются.
+// Посещаемость считается по отметкам занятий с учётом правил предмета.
+// Доступно администраторам, преподавателям предмета, а без subject_id - преподавателям всей группы.
+func ListGradesAndAttendanceOfAGroupBySubgroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	// subject_id необязателен, без него возвращаются все предметы группы
+	subjectID := 0
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	if paramSubjectID != "" {
+		subjectID, err = strconv.Atoi(paramSubjectID)
+		if err != nil {
+			http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	// Без subject_id сводка охватывает все предметы группы, поэтому нужна связь с группой
+	canView := false
+	if subjectID != 0 {
+		canView, err = canManageSubject(claims.Issuer, subjectID)
+	} else {
+		canView, err = isAdmin(claims.Issuer)
+		if err == nil && !canView {
+			canView, err = professorTeachesGroup(claims.Issuer, groupID, 0)
+		}
+	}
+	if err != nil {
+		log.Println("ListGradesAndAttendanceOfAGroupBySubgroup privileges error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canView {
+		http.Error(w, "Only administrators and professors of this group or subject can view subgroup summaries", http.StatusUnauthorized)
+		return
+	}
+
+	listGradesAndAttendanceBySubgroupQuery := `
+	SELECT COALESCE(p.subgroup_id, 0), COALESCE(sub.subgroup_name, ''), sg.subject_id, s.subject_name,
+	       COUNT(DISTINCT sg.student_id), COUNT(sg.id),
+	       COALESCE(AVG(sg.grade), 0)
+	FROM student_grades sg
+	JOIN subject s ON sg.subject_id = s.id
+	JOIN person p ON sg.student_id = p.id
+	LEFT JOIN subgroup sub ON p.subgroup_id = sub.id
+	WHERE p.group_id = $1 AND ($2 = 0 OR sg.subject_id = $2)
+	GROUP BY p.subgroup_id, sub.subgroup_name, sg.subject_id, s.subject_name
+	ORDER BY sg.subject_id, sub.subgroup_name NULLS FIRST`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listGradesAndAttendanceBySubgroupQuery, groupID, subjectID)
+	defer rows.Close()
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListGradesAndAttendanceOfAGroupBySubgroup QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var summary model.SubgroupGradeSummary
+	var summaries []model.SubgroupGradeSummary
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&summary.SubgroupID,
+			&summary.SubgroupName,
+			&summary.SubjectID,
+			&summary.SubjectName,
+			&summary.StudentCount,
+			&summary.GradeCount,
+			&summary.AverageGrade); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	attendance, err := attendanceSummaries(attendanceFilter{subjectID: subjectID, groupID: groupID})
+	if err != nil {
+		log.Println("attendanceSummaries error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type subgroupSubject struct {
+		subgroupID int
+		subjectID  int
+	}
+
+	attended := map[subgroupSubject]int{}
+	counted := map[subgroupSubject]int{}
+
+	for _, studentAttendance := range attendance {
+		key := subgroupSubject{studentAttendance.SubgroupID, studentAttendance.SubjectID}
+		attended[key] += studentAttendance.AttendedLessons
+		counted[key] += studentAttendance.CountedLessons
+	}
+
+	for i := range summaries {
+		key := subgroupSubject{summaries[i].SubgroupID, summaries[i].SubjectID}
+		if counted[key] > 0 {
+			summaries[i].AttendanceRate = roundHundredths(float64(attended[key]) / float64(counted[key]))
+		}
+	}
+
+	resp, err := json.Marshal(summaries)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Grades And Attendance Of A Group By Subgroup failed: %v\n", err)
+	}
+}
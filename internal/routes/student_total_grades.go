@@ -14,6 +14,19 @@ import (
 	"unicode/utf8"
 )
 
+// latestTotalGradeJoin присоединяет как stg действующую итоговую оценку студента по предмету - оценку
+// последнего семестра, чтобы пересдача заменяла прежнюю попытку. Оценки без семестра считаются самыми ранними.
+func latestTotalGradeJoin(studentColumn, subjectColumn string) string {
+	return `
+	LEFT JOIN LATERAL (
+	    SELECT latest.* FROM student_total_grades latest
+	    LEFT JOIN term latest_term ON latest.term_id = latest_term.id
+	    WHERE latest.student_id = ` + studentColumn + ` AND latest.subject_id = ` + subjectColumn + `
+	    ORDER BY latest_term.start_date DESC NULLS LAST, latest.id DESC
+	    LIMIT 1
+	) stg ON true`
+}
+
 func ListCurrentUserTotalGradesBySubject(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
@@ -67,7 +80,7 @@ func ListCurrentUserTotalGradesBySubject(w http.ResponseWriter, r *http.Request)
 	}
 
 	listCurrentUserTotalGradesQuery := `
-	SELECT DISTINCT stg.subject_id, s.subject_name, stg.grade
+	SELECT DISTINCT stg.subject_id, s.subject_name, stg.grade, COALESCE(stg.term_id, 0)
 	FROM student_total_grades stg
 	JOIN subject s ON stg.subject_id = s.id  
 	WHERE student_id = $1 AND stg.subject_id = $2`
@@ -104,7 +117,8 @@ func ListCurrentUserTotalGradesBySubject(w http.ResponseWriter, r *http.Request)
 		if err := rows.Scan(
 			&studentTotalGrade.SubjectID,
 			&studentTotalGrade.SubjectName,
-			&studentTotalGrade.Grade); err != nil {
+			&studentTotalGrade.Grade,
+			&studentTotalGrade.TermID); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -155,7 +169,7 @@ func ListTotalGradesOfAStudentBySubject(w http.ResponseWriter, r *http.Request)
 
 	listTotalGradesOfAStudentQuery := `
 	SELECT DISTINCT (stg.id), stg.student_id, p.firstname, p.lastname, p.group_id, 
-	       g.group_name, stg.subject_id, s.subject_name, stg.grade
+	       g.group_name, stg.subject_id, s.subject_name, stg.grade, COALESCE(stg.term_id, 0)
 	FROM student_total_grades stg
 	JOIN subject s ON stg.subject_id = s.id
 	JOIN person p ON stg.student_id = p.id
@@ -201,7 +215,8 @@ func ListTotalGradesOfAStudentBySubject(w http.ResponseWriter, r *http.Request)
 			&studentTotalGrade.StudentGroupName,
 			&studentTotalGrade.SubjectID,
 			&studentTotalGrade.SubjectName,
-			&studentTotalGrade.Grade); err != nil {
+			&studentTotalGrade.Grade,
+			&studentTotalGrade.TermID); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -243,20 +258,33 @@ func ListTotalGradesOfAGroup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
 	}
 
+	// subgroup_id необязателен и сужает выборку до одной подгруппы
+	subgroupID := 0
+	paramSubgroupID := r.URL.Query().Get("subgroup_id")
+	if paramSubgroupID != "" {
+		subgroupID, err = strconv.Atoi(paramSubgroupID)
+		if err != nil {
+			http.Error(w, "subgroup_id must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
 	listTotalGradesOfAStudentQuery := `
 	SELECT DISTINCT stg.student_id, p.firstname, p.lastname, p.group_id, 
-	       g.group_name, stg.subject_id, s.subject_name, stg.grade
+	       g.group_name, COALESCE(p.subgroup_id, 0), COALESCE(sub.subgroup_name, ''),
+	       stg.subject_id, s.subject_name, stg.grade, COALESCE(stg.term_id, 0)
 	FROM student_total_grades stg
 	JOIN subject s ON stg.subject_id = s.id
 	JOIN person p ON stg.student_id = p.id
 	JOIN group_subject gs ON p.group_id = gs.group_id
 	JOIN group_uni g ON gs.group_id = g.id
-	WHERE p.group_id = $1`
+	LEFT JOIN subgroup sub ON p.subgroup_id = sub.id
+	WHERE p.group_id = $1 AND ($2 = 0 OR p.subgroup_id = $2)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(ctx, listTotalGradesOfAStudentQuery, groupID)
+	rows, err := db.QueryContext(ctx, listTotalGradesOfAStudentQuery, groupID, subgroupID)
 	defer rows.Close()
 
 	if err != nil {
@@ -288,9 +316,12 @@ func ListTotalGradesOfAGroup(w http.ResponseWriter, r *http.Request) {
 			&studentTotalGrade.StudentLastname,
 			&studentTotalGrade.StudentGroupID,
 			&studentTotalGrade.StudentGroupName,
+			&studentTotalGrade.StudentSubgroupID,
+			&studentTotalGrade.StudentSubgroupName,
 			&studentTotalGrade.SubjectID,
 			&studentTotalGrade.SubjectName,
-			&studentTotalGrade.Grade); err != nil {
+			&studentTotalGrade.Grade,
+			&studentTotalGrade.TermID); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -391,6 +422,15 @@ func InsertTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentTotalGrade.StudentID, studentTotalGrade.SubjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only set total grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -412,22 +452,43 @@ func InsertTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	insertTotalGradeOfAStudentQuery := `
-		INSERT INTO student_total_grades 
-		    (student_id, subject_id, grade) 
-		VALUES ($1, $2, $3);`
+	gradeProblem, err := totalGradeProblem(studentTotalGrade.SubjectID, studentTotalGrade.Grade)
+	if err != nil {
+		log.Println("totalGradeProblem error: ", err)
+		http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	if gradeProblem != "" {
+		http.Error(w, gradeProblem, http.StatusBadRequest)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(
-		ctx,
-		insertTotalGradeOfAStudentQuery,
-		studentTotalGrade.StudentID,
-		studentTotalGrade.SubjectID,
-		studentTotalGrade.Grade)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("InsertTotalGradeOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = insertTotalGrade(ctx, tx, studentTotalGrade, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("InsertTotalGradeOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
@@ -540,6 +601,15 @@ func UpdateTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentTotalGrade.StudentID, studentTotalGrade.SubjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only update total grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -561,23 +631,47 @@ func UpdateTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateTotalGradeOfAStudentQuery := `
-		UPDATE student_total_grades 
-		SET student_id = $1, subject_id = $2, grade = $3 
-		WHERE id = $4;`
+	gradeProblem, err := totalGradeProblem(studentTotalGrade.SubjectID, studentTotalGrade.Grade)
+	if err != nil {
+		log.Println("totalGradeProblem error: ", err)
+		http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	if gradeProblem != "" {
+		http.Error(w, gradeProblem, http.StatusBadRequest)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(
-		ctx,
-		updateTotalGradeOfAStudentQuery,
-		studentTotalGrade.StudentID,
-		studentTotalGrade.SubjectID,
-		studentTotalGrade.Grade,
-		studentTotalGrade.ID)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("UpdateTotalGradeOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = updateTotalGrade(ctx, tx, studentTotalGrade, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeNotFound) {
+			http.Error(w, "Grade not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("UpdateTotalGradeOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
@@ -699,6 +793,15 @@ func DeleteTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentID, subjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !hasGroup {
 		http.Error(w, "You can only delete total grades for students from groups that you teach", http.StatusUnauthorized)
 		return
@@ -716,14 +819,38 @@ func DeleteTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteTotalGradeOfAStudentQuery := `DELETE FROM student_total_grades WHERE id = $1 AND student_id = $2 AND subject_id = $3`
+	// Причина удаления обязательна после окончания семестра
+	reason := r.URL.Query().Get("reason")
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, deleteTotalGradeOfAStudentQuery, id, studentID, subjectID)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("DeleteTotalGradeOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = deleteTotalGrade(ctx, tx, id, studentID, subjectID, reason, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
+		if errors.Is(err, errGradeNotFound) {
+			http.Error(w, "Grade not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Println("DeleteTotalGradeOfAStudent QueryRowContext deadline exceeded: ", err)
 			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
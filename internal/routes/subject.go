@@ -77,7 +77,12 @@ func ListCurrentUserSubjects(w http.ResponseWriter, r *http.Request) {
 			JOIN person p ON gs.group_id = p.group_id
 			JOIN subject s ON gs.subject_id = s.id
 			WHERE p.id = $1
-			ORDER BY gs.subject_id`
+			UNION
+			SELECT e.subject_id, s.subject_name
+			FROM enrollment e
+			JOIN subject s ON e.subject_id = s.id
+			WHERE e.student_id = $1 AND e.status = 'enrolled'
+			ORDER BY subject_id`
 	default:
 		http.Error(w, "Admins don't have any subjects", http.StatusBadRequest)
 		return
@@ -159,7 +164,12 @@ func ListSubjectsOfAStudent(w http.ResponseWriter, r *http.Request) {
 			JOIN person p ON gs.group_id = p.group_id
 			JOIN subject s ON gs.subject_id = s.id
 			WHERE p.id = $1
-			ORDER BY gs.subject_id;`
+			UNION
+			SELECT e.subject_id, s.subject_name
+			FROM enrollment e
+			JOIN subject s ON e.subject_id = s.id
+			WHERE e.student_id = $1 AND e.status = 'enrolled'
+			ORDER BY subject_id;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
@@ -224,7 +234,11 @@ func ListSubjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listSubjectsQuery := `SELECT id, subject_name FROM subject;`
+	listSubjectsQuery := `
+		SELECT s.id, s.subject_name, COALESCE(s.subject_code, ''), s.credits, s.grading_scale_id, gs.scale_name, 
+		       s.grading_method, s.attendance_penalty, s.ranking_visible 
+		FROM subject s
+		JOIN grading_scale gs ON s.grading_scale_id = gs.id;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
@@ -259,7 +273,8 @@ func ListSubjects(w http.ResponseWriter, r *http.Request) {
 	var subjects []model.Subject
 
 	for rows.Next() {
-		if err := rows.Scan(&subject.ID, &subject.SubjectName); err != nil {
+		if err := rows.Scan(&subject.ID, &subject.SubjectName, &subject.SubjectCode, &subject.Credits,
+			&subject.GradingScaleID, &subject.GradingScaleName, &subject.GradingMethod, &subject.AttendancePenalty, &subject.RankingVisible); err != nil {
 			log.Println(err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -347,12 +362,37 @@ func AddSubject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	insertSubjectQuery := `INSERT INTO subject (subject_name) VALUES ($1::text);`
+	if len([]rune(subject.SubjectCode)) > 20 {
+		http.Error(w, "Maximum subject code length is 20 characters", http.StatusBadRequest)
+		return
+	}
+
+	if subject.Credits < 0 {
+		http.Error(w, "Credits cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if subject.GradingMethod != "" && !isValidGradingMethod(subject.GradingMethod) {
+		http.Error(w, "grading_method must be average, weighted or attendance_penalized", http.StatusBadRequest)
+		return
+	}
+
+	if subject.AttendancePenalty < 0 {
+		http.Error(w, "Attendance penalty cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	insertSubjectQuery := `
+		INSERT INTO subject (subject_name, subject_code, credits, grading_scale_id, grading_method, attendance_penalty) 
+		VALUES ($1::text, NULLIF($2::text, ''), $3, 
+		        COALESCE(NULLIF($4, 0), (SELECT id FROM grading_scale WHERE scale_name = '5-point')), 
+		        COALESCE(NULLIF($5::text, ''), 'average'), $6);`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, insertSubjectQuery, subject.SubjectName)
+	_, err = db.ExecContext(ctx, insertSubjectQuery, subject.SubjectName, subject.SubjectCode, subject.Credits,
+		subject.GradingScaleID, subject.GradingMethod, subject.AttendancePenalty)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -372,6 +412,11 @@ func AddSubject(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Subject already exists", http.StatusGatewayTimeout)
 			return
 		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation, grading scale does not exist: ", err)
+			http.Error(w, "Grading scale does not exist", http.StatusBadRequest)
+			return
+		}
 
 		log.Println("Database error: ", pgErr)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -394,6 +439,16 @@ func AddSubject(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// subjectUpdate - тело запроса UpdateSubject. Указатели отличают поле, которого нет в запросе, от нулевого значения.
+type subjectUpdate struct {
+	model.Subject
+	SubjectCode       *string  `json:"subject_code"`
+	Credits           *float64 `json:"credits"`
+	AttendancePenalty *float64 `json:"attendance_penalty"`
+}
+
+// UpdateSubject изменяет предмет. Необязательные поля, которых нет в запросе, сохраняют текущие значения,
+// пустой subject_code удаляет код предмета.
 func UpdateSubject(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
@@ -439,7 +494,7 @@ func UpdateSubject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var subject model.Subject
+	var subject subjectUpdate
 
 	err = json.NewDecoder(r.Body).Decode(&subject)
 	if err != nil {
@@ -453,12 +508,40 @@ func UpdateSubject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateSubjectQuery := `UPDATE subject SET subject_name = $1::text WHERE id = $2;`
+	if subject.SubjectCode != nil && len([]rune(*subject.SubjectCode)) > 20 {
+		http.Error(w, "Maximum subject code length is 20 characters", http.StatusBadRequest)
+		return
+	}
+
+	if subject.Credits != nil && *subject.Credits < 0 {
+		http.Error(w, "Credits cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if subject.GradingMethod != "" && !isValidGradingMethod(subject.GradingMethod) {
+		http.Error(w, "grading_method must be average, weighted or attendance_penalized", http.StatusBadRequest)
+		return
+	}
+
+	if subject.AttendancePenalty != nil && *subject.AttendancePenalty < 0 {
+		http.Error(w, "Attendance penalty cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	updateSubjectQuery := `
+		UPDATE subject SET subject_name = $1::text,
+		    subject_code = CASE WHEN $2::text IS NULL THEN subject_code ELSE NULLIF($2::text, '') END,
+		    credits = COALESCE($3, credits),
+		    grading_scale_id = COALESCE(NULLIF($5, 0), grading_scale_id), 
+		    grading_method = COALESCE(NULLIF($6::text, ''), grading_method),
+		    attendance_penalty = COALESCE($7, attendance_penalty)
+		WHERE id = $4;`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
 	defer cancel()
 
-	_, err = db.ExecContext(ctx, updateSubjectQuery, subject.SubjectName, subject.ID)
+	_, err = db.ExecContext(ctx, updateSubjectQuery, subject.SubjectName, subject.SubjectCode, subject.Credits, subject.ID,
+		subject.GradingScaleID, subject.GradingMethod, subject.AttendancePenalty)
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -478,6 +561,11 @@ func UpdateSubject(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Subject already exists", http.StatusGatewayTimeout)
 			return
 		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation, grading scale does not exist: ", err)
+			http.Error(w, "Grading scale does not exist", http.StatusBadRequest)
+			return
+		}
 
 		log.Println("Database error: ", pgErr)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -0,0 +1,649 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// prerequisiteReports проверяет пререквизиты предмета subjectID по итоговым оценкам из student_total_grades.
+// Проверяется один студент studentID, либо все студенты группы groupID, если studentID = 0.
+// Возвращаются отчёты только по студентам с невыполненными пререквизитами.
+// Пререквизит выполнен, если числовой эквивалент итоговой оценки по шкале пререквизита не ниже min_grade.
+// При нескольких попытках (пересдаче в другом семестре) берётся лучшая.
+func prerequisiteReports(groupID, studentID, subjectID int) ([]model.EligibilityReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	unmetPrerequisitesQuery := `
+		SELECT p.id, p.firstname, p.lastname, sp.prerequisite_id, s.subject_name, sp.min_grade,
+		       COALESCE(best.grade, '')
+		FROM person p
+		CROSS JOIN subject_prerequisite sp
+		JOIN subject s ON sp.prerequisite_id = s.id
+		LEFT JOIN LATERAL (
+		    SELECT stg.grade, gsv.numeric_value
+		    FROM student_total_grades stg
+		    LEFT JOIN grading_scale_value gsv ON gsv.scale_id = s.grading_scale_id AND gsv.value = stg.grade
+		    WHERE stg.student_id = p.id AND stg.subject_id = sp.prerequisite_id
+		    ORDER BY gsv.numeric_value DESC NULLS LAST, stg.id DESC
+		    LIMIT 1
+		) best ON true
+		WHERE sp.subject_id = $1
+		  AND (p.id = $2 OR ($2 = 0 AND p.group_id = $3 AND p.is_professor = false AND p.is_admin = false))
+		  AND NOT COALESCE(best.numeric_value >= sp.min_grade, false)
+		ORDER BY p.id, sp.prerequisite_id`
+
+	rows, err := db.QueryContext(ctx, unmetPrerequisitesQuery, subjectID, studentID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []model.EligibilityReport
+
+	for rows.Next() {
+		var report model.EligibilityReport
+		var unmet model.UnmetPrerequisite
+
+		if err := rows.Scan(
+			&report.StudentID,
+			&report.StudentFirstname,
+			&report.StudentLastname,
+			&unmet.PrerequisiteID,
+			&unmet.PrerequisiteName,
+			&unmet.MinGrade,
+			&unmet.Grade); err != nil {
+			return nil, err
+		}
+
+		if len(reports) > 0 && reports[len(reports)-1].StudentID == report.StudentID {
+			last := &reports[len(reports)-1]
+			last.UnmetPrerequisites = append(last.UnmetPrerequisites, unmet)
+			continue
+		}
+
+		report.SubjectID = subjectID
+		report.UnmetPrerequisites = []model.UnmetPrerequisite{unmet}
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func ListSubjectPrerequisites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	listSubjectPrerequisitesQuery := `
+		SELECT sp.subject_id, s.subject_name, sp.prerequisite_id, ps.subject_name, sp.min_grade
+		FROM subject_prerequisite sp
+		JOIN subject s ON sp.subject_id = s.id
+		JOIN subject ps ON sp.prerequisite_id = ps.id
+		WHERE sp.subject_id = $1
+		ORDER BY sp.prerequisite_id;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listSubjectPrerequisitesQuery, subjectID)
+	defer rows.Close()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListSubjectPrerequisites QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var prerequisite model.SubjectPrerequisite
+	var prerequisites []model.SubjectPrerequisite
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&prerequisite.SubjectID,
+			&prerequisite.SubjectName,
+			&prerequisite.PrerequisiteID,
+			&prerequisite.PrerequisiteName,
+			&prerequisite.MinGrade); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		prerequisites = append(prerequisites, prerequisite)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(prerequisites)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Subject Prerequisites failed: %v\n", err)
+	}
+}
+
+func AddSubjectPrerequisite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to add subject prerequisites", http.StatusUnauthorized)
+		return
+	}
+
+	var prerequisite model.SubjectPrerequisite
+	err = json.NewDecoder(r.Body).Decode(&prerequisite)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if prerequisite.SubjectID == prerequisite.PrerequisiteID {
+		http.Error(w, "Subject cannot be a prerequisite of itself", http.StatusBadRequest)
+		return
+	}
+
+	if prerequisite.MinGrade < 0 {
+		http.Error(w, "min_grade cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	// Новая связь не должна замыкать цепочку пререквизитов в цикл
+	var createsCycle bool
+
+	createsCycleQuery := `
+		WITH RECURSIVE required (id) AS (
+			SELECT prerequisite_id FROM subject_prerequisite WHERE subject_id = $1
+			UNION
+			SELECT sp.prerequisite_id FROM subject_prerequisite sp JOIN required r ON sp.subject_id = r.id
+		)
+		SELECT EXISTS (SELECT FROM required WHERE id = $2)`
+
+	err = db.QueryRowContext(ctx, createsCycleQuery, prerequisite.PrerequisiteID, prerequisite.SubjectID).Scan(&createsCycle)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddSubjectPrerequisite QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if createsCycle {
+		http.Error(w, "Prerequisite already depends on this subject", http.StatusBadRequest)
+		return
+	}
+
+	insertSubjectPrerequisiteQuery := `
+		INSERT INTO subject_prerequisite (subject_id, prerequisite_id, min_grade) VALUES ($1, $2, $3);`
+
+	_, err = db.ExecContext(ctx, insertSubjectPrerequisiteQuery,
+		prerequisite.SubjectID, prerequisite.PrerequisiteID, prerequisite.MinGrade)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddSubjectPrerequisite QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23505" {
+			log.Println("Unique key violation, subject prerequisite already exists: ", err)
+			http.Error(w, "Subject prerequisite already exists", http.StatusConflict)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Inserting subject prerequisite successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("AddSubjectPrerequisite failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateSubjectPrerequisite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to update subject prerequisites", http.StatusUnauthorized)
+		return
+	}
+
+	var prerequisite model.SubjectPrerequisite
+	err = json.NewDecoder(r.Body).Decode(&prerequisite)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if prerequisite.MinGrade < 0 {
+		http.Error(w, "min_grade cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	updateSubjectPrerequisiteQuery := `
+		UPDATE subject_prerequisite SET min_grade = $1 WHERE subject_id = $2 AND prerequisite_id = $3;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, updateSubjectPrerequisiteQuery,
+		prerequisite.MinGrade, prerequisite.SubjectID, prerequisite.PrerequisiteID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("UpdateSubjectPrerequisite QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Updating subject prerequisite successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("UpdateSubjectPrerequisite failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func DeleteSubjectPrerequisite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		http.Error(w, "You do not have administrator privileges to delete subject prerequisites", http.StatusUnauthorized)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramPrerequisiteID := r.URL.Query().Get("prerequisite_id")
+	prerequisiteID, err := strconv.Atoi(paramPrerequisiteID)
+	if err != nil {
+		http.Error(w, "prerequisite_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	deleteSubjectPrerequisiteQuery := `DELETE FROM subject_prerequisite WHERE subject_id = $1 AND prerequisite_id = $2;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, deleteSubjectPrerequisiteQuery, subjectID, prerequisiteID)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DeleteSubjectPrerequisite QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Deleting subject prerequisite successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("DeleteSubjectPrerequisite failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CheckStudentEligibility возвращает отчёт о пререквизитах предмета для студента.
+// Студент проверяет себя, преподаватели и администраторы передают student_id.
+func CheckStudentEligibility(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	paramStudentID := r.URL.Query().Get("student_id")
+	if isStudent {
+		paramStudentID = claims.Issuer
+	}
+
+	studentID, err := strconv.Atoi(paramStudentID)
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := prerequisiteReports(0, studentID, subjectID)
+	if err != nil {
+		log.Println("prerequisiteReports error: ", err)
+		http.Error(w, "Error while checking subject prerequisites", http.StatusInternalServerError)
+		return
+	}
+
+	report := model.EligibilityReport{
+		StudentID:          studentID,
+		SubjectID:          subjectID,
+		Eligible:           true,
+		UnmetPrerequisites: []model.UnmetPrerequisite{},
+	}
+
+	if len(reports) > 0 {
+		report = reports[0]
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Check Student Eligibility failed: %v\n", err)
+	}
+}
+
+// CheckGroupEligibility возвращает отчёты по студентам группы, не выполнившим пререквизиты предмета.
+// Пустой список означает, что предмет можно назначить группе.
+func CheckGroupEligibility(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := prerequisiteReports(groupID, 0, subjectID)
+	if err != nil {
+		log.Println("prerequisiteReports error: ", err)
+		http.Error(w, "Error while checking subject prerequisites", http.StatusInternalServerError)
+		return
+	}
+
+	if reports == nil {
+		reports = []model.EligibilityReport{}
+	}
+
+	resp, err := json.Marshal(reports)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Check Group Eligibility failed: %v\n", err)
+	}
+}
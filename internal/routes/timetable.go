@@ -0,0 +1,738 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Виды пересечений занятий в расписании
+const (
+	timetableConflictProfessor = "professor"
+	timetableConflictGroup     = "group"
+	timetableConflictRoom      = "room"
+)
+
+// Правило расписания создаёт занятия не больше чем на год вперёд
+const maxTimetableRuleDuration = 366 * 24 * time.Hour
+
+// lessonConflicts возвращает занятия, пересекающиеся по времени с lesson и занимающие того же преподавателя,
+// ту же аудиторию или ту же группу. Занятия разных подгрупп одной группы и отменённые занятия не пересекаются.
+// Занятие excludeLessonID (изменяемое) не учитывается.
+func lessonConflicts(lesson model.Lesson, excludeLessonID int) ([]model.TimetableConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	lessonConflictsQuery := `SELECT ` + lessonColumns + `
+		WHERE l.starts_at < $2 AND l.ends_at > $1 AND l.id <> $3 AND l.cancelled_at IS NULL
+		  AND ((l.group_id = $4 AND (l.subgroup_id IS NULL OR $5 = 0 OR l.subgroup_id = $5))
+		    OR l.professor_id = $6 OR l.room_id = $7)
+		ORDER BY l.starts_at, l.id`
+
+	rows, err := db.QueryContext(ctx, lessonConflictsQuery, lesson.StartsAt, lesson.EndsAt, excludeLessonID,
+		lesson.GroupID, lesson.SubgroupID, lesson.ProfessorID, lesson.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []model.TimetableConflict
+
+	for rows.Next() {
+		other, err := scanLesson(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		kind := timetableConflictGroup
+		if lesson.ProfessorID != 0 && other.ProfessorID == lesson.ProfessorID {
+			kind = timetableConflictProfessor
+		} else if lesson.RoomID != 0 && other.RoomID == lesson.RoomID {
+			kind = timetableConflictRoom
+		}
+
+		conflicts = append(conflicts, model.TimetableConflict{
+			Kind:     kind,
+			StartsAt: lesson.StartsAt,
+			EndsAt:   lesson.EndsAt,
+			Lesson:   other,
+		})
+	}
+
+	return conflicts, rows.Err()
+}
+
+// writeTimetableConflicts отвечает 409 со списком пересечений
+func writeTimetableConflicts(w http.ResponseWriter, conflicts []model.TimetableConflict) {
+	resp, err := json.Marshal(conflicts)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Write timetable conflicts failed: %v\n", err)
+	}
+}
+
+// timetableRuleLesson - первое занятие правила, по нему проверяются права и само занятие
+func timetableRuleLesson(rule model.TimetableRule) model.Lesson {
+	return model.Lesson{
+		SubjectID:   rule.SubjectID,
+		GroupID:     rule.GroupID,
+		SubgroupID:  rule.SubgroupID,
+		ProfessorID: rule.ProfessorID,
+		RoomID:      rule.RoomID,
+		StartsAt:    rule.FirstStartsAt,
+		EndsAt:      rule.FirstEndsAt,
+		Topic:       rule.Topic,
+		Kind:        lessonKindLesson,
+	}
+}
+
+// timetableRuleLessons создаёт занятия правила. Время начала сдвигается на целые недели по часам
+// часового пояса правила, поэтому переход на летнее время не сдвигает занятия.
+func timetableRuleLessons(rule model.TimetableRule, location *time.Location) []model.Lesson {
+	firstStartsAt := rule.FirstStartsAt.In(location)
+	duration := rule.FirstEndsAt.Sub(rule.FirstStartsAt)
+
+	year, month, day := rule.RepeatUntil.Date()
+	until := time.Date(year, month, day+1, 0, 0, 0, 0, location)
+
+	var lessons []model.Lesson
+
+	for i := 0; ; i++ {
+		lesson := timetableRuleLesson(rule)
+		lesson.StartsAt = firstStartsAt.AddDate(0, 0, 7*rule.IntervalWeeks*i)
+		lesson.EndsAt = lesson.StartsAt.Add(duration)
+
+		if !lesson.StartsAt.Before(until) {
+			break
+		}
+
+		lessons = append(lessons, lesson)
+	}
+
+	return lessons
+}
+
+// AddTimetableRule добавляет повторяющееся занятие и создаёт все его занятия.
+// Если хотя бы одно занятие пересекается с другими, ничего не создаётся и возвращается 409 со списком пересечений.
+func AddTimetableRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var rule model.TimetableRule
+
+	err = json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	isAdmin, err := isAdmin(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking administrator privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isAdmin {
+		rule.ProfessorID, err = strconv.Atoi(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if rule.IntervalWeeks == 0 {
+		rule.IntervalWeeks = 1
+	}
+
+	if rule.Timezone == "" {
+		rule.Timezone = "UTC"
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, timetableRuleLesson(rule))
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only add timetable rules for subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	problem, err := lessonProblem(timetableRuleLesson(rule))
+	if err != nil {
+		log.Println("lessonProblem error: ", err)
+		http.Error(w, "Error while checking lesson", http.StatusInternalServerError)
+		return
+	}
+
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	if rule.IntervalWeeks < 0 {
+		http.Error(w, "interval_weeks must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if rule.FirstEndsAt.Sub(rule.FirstStartsAt) >= 24*time.Hour {
+		http.Error(w, "Lesson of a timetable rule must be shorter than a day", http.StatusBadRequest)
+		return
+	}
+
+	location, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		http.Error(w, "timezone must be an IANA time zone name, for example Europe/Moscow", http.StatusBadRequest)
+		return
+	}
+
+	if rule.RepeatUntil.IsZero() || rule.RepeatUntil.Sub(rule.FirstStartsAt) > maxTimetableRuleDuration {
+		http.Error(w, "repeat_until is required and must be within a year of the first lesson", http.StatusBadRequest)
+		return
+	}
+
+	lessons := timetableRuleLessons(rule, location)
+	if len(lessons) == 0 {
+		http.Error(w, "repeat_until must not be before the first lesson", http.StatusBadRequest)
+		return
+	}
+
+	conflicts := []model.TimetableConflict{}
+
+	for _, lesson := range lessons {
+		found, err := lessonConflicts(lesson, 0)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Println("lessonConflicts deadline exceeded: ", err)
+				http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+				return
+			}
+			log.Println("lessonConflicts error: ", err)
+			http.Error(w, "Error while checking timetable conflicts", http.StatusInternalServerError)
+			return
+		}
+		conflicts = append(conflicts, found...)
+	}
+
+	if len(conflicts) > 0 {
+		writeTimetableConflicts(w, conflicts)
+		return
+	}
+
+	addTimetableRuleQuery := `
+		INSERT INTO timetable_rule (subject_id, group_id, subgroup_id, professor_id, room_id, first_starts_at,
+		                            first_ends_at, interval_weeks, repeat_until, timezone, topic, created_by)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+		RETURNING id;`
+
+	addRuleLessonQuery := `
+		INSERT INTO lesson (subject_id, group_id, subgroup_id, professor_id, room_id, starts_at, ends_at, topic,
+		                    timetable_rule_id)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), $6, $7, NULLIF($8, ''), $9);`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("AddTimetableRule BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, addTimetableRuleQuery, rule.SubjectID, rule.GroupID, rule.SubgroupID, rule.ProfessorID,
+		rule.RoomID, rule.FirstStartsAt, rule.FirstEndsAt, rule.IntervalWeeks, rule.RepeatUntil.Format(time.DateOnly),
+		rule.Timezone, rule.Topic, claims.Issuer).Scan(&rule.ID)
+	for i := 0; err == nil && i < len(lessons); i++ {
+		lesson := lessons[i]
+		_, err = tx.ExecContext(ctx, addRuleLessonQuery, lesson.SubjectID, lesson.GroupID, lesson.SubgroupID,
+			lesson.ProfessorID, lesson.RoomID, lesson.StartsAt, lesson.EndsAt, lesson.Topic, rule.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AddTimetableRule QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pgErr.Code == "23503" {
+			log.Println("Foreign key violation: ", err)
+			http.Error(w, "Subject, group, professor or room does not exist", http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rule.Lessons = len(lessons)
+
+	resp, err := json.Marshal(rule)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Add timetable rule failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteTimetableRule удаляет правило расписания id вместе с его будущими занятиями.
+// Прошедшие занятия остаются без привязки к правилу.
+func DeleteTimetableRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid HTTP method. Only DELETE is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var rule model.TimetableRule
+
+	err = json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	getTimetableRuleQuery := `
+		SELECT subject_id, group_id, COALESCE(subgroup_id, 0) FROM timetable_rule WHERE id = $1`
+
+	err = db.QueryRowContext(ctx, getTimetableRuleQuery, rule.ID).Scan(&rule.SubjectID, &rule.GroupID, &rule.SubgroupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Timetable rule not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canManage, err := canManageLesson(claims.Issuer, timetableRuleLesson(rule))
+	if err != nil {
+		log.Println("canManageLesson error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "You can only delete timetable rules for subjects and groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("DeleteTimetableRule BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM lesson WHERE timetable_rule_id = $1 AND starts_at > now();`, rule.ID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM timetable_rule WHERE id = $1;`, rule.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("DeleteTimetableRule QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Delete timetable rule successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Delete timetable rule failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListTimetableRules возвращает правила расписания. Фильтры group_id и professor_id необязательны.
+func ListTimetableRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID, err := optionalIntParam(r, "group_id")
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	professorID, err := optionalIntParam(r, "professor_id")
+	if err != nil {
+		http.Error(w, "professor_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	listTimetableRulesQuery := `
+		SELECT t.id, t.subject_id, s.subject_name, t.group_id, COALESCE(g.group_name, ''), COALESCE(t.subgroup_id, 0),
+		       COALESCE(t.professor_id, 0), COALESCE(t.room_id, 0), t.first_starts_at, t.first_ends_at,
+		       t.interval_weeks, t.repeat_until, t.timezone, COALESCE(t.topic, ''),
+		       (SELECT COUNT(*) FROM lesson l WHERE l.timetable_rule_id = t.id)
+		FROM timetable_rule t
+		JOIN subject s ON t.subject_id = s.id
+		JOIN group_uni g ON t.group_id = g.id
+		WHERE ($1 = 0 OR t.group_id = $1) AND ($2 = 0 OR t.professor_id = $2)
+		ORDER BY t.first_starts_at, t.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, listTimetableRulesQuery, groupID, professorID)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("ListTimetableRules QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rules := []model.TimetableRule{}
+
+	for rows.Next() {
+		var rule model.TimetableRule
+
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.SubjectID,
+			&rule.SubjectName,
+			&rule.GroupID,
+			&rule.GroupName,
+			&rule.SubgroupID,
+			&rule.ProfessorID,
+			&rule.RoomID,
+			&rule.FirstStartsAt,
+			&rule.FirstEndsAt,
+			&rule.IntervalWeeks,
+			&rule.RepeatUntil,
+			&rule.Timezone,
+			&rule.Topic,
+			&rule.Lessons); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(rules)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Timetable Rules failed: %v\n", err)
+	}
+}
+
+// GetTimetable возвращает расписание на день или неделю (view = day или week, по умолчанию week),
+// содержащие дату date (по умолчанию сегодня) в часовом поясе timezone (по умолчанию UTC).
+// С group_id - расписание группы, с professor_id - преподавателя, без них - текущего студента или преподавателя.
+func GetTimetable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	view := r.URL.Query().Get("view")
+	if view == "" {
+		view = "week"
+	}
+
+	if view != "day" && view != "week" {
+		http.Error(w, "view must be day or week", http.StatusBadRequest)
+		return
+	}
+
+	timezone := r.URL.Query().Get("timezone")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		http.Error(w, "timezone must be an IANA time zone name, for example Europe/Moscow", http.StatusBadRequest)
+		return
+	}
+
+	date := time.Now().In(location)
+	if paramDate := r.URL.Query().Get("date"); paramDate != "" {
+		date, err = time.ParseInLocation(time.DateOnly, paramDate, location)
+		if err != nil {
+			http.Error(w, "date must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	groupID, err := optionalIntParam(r, "group_id")
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	professorID, err := optionalIntParam(r, "professor_id")
+	if err != nil {
+		http.Error(w, "professor_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+	days := 1
+	if view == "week" {
+		from = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		days = 7
+	}
+	to := from.AddDate(0, 0, days)
+
+	timetableQuery := `SELECT ` + lessonColumns + ` WHERE l.starts_at >= $1 AND l.starts_at < $2 AND `
+	var ownerID interface{}
+
+	switch {
+	case groupID != 0:
+		timetableQuery += `l.group_id = $3`
+		ownerID = groupID
+	case professorID != 0:
+		timetableQuery += `l.professor_id = $3`
+		ownerID = professorID
+	default:
+		isStudent, err := isStudent(claims.Issuer)
+		if err != nil {
+			http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if isStudent {
+			timetableQuery += `EXISTS (SELECT 1 FROM person p WHERE p.id = $3 AND ` + lessonStudentCondition + `)`
+		} else {
+			timetableQuery += `l.professor_id = $3`
+		}
+		ownerID = claims.Issuer
+	}
+
+	timetableQuery += ` ORDER BY l.starts_at, l.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, timetableQuery, from, to, ownerID)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("GetTimetable QueryContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		log.Println("Database error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	timetable := model.Timetable{
+		From: from.Format(time.DateOnly),
+		To:   to.AddDate(0, 0, -1).Format(time.DateOnly),
+		Days: make([]model.TimetableDay, days),
+	}
+
+	for i := range timetable.Days {
+		timetable.Days[i] = model.TimetableDay{
+			Date:    from.AddDate(0, 0, i).Format(time.DateOnly),
+			Lessons: []model.Lesson{},
+		}
+	}
+
+	for rows.Next() {
+		lesson, err := scanLesson(rows)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		lesson.StartsAt, lesson.EndsAt = lesson.StartsAt.In(location), lesson.EndsAt.In(location)
+		for i := range timetable.Days {
+			if timetable.Days[i].Date == lesson.StartsAt.Format(time.DateOnly) {
+				timetable.Days[i].Lessons = append(timetable.Days[i].Lessons, lesson)
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(timetable)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Timetable failed: %v\n", err)
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+	"time"
+)
+
+func TestTimetableRuleLessons(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database is not available: ", err)
+	}
+
+	// Переход на летнее время в Берлине - 31 марта 2024
+	firstStartsAt := time.Date(2024, 3, 18, 10, 0, 0, 0, location)
+
+	tests := []struct {
+		name          string
+		intervalWeeks int
+		repeatUntil   time.Time
+		days          []int
+	}{
+		{"weekly across DST, until is inclusive", 1, time.Date(2024, 4, 8, 0, 0, 0, 0, time.UTC), []int{18, 25, 32, 39}},
+		{"every two weeks", 2, time.Date(2024, 4, 7, 0, 0, 0, 0, time.UTC), []int{18, 32}},
+		{"until the first day", 1, time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC), []int{18}},
+		{"until before the first day", 1, time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := model.TimetableRule{
+				SubjectID:     3,
+				GroupID:       5,
+				FirstStartsAt: firstStartsAt.UTC(),
+				FirstEndsAt:   firstStartsAt.Add(90 * time.Minute).UTC(),
+				IntervalWeeks: test.intervalWeeks,
+				RepeatUntil:   test.repeatUntil,
+			}
+
+			lessons := timetableRuleLessons(rule, location)
+			if len(lessons) != len(test.days) {
+				t.Fatalf("got %d lessons, want %d", len(lessons), len(test.days))
+			}
+
+			for i, lesson := range lessons {
+				want := time.Date(2024, 3, test.days[i], 10, 0, 0, 0, location)
+				if !lesson.StartsAt.Equal(want) {
+					t.Errorf("lesson %d starts at %v, want %v", i, lesson.StartsAt, want)
+				}
+				if lesson.EndsAt.Sub(lesson.StartsAt) != 90*time.Minute {
+					t.Errorf("lesson %d lasts %v, want 1h30m", i, lesson.EndsAt.Sub(lesson.StartsAt))
+				}
+				if lesson.SubjectID != rule.SubjectID || lesson.GroupID != rule.GroupID {
+					t.Errorf("lesson %d has subject %d, group %d", i, lesson.SubjectID, lesson.GroupID)
+				}
+			}
+		})
+	}
+}
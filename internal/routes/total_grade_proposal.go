@@ -0,0 +1,645 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	gradingMethodAverage             = "average"
+	gradingMethodWeighted            = "weighted"
+	gradingMethodAttendancePenalized = "attendance_penalized"
+)
+
+func isValidGradingMethod(gradingMethod string) bool {
+	switch gradingMethod {
+	case gradingMethodAverage, gradingMethodWeighted, gradingMethodAttendancePenalized:
+		return true
+	}
+	return false
+}
+
+// nearestScaleValue возвращает значение шкалы с ближайшим к score числовым эквивалентом.
+// При равенстве расстояний выбирается большее значение.
+func nearestScaleValue(values []model.GradingScaleValue, score float64) string {
+	nearest := ""
+	bestDistance := math.Inf(1)
+
+	for _, value := range values {
+		distance := math.Abs(value.NumericValue - score)
+		if distance < bestDistance || (distance == bestDistance && value.NumericValue > score) {
+			nearest = value.Value
+			bestDistance = distance
+		}
+	}
+
+	return nearest
+}
+
+// totalGradeProposals предлагает итоговые оценки по предмету subjectID для студента studentID
+// или для всех студентов группы groupID, если studentID = 0. Способ расчёта берётся из subject.grading_method.
+func totalGradeProposals(groupID, studentID, subjectID int) ([]model.TotalGradeProposal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	var gradingMethod string
+	var attendancePenalty float64
+
+	subjectRulesQuery := `SELECT grading_method, attendance_penalty FROM subject WHERE id = $1`
+
+	err := db.QueryRowContext(ctx, subjectRulesQuery, subjectID).Scan(&gradingMethod, &attendancePenalty)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleValuesQuery := `
+		SELECT gsv.value, gsv.numeric_value
+		FROM subject s
+		JOIN grading_scale_value gsv ON gsv.scale_id = s.grading_scale_id
+		WHERE s.id = $1`
+
+	valueRows, err := db.QueryContext(ctx, scaleValuesQuery, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer valueRows.Close()
+
+	var values []model.GradingScaleValue
+
+	for valueRows.Next() {
+		var value model.GradingScaleValue
+		if err := valueRows.Scan(&value.Value, &value.NumericValue); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	if err := valueRows.Err(); err != nil {
+		return nil, err
+	}
+
+	weighted := map[int]model.WeightedScore{}
+	if gradingMethod == gradingMethodWeighted {
+		scores, err := weightedScores(groupID, studentID, subjectID)
+		if err != nil {
+			return nil, err
+		}
+		for _, score := range scores {
+			weighted[score.StudentID] = score
+		}
+	}
+
+	// Пропуски считаются по отметкам занятий с учётом правил посещаемости предмета
+	attendance := attendanceFilter{subjectID: subjectID, groupID: groupID}
+	if studentID != 0 {
+		attendance = attendanceFilter{subjectID: subjectID, studentID: studentID}
+	}
+
+	summaries, err := attendanceSummaries(attendance)
+	if err != nil {
+		return nil, err
+	}
+
+	absences := map[int]int{}
+	for _, summary := range summaries {
+		absences[summary.StudentID] = summary.EffectiveAbsences
+	}
+
+	// Записи без оценки (grade IS NULL) в среднее не входят
+	lessonSummaryQuery := `
+		SELECT p.id, p.firstname, p.lastname, 
+		       AVG(sg.grade), 
+		       COALESCE(stg.grade, ''), COALESCE(stg.is_override, false)
+		FROM person p
+		LEFT JOIN student_grades sg ON sg.student_id = p.id AND sg.subject_id = $1` + latestTotalGradeJoin("p.id", "$1") + `
+		WHERE p.is_professor = false AND p.is_admin = false 
+		  AND (($3 = 0 AND p.group_id = $2) OR p.id = $3)
+		GROUP BY p.id, p.firstname, p.lastname, stg.grade, stg.is_override
+		ORDER BY p.lastname, p.firstname, p.id`
+
+	rows, err := db.QueryContext(ctx, lessonSummaryQuery, subjectID, groupID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proposals []model.TotalGradeProposal
+
+	for rows.Next() {
+		var proposal model.TotalGradeProposal
+		var average *float64
+
+		if err := rows.Scan(
+			&proposal.StudentID,
+			&proposal.StudentFirstname,
+			&proposal.StudentLastname,
+			&average,
+			&proposal.CurrentGrade,
+			&proposal.IsOverride); err != nil {
+			return nil, err
+		}
+
+		proposal.SubjectID = subjectID
+		proposal.GradingMethod = gradingMethod
+		proposal.Absences = absences[proposal.StudentID]
+
+		hasGrades := average != nil
+
+		switch gradingMethod {
+		case gradingMethodWeighted:
+			hasGrades = false
+			for _, category := range weighted[proposal.StudentID].Categories {
+				if category.GradeCount > 0 {
+					hasGrades = true
+				}
+			}
+			proposal.Score = weighted[proposal.StudentID].Score
+		case gradingMethodAttendancePenalized:
+			if hasGrades {
+				proposal.Score = *average - attendancePenalty*float64(proposal.Absences)
+			}
+		default:
+			if hasGrades {
+				proposal.Score = *average
+			}
+		}
+
+		proposal.Score = math.Round(proposal.Score*100) / 100
+
+		if hasGrades {
+			proposal.ProposedGrade = nearestScaleValue(values, proposal.Score)
+		}
+
+		proposals = append(proposals, proposal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return proposals, nil
+}
+
+// ListTotalGradeProposals возвращает предложенные итоговые оценки группы по предмету вместе с текущими.
+// Доступно администраторам и преподавателям предмета.
+func ListTotalGradeProposals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paramGroupID := r.URL.Query().Get("group_id")
+
+	groupID, err := strconv.Atoi(paramGroupID)
+	if err != nil {
+		http.Error(w, "group_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	paramSubjectID := r.URL.Query().Get("subject_id")
+
+	subjectID, err := strconv.Atoi(paramSubjectID)
+	if err != nil {
+		http.Error(w, "subject_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	canManage, err := canManageSubject(claims.Issuer, subjectID)
+	if err != nil {
+		log.Println("canManageSubject error: ", err)
+		http.Error(w, "Error while checking privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !canManage {
+		http.Error(w, "Only administrators and professors of this subject can view total grade proposals", http.StatusUnauthorized)
+		return
+	}
+
+	proposals, err := totalGradeProposals(groupID, 0, subjectID)
+	if err != nil {
+		log.Println("totalGradeProposals error: ", err)
+		http.Error(w, "Error while computing total grade proposals", http.StatusInternalServerError)
+		return
+	}
+
+	if proposals == nil {
+		proposals = []model.TotalGradeProposal{}
+	}
+
+	resp, err := json.Marshal(proposals)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("List Total Grade Proposals failed: %v\n", err)
+	}
+}
+
+// AcceptTotalGradeProposals записывает предложенные оценки в student_total_grades.
+// Оценки, выставленные вручную через OverrideTotalGradeOfAStudent, не перезаписываются.
+func AcceptTotalGradeProposals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid HTTP method. Only POST is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isProfessor, err := isProfessor(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isProfessor {
+		http.Error(w, "You do not have professor privileges", http.StatusUnauthorized)
+		return
+	}
+
+	var accept model.AcceptTotalGradeProposals
+	err = json.NewDecoder(r.Body).Decode(&accept)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	hasSubject, err := professorHasSubject(claims.Issuer, accept.SubjectID)
+	if err != nil {
+		log.Println("professorHasSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject {
+		http.Error(w, "You can only set total grades for subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	proposals, err := totalGradeProposals(accept.GroupID, 0, accept.SubjectID)
+	if err != nil {
+		log.Println("totalGradeProposals error: ", err)
+		http.Error(w, "Error while computing total grade proposals", http.StatusInternalServerError)
+		return
+	}
+
+	selected := map[int]bool{}
+	for _, studentID := range accept.StudentIDs {
+		selected[studentID] = true
+	}
+
+	accepted := []model.TotalGradeProposal{}
+
+	for _, proposal := range proposals {
+		if len(selected) > 0 && !selected[proposal.StudentID] {
+			continue
+		}
+		if proposal.ProposedGrade == "" || proposal.IsOverride {
+			continue
+		}
+
+		hasGroup, err := professorHasGroup(claims.Issuer, proposal.StudentID)
+		if err != nil {
+			log.Println("professorHasGroup error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasGroup {
+			http.Error(w, "You can only set total grades for students from groups that you teach", http.StatusUnauthorized)
+			return
+		}
+
+		accepted = append(accepted, proposal)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("AcceptTotalGradeProposals BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	applied := []model.TotalGradeProposal{}
+
+	for _, proposal := range accepted {
+		studentTotalGrade := model.StudentTotalGrade{
+			StudentID: proposal.StudentID,
+			SubjectID: accept.SubjectID,
+			TermID:    accept.TermID,
+			Grade:     proposal.ProposedGrade,
+			Reason:    accept.Reason,
+		}
+
+		var ok bool
+		ok, err = upsertTotalGrade(ctx, tx, studentTotalGrade, false, claims.Issuer)
+		if err != nil {
+			break
+		}
+		if ok {
+			applied = append(applied, proposal)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errReasonRequired) {
+			http.Error(w, "Reason is required for changes after the term end date", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("AcceptTotalGradeProposals QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(applied)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("AcceptTotalGradeProposals failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// OverrideTotalGradeOfAStudent выставляет итоговую оценку вручную с обязательной причиной.
+// Такая оценка не перезаписывается при принятии предложений.
+func OverrideTotalGradeOfAStudent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Invalid HTTP method. Only PUT is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isProfessor, err := isProfessor(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !isProfessor {
+		http.Error(w, "You do not have professor privileges", http.StatusUnauthorized)
+		return
+	}
+
+	var studentTotalGrade model.StudentTotalGrade
+	err = json.NewDecoder(r.Body).Decode(&studentTotalGrade)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	hasSubject, err := professorHasSubject(claims.Issuer, studentTotalGrade.SubjectID)
+	if err != nil {
+		log.Println("professorHasSubject error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasSubject {
+		http.Error(w, "You can only set total grades for subjects that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	hasGroup, err := professorHasGroup(claims.Issuer, studentTotalGrade.StudentID)
+	if err != nil {
+		log.Println("professorHasGroup error: ", err)
+		http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+		return
+	}
+
+	if !hasGroup {
+		hasGroup, err = professorHasEnrolledStudent(claims.Issuer, studentTotalGrade.StudentID, studentTotalGrade.SubjectID)
+		if err != nil {
+			log.Println("professorHasEnrolledStudent error: ", err)
+			http.Error(w, "Error while checking professor privileges", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !hasGroup {
+		http.Error(w, "You can only set total grades for students from groups that you teach", http.StatusUnauthorized)
+		return
+	}
+
+	studentHasSubject, err := studentHasSubject(studentTotalGrade.StudentID, studentTotalGrade.SubjectID)
+	if err != nil {
+		http.Error(w, "Error while checking if student has this subject", http.StatusInternalServerError)
+		return
+	}
+
+	if !studentHasSubject {
+		http.Error(w, "Student doesn't have this subject in his program", http.StatusUnauthorized)
+		return
+	}
+
+	studentTotalGrade.OverrideReason = strings.TrimSpace(studentTotalGrade.OverrideReason)
+	if studentTotalGrade.OverrideReason == "" {
+		http.Error(w, "Override reason cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(studentTotalGrade.Grade) > 50 {
+		http.Error(w, "Grade length cannot be bigger than 50 characters", http.StatusBadRequest)
+		return
+	}
+
+	gradeProblem, err := totalGradeProblem(studentTotalGrade.SubjectID, studentTotalGrade.Grade)
+	if err != nil {
+		log.Println("totalGradeProblem error: ", err)
+		http.Error(w, "Error while checking grade against grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	if gradeProblem != "" {
+		http.Error(w, gradeProblem, http.StatusBadRequest)
+		return
+	}
+
+	// Причина ручной оценки записывается и в историю изменений
+	if studentTotalGrade.Reason == "" {
+		studentTotalGrade.Reason = studentTotalGrade.OverrideReason
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("OverrideTotalGradeOfAStudent BeginTx error: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = upsertTotalGrade(ctx, tx, studentTotalGrade, true, claims.Issuer)
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		if errors.Is(err, errGradeLocked) {
+			http.Error(w, "Grades of this group and subject are finalized", http.StatusConflict)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Println("OverrideTotalGradeOfAStudent QueryRowContext deadline exceeded: ", err)
+			http.Error(w, "Database query time limit exceeded", http.StatusGatewayTimeout)
+			return
+		}
+
+		var pgErr *pq.Error
+		if ok := errors.As(err, &pgErr); !ok {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Database error: ", pgErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal("Override Total Grade Of A Student Successful")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("OverrideTotalGradeOfAStudent failed: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
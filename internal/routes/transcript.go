@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errStudentNotFound = errors.New("student not found")
+
+func roundHundredths(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// transcriptGrade - итоговая оценка студента вместе с семестром.
+// percent - процент оценки от максимума шкалы, nil для зачётных шкал и оценок, которых нет в шкале.
+type transcriptGrade struct {
+	termID   int
+	termName string
+	entry    model.TranscriptEntry
+	percent  *float64
+}
+
+// addTranscriptGrades раскладывает оценки, упорядоченные по семестрам, в transcript и считает кредиты и GPA.
+// Кредиты засчитываются только за сданные предметы, попытки учитываются отдельно.
+func addTranscriptGrades(transcript *model.Transcript, grades []transcriptGrade) {
+	transcript.Terms = []model.TranscriptTerm{}
+
+	var cumulativePoints, cumulativeGradedCredits float64
+	var termPoints, termGradedCredits float64
+
+	for _, grade := range grades {
+		if len(transcript.Terms) == 0 || transcript.Terms[len(transcript.Terms)-1].TermID != grade.termID {
+			termPoints, termGradedCredits = 0, 0
+			transcript.Terms = append(transcript.Terms, model.TranscriptTerm{
+				TermID:   grade.termID,
+				TermName: grade.termName,
+				Entries:  []model.TranscriptEntry{},
+			})
+		}
+
+		term := &transcript.Terms[len(transcript.Terms)-1]
+		term.Entries = append(term.Entries, grade.entry)
+
+		term.TermAttemptedCredits += grade.entry.Credits
+		transcript.AttemptedCredits += grade.entry.Credits
+		if grade.entry.Passed {
+			term.TermCredits += grade.entry.Credits
+			transcript.TotalCredits += grade.entry.Credits
+		}
+
+		if grade.percent != nil && grade.entry.Credits > 0 {
+			termPoints += *grade.percent * grade.entry.Credits
+			termGradedCredits += grade.entry.Credits
+			cumulativePoints += *grade.percent * grade.entry.Credits
+			cumulativeGradedCredits += grade.entry.Credits
+		}
+
+		if termGradedCredits > 0 {
+			term.TermGPA = roundHundredths(termPoints / termGradedCredits)
+		}
+		if cumulativeGradedCredits > 0 {
+			transcript.GPA = roundHundredths(cumulativePoints / cumulativeGradedCredits)
+		}
+		term.CumulativeCredits = transcript.TotalCredits
+		term.CumulativeAttemptedCredits = transcript.AttemptedCredits
+		term.CumulativeGPA = transcript.GPA
+	}
+}
+
+// buildTranscript собирает итоговые оценки студента по семестрам.
+// Числовой эквивалент оценки переводится в процент от максимума шкалы предмета, чтобы GPA
+// не смешивал шкалы. Зачётные шкалы и оценки, которых нет в шкале, в GPA не входят.
+func buildTranscript(studentID int) (model.Transcript, error) {
+	var transcript model.Transcript
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeLimit)*time.Second)
+	defer cancel()
+
+	studentQuery := `
+		SELECT p.id, p.firstname, p.lastname, COALESCE(g.group_name, '')
+		FROM person p
+		LEFT JOIN group_uni g ON p.group_id = g.id
+		WHERE p.id = $1 AND p.is_professor = false AND p.is_admin = false;`
+
+	err := db.QueryRowContext(ctx, studentQuery, studentID).Scan(
+		&transcript.StudentID,
+		&transcript.StudentFirstname,
+		&transcript.StudentLastname,
+		&transcript.StudentGroupName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return transcript, errStudentNotFound
+		}
+		return transcript, err
+	}
+
+	totalGradesQuery := `
+		SELECT COALESCE(t.id, 0), COALESCE(t.term_name, ''),
+		       s.id, COALESCE(s.subject_code, ''), s.subject_name, s.credits, stg.grade,
+		       CASE WHEN NOT gs.is_pass_fail AND scale_max.numeric_value > 0
+		            THEN gsv.numeric_value * 100 / scale_max.numeric_value END,
+		       COALESCE(gsv.numeric_value >= gs.passing_threshold, false)
+		FROM student_total_grades stg
+		JOIN subject s ON stg.subject_id = s.id
+		JOIN grading_scale gs ON s.grading_scale_id = gs.id
+		LEFT JOIN grading_scale_value gsv ON gsv.scale_id = gs.id AND gsv.value = stg.grade
+		LEFT JOIN LATERAL (
+		    SELECT MAX(numeric_value) AS numeric_value FROM grading_scale_value WHERE scale_id = gs.id
+		) scale_max ON true
+		LEFT JOIN term t ON stg.term_id = t.id
+		WHERE stg.student_id = $1
+		ORDER BY t.start_date NULLS FIRST, t.id, s.subject_name;`
+
+	rows, err := db.QueryContext(ctx, totalGradesQuery, studentID)
+	if err != nil {
+		return transcript, err
+	}
+	defer rows.Close()
+
+	var grades []transcriptGrade
+
+	for rows.Next() {
+		var grade transcriptGrade
+
+		if err := rows.Scan(
+			&grade.termID,
+			&grade.termName,
+			&grade.entry.SubjectID,
+			&grade.entry.SubjectCode,
+			&grade.entry.SubjectName,
+			&grade.entry.Credits,
+			&grade.entry.Grade,
+			&grade.percent,
+			&grade.entry.Passed); err != nil {
+			return transcript, err
+		}
+
+		grades = append(grades, grade)
+	}
+
+	if err := rows.Err(); err != nil {
+		return transcript, err
+	}
+
+	addTranscriptGrades(&transcript, grades)
+
+	return transcript, nil
+}
+
+// GetTranscript возвращает выписку оценок студента в JSON или PDF (format=pdf).
+// Студент получает свою выписку, преподаватели и администраторы передают student_id.
+func GetTranscript(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid HTTP method. Only GET is allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "pdf" {
+		http.Error(w, "format must be json or pdf", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(jwtName)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := jwtCheck(cookie)
+
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	claims := token.Claims.(*jwt.RegisteredClaims)
+
+	userExists, err := checkUserExists(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking user authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if !userExists {
+		log.Println("User with id ", claims.Issuer, "does not exist: ", err)
+		http.Error(w, "You are not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	isStudent, err := isStudent(claims.Issuer)
+	if err != nil {
+		http.Error(w, "Error while checking is user is a student", http.StatusInternalServerError)
+		return
+	}
+
+	paramStudentID := r.URL.Query().Get("student_id")
+	if isStudent {
+		paramStudentID = claims.Issuer
+	}
+
+	studentID, err := strconv.Atoi(paramStudentID)
+	if err != nil {
+		http.Error(w, "student_id must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	transcript, err := buildTranscript(studentID)
+	if err != nil {
+		if errors.Is(err, errStudentNotFound) {
+			http.Error(w, "Student not found", http.StatusNotFound)
+			return
+		}
+		log.Println("buildTranscript error: ", err)
+		http.Error(w, "Error while building transcript", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "pdf" {
+		var buf bytes.Buffer
+
+		if err := writeTranscriptPDF(&buf, transcript); err != nil {
+			if errors.Is(err, errPDFFontNotConfigured) {
+				log.Println("writeTranscriptPDF error: ", err)
+				http.Error(w, "PDF transcripts are not available, the server has no font with Cyrillic", http.StatusServiceUnavailable)
+				return
+			}
+			log.Println("writeTranscriptPDF error: ", err)
+			http.Error(w, "Error while rendering transcript", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="transcript_`+strconv.Itoa(studentID)+`.pdf"`)
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(buf.Bytes())
+		if err != nil {
+			log.Printf("Get Transcript failed: %v\n", err)
+		}
+		return
+	}
+
+	resp, err := json.Marshal(transcript)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Get Transcript failed: %v\n", err)
+	}
+}
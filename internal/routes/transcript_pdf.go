@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"github.com/go-pdf/fpdf"
+	"io"
+	"os"
+)
+
+// pdfFontPath - путь к TTF шрифту с кириллицей. Встроенные шрифты PDF кириллицу не выводят,
+// поэтому без него выписка в PDF не строится.
+var pdfFontPath string
+
+var errPDFFontNotConfigured = errors.New("PDF_FONT_PATH is not set")
+
+func writeTranscriptPDF(out io.Writer, transcript model.Transcript) error {
+	if pdfFontPath == "" {
+		return errPDFFontNotConfigured
+	}
+
+	fontBytes, err := os.ReadFile(pdfFontPath)
+	if err != nil {
+		return err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+
+	fontFamily := "TranscriptFont"
+	pdf.AddUTF8FontFromBytes(fontFamily, "", fontBytes)
+
+	pdf.AddPage()
+
+	pdf.SetFont(fontFamily, "", 16)
+	pdf.CellFormat(0, 10, "Transcript", "", 1, "C", false, 0, "")
+
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.CellFormat(0, 7, fmt.Sprintf("%s %s", transcript.StudentLastname, transcript.StudentFirstname), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 7, transcript.StudentGroupName, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	for _, term := range transcript.Terms {
+		termName := term.TermName
+		if term.TermID == 0 {
+			termName = "-"
+		}
+
+		pdf.SetFont(fontFamily, "", 12)
+		pdf.CellFormat(0, 8, termName, "", 1, "L", false, 0, "")
+
+		pdf.SetFont(fontFamily, "", 10)
+		pdf.CellFormat(25, 7, "Code", "1", 0, "L", false, 0, "")
+		pdf.CellFormat(105, 7, "Subject", "1", 0, "L", false, 0, "")
+		pdf.CellFormat(25, 7, "Credits", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 7, "Grade", "1", 1, "R", false, 0, "")
+
+		for _, entry := range term.Entries {
+			pdf.CellFormat(25, 7, entry.SubjectCode, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(105, 7, entry.SubjectName, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(25, 7, fmt.Sprintf("%.1f", entry.Credits), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(35, 7, entry.Grade, "1", 1, "R", false, 0, "")
+		}
+
+		pdf.CellFormat(0, 7, fmt.Sprintf("Term credits: %.1f of %.1f   Term GPA: %.2f%%   Cumulative credits: %.1f of %.1f   Cumulative GPA: %.2f%%",
+			term.TermCredits, term.TermAttemptedCredits, term.TermGPA,
+			term.CumulativeCredits, term.CumulativeAttemptedCredits, term.CumulativeGPA), "", 1, "L", false, 0, "")
+		pdf.Ln(3)
+	}
+
+	pdf.SetFont(fontFamily, "", 12)
+	pdf.CellFormat(0, 8, fmt.Sprintf("Total credits: %.1f of %.1f attempted   GPA: %.2f%%",
+		transcript.TotalCredits, transcript.AttemptedCredits, transcript.GPA), "", 1, "L", false, 0, "")
+
+	return pdf.Output(out)
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"github.com/BukhryakovVladimir/learn_live/internal/model"
+	"testing"
+)
+
+func transcriptPercent(value float64) *float64 {
+	return &value
+}
+
+func TestAddTranscriptGrades(t *testing.T) {
+	grades := []transcriptGrade{
+		// 5 по 5-балльной шкале и 80 по 100-балльной: 100% и 80%
+		{termID: 1, termName: "Fall", entry: model.TranscriptEntry{SubjectID: 1, Credits: 4, Grade: "5", Passed: true}, percent: transcriptPercent(100)},
+		{termID: 1, termName: "Fall", entry: model.TranscriptEntry{SubjectID: 2, Credits: 2, Grade: "80", Passed: true}, percent: transcriptPercent(80)},
+		// Зачёт не входит в GPA, но кредиты приносит
+		{termID: 1, termName: "Fall", entry: model.TranscriptEntry{SubjectID: 3, Credits: 1, Grade: "pass", Passed: true}},
+		// Несданный предмет входит в GPA, но кредиты не приносит
+		{termID: 2, termName: "Spring", entry: model.TranscriptEntry{SubjectID: 4, Credits: 2, Grade: "2", Passed: false}, percent: transcriptPercent(40)},
+		{termID: 2, termName: "Spring", entry: model.TranscriptEntry{SubjectID: 5, Credits: 3, Grade: "fail", Passed: false}},
+	}
+
+	var transcript model.Transcript
+	addTranscriptGrades(&transcript, grades)
+
+	if len(transcript.Terms) != 2 {
+		t.Fatalf("got %d terms, want 2", len(transcript.Terms))
+	}
+
+	fall, spring := transcript.Terms[0], transcript.Terms[1]
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"fall credits", fall.TermCredits, 7},
+		{"fall attempted credits", fall.TermAttemptedCredits, 7},
+		{"fall GPA", fall.TermGPA, 93.33},
+		{"fall cumulative GPA", fall.CumulativeGPA, 93.33},
+		{"spring credits", spring.TermCredits, 0},
+		{"spring attempted credits", spring.TermAttemptedCredits, 5},
+		{"spring GPA", spring.TermGPA, 40},
+		{"spring cumulative credits", spring.CumulativeCredits, 7},
+		{"spring cumulative attempted credits", spring.CumulativeAttemptedCredits, 12},
+		{"spring cumulative GPA", spring.CumulativeGPA, 80},
+		{"total credits", transcript.TotalCredits, 7},
+		{"attempted credits", transcript.AttemptedCredits, 12},
+		{"GPA", transcript.GPA, 80},
+	}
+
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+// Пересдача в следующем семестре - отдельная попытка: кредиты предмета засчитываются один раз, а в попытках - дважды
+func TestAddTranscriptGradesCountsRetakes(t *testing.T) {
+	grades := []transcriptGrade{
+		{termID: 1, termName: "Fall", entry: model.TranscriptEntry{SubjectID: 1, Credits: 3, Grade: "2", Passed: false}, percent: transcriptPercent(40)},
+		{termID: 2, termName: "Spring", entry: model.TranscriptEntry{SubjectID: 1, Credits: 3, Grade: "4", Passed: true}, percent: transcriptPercent(80)},
+	}
+
+	var transcript model.Transcript
+	addTranscriptGrades(&transcript, grades)
+
+	if len(transcript.Terms) != 2 {
+		t.Fatalf("got %d terms, want 2", len(transcript.Terms))
+	}
+
+	if transcript.TotalCredits != 3 || transcript.AttemptedCredits != 6 {
+		t.Errorf("credits = %v of %v attempted, want 3 of 6", transcript.TotalCredits, transcript.AttemptedCredits)
+	}
+	if transcript.Terms[0].TermCredits != 0 || transcript.Terms[1].TermCredits != 3 {
+		t.Errorf("term credits = %v, %v, want 0, 3", transcript.Terms[0].TermCredits, transcript.Terms[1].TermCredits)
+	}
+}
+
+// Без шрифта с кириллицей выписка не строится, а не выводится встроенным Helvetica
+func TestWriteTranscriptPDFRequiresFont(t *testing.T) {
+	defer func(path string) { pdfFontPath = path }(pdfFontPath)
+	pdfFontPath = ""
+
+	var buf bytes.Buffer
+	err := writeTranscriptPDF(&buf, model.Transcript{StudentLastname: "Иванов", StudentFirstname: "Иван"})
+	if !errors.Is(err, errPDFFontNotConfigured) {
+		t.Errorf("writeTranscriptPDF error = %v, want errPDFFontNotConfigured", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("writeTranscriptPDF wrote %d bytes", buf.Len())
+	}
+}